@@ -0,0 +1,220 @@
+// Command archive backs up the database to a portable JSON archive and
+// restores it, also into another deployment with different IDs.
+//
+// Usage:
+//
+//	go run ./cmd/archive export -o backup.jsonl.gz [-school 013601,民權國民小學] [-county 高雄市] [-source 高雄市] [-skip-norms]
+//	go run ./cmd/archive import -i backup.jsonl.gz [-school ...] [-county ...] [-skip-norms] [-dry-run]
+//
+// Files ending in .gz are compressed; "-" reads from stdin or writes to stdout.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/wei979/ICACP/backend/internal/services"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  archive export -o FILE [-school CODES_OR_NAMES] [-county NAMES] [-source TEXT] [-skip-norms]")
+	fmt.Fprintln(os.Stderr, "  archive import -i FILE [-school CODES_OR_NAMES] [-county NAMES] [-skip-norms] [-dry-run]")
+	os.Exit(2)
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "archive file to write (.gz to compress, - for stdout)")
+	schools := flags.String("school", "", "comma-separated school codes or names to export (default all)")
+	counties := flags.String("county", "", "comma-separated counties to export (default all)")
+	source := flags.String("source", "", "name of this deployment, stored in the archive header")
+	skipNorms := flags.Bool("skip-norms", false, "leave out the official norm tables")
+	flags.Parse(args)
+	if *output == "" {
+		log.Fatal("-o is required")
+	}
+
+	db := connect()
+
+	w, closeOutput, err := openOutput(*output)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *output, err)
+	}
+
+	counts, err := services.NewArchiveService(db).Export(w, services.ArchiveExportOptions{
+		Filter:    services.ArchiveFilter{Schools: splitList(*schools), Counties: splitList(*counties)},
+		Source:    *source,
+		SkipNorms: *skipNorms,
+	})
+	if err != nil {
+		closeOutput()
+		if *output != "-" {
+			os.Remove(*output)
+		}
+		log.Fatalf("Export failed: %v", err)
+	}
+	if err := closeOutput(); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+
+	log.Printf("Exported to %s", *output)
+	printJSON(counts)
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("i", "", "archive file to read (.gz if compressed, - for stdin)")
+	schools := flags.String("school", "", "comma-separated school codes or names to restore (default all)")
+	counties := flags.String("county", "", "comma-separated counties to restore (default all)")
+	skipNorms := flags.Bool("skip-norms", false, "do not restore the official norm tables")
+	dryRun := flags.Bool("dry-run", false, "run the restore and roll it back, reporting what would change")
+	flags.Parse(args)
+	if *input == "" {
+		log.Fatal("-i is required")
+	}
+
+	db := connect()
+
+	r, closeInput, err := openInput(*input)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *input, err)
+	}
+	defer closeInput()
+
+	result, err := services.NewArchiveService(db).Import(r, services.ArchiveImportOptions{
+		Filter:    services.ArchiveFilter{Schools: splitList(*schools), Counties: splitList(*counties)},
+		SkipNorms: *skipNorms,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		log.Fatalf("Import failed, nothing was changed: %v", err)
+	}
+
+	if result.DryRun {
+		log.Println("Dry run: no changes were saved")
+	} else {
+		log.Println("Import finished")
+	}
+	printJSON(result)
+}
+
+// connect opens the database from DATABASE_URL
+func connect() *gorm.DB {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		log.Fatal("DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	return db
+}
+
+// openOutput opens the archive to write, compressing .gz files
+func openOutput(path string) (io.Writer, func() error, error) {
+	var file *os.File
+	if path == "-" {
+		file = os.Stdout
+	} else {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		file = f
+	}
+
+	closeFile := func() error {
+		if file == os.Stdout {
+			return nil
+		}
+		return file.Close()
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, closeFile, nil
+	}
+
+	gz := gzip.NewWriter(file)
+	return gz, func() error {
+		if err := gz.Close(); err != nil {
+			closeFile()
+			return err
+		}
+		return closeFile()
+	}, nil
+}
+
+// openInput opens the archive to read, decompressing .gz files
+func openInput(path string) (io.Reader, func() error, error) {
+	var file *os.File
+	if path == "-" {
+		file = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		file = f
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return file, file.Close, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return gz, func() error {
+		gz.Close()
+		return file.Close()
+	}, nil
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printJSON(value interface{}) {
+	out, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Fprintln(os.Stderr, string(out))
+}
@@ -107,7 +107,7 @@ func main() {
 		if searchErr != nil {
 			time.Sleep(1100 * time.Millisecond)
 			// Extract district from address (e.g., 那瑪夏區, 三地門鄉)
-			district := extractDistrict(school.Address)
+			district := models.ExtractDistrict(school.CountyName, school.Address)
 			if district != "" {
 				searchQuery = fmt.Sprintf("%s %s Taiwan", school.Name, district)
 				lat, lon, searchErr = geocodeAddress(searchQuery)
@@ -193,29 +193,6 @@ func isInTaiwan(lat, lon float64) bool {
 	return lat >= 21.5 && lat <= 26.5 && lon >= 119.5 && lon <= 122.5
 }
 
-// extractDistrict extracts district/township name from address
-func extractDistrict(address string) string {
-	// Common patterns for Taiwan districts
-	// e.g., "高雄市那瑪夏區..." -> "那瑪夏區"
-	// e.g., "屏東縣三地門鄉..." -> "三地門鄉"
-	suffixes := []string{"區", "鄉", "鎮", "市"}
-
-	for _, suffix := range suffixes {
-		idx := strings.Index(address, suffix)
-		if idx > 0 {
-			// Find start of district name (after 縣 or 市)
-			start := 0
-			if countyIdx := strings.Index(address, "縣"); countyIdx >= 0 && countyIdx < idx {
-				start = countyIdx + len("縣")
-			} else if cityIdx := strings.Index(address, "市"); cityIdx >= 0 && cityIdx < idx {
-				start = cityIdx + len("市")
-			}
-			return address[start : idx+len(suffix)]
-		}
-	}
-	return ""
-}
-
 // buildFullAddress creates a full address string for geocoding
 func buildFullAddress(address, countyName string) string {
 	// Clean up address
@@ -32,6 +32,16 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// Normalize free-text classes and link students to Class records
+	if err := services.MigrateStudentClasses(db); err != nil {
+		log.Fatal("Failed to migrate student classes:", err)
+	}
+
+	// Check the font of the PDF reports
+	if err := services.CheckReportFont(); err != nil {
+		log.Fatal("Failed to load report font:", err)
+	}
+
 	// Initialize Redis
 	if err := config.InitRedis(); err != nil {
 		log.Fatal("Failed to initialize Redis:", err)
@@ -62,6 +72,8 @@ func main() {
 	{
 		countyRoutes.GET("/statistics", countyHandler.GetAllCountyStatistics)
 		countyRoutes.GET("/:countyName/statistics", countyHandler.GetCountyStatistics)
+		countyRoutes.GET("/:countyName/districts/statistics", countyHandler.GetDistrictStatistics)
+		countyRoutes.GET("/:countyName/districts/:district/statistics", countyHandler.GetSingleDistrictStatistics)
 	}
 
 	// School routes
@@ -80,6 +92,24 @@ func main() {
 		schoolRoutes.DELETE("/:id", schoolHandler.Delete)
 	}
 
+	// Class routes
+	classService := services.NewClassService(db)
+	classHandler := handlers.NewClassHandler(classService)
+
+	classRoutes := v1.Group("/classes")
+	// TODO: Add auth middleware when available from 001-user-auth
+	// classRoutes.Use(authMiddleware())
+	{
+		classRoutes.GET("", classHandler.List)
+		classRoutes.GET("/:id", classHandler.Get)
+		classRoutes.GET("/:id/students", classHandler.GetRoster)
+		classRoutes.POST("", classHandler.Create)
+		classRoutes.POST("/:id/students", classHandler.AssignStudents)
+		classRoutes.PUT("/:id", classHandler.Update)
+		classRoutes.DELETE("/:id", classHandler.Delete)
+		classRoutes.DELETE("/:id/students/:studentId", classHandler.RemoveStudent)
+	}
+
 	// Student routes
 	studentService := services.NewStudentService(db)
 	studentHandler := handlers.NewStudentHandler(studentService)
@@ -89,9 +119,16 @@ func main() {
 	// studentRoutes.Use(authMiddleware())
 	{
 		studentRoutes.GET("", studentHandler.List)
+		studentRoutes.GET("/duplicates", studentHandler.FindDuplicates)  // Must be before /:id
+		studentRoutes.GET("/lookup-number", studentHandler.LookupNumber) // Must be before /:id
+		studentRoutes.POST("/merges/:mergeId/revert", studentHandler.RevertMerge)
 		studentRoutes.GET("/:id", studentHandler.Get)
 		studentRoutes.GET("/:id/records", studentHandler.GetWithRecords)
+		studentRoutes.GET("/:id/history", studentHandler.GetHistory)
+		studentRoutes.GET("/:id/number-history", studentHandler.GetNumberHistory)
+		studentRoutes.GET("/:id/merges", studentHandler.ListMerges)
 		studentRoutes.POST("", studentHandler.Create)
+		studentRoutes.POST("/:id/merge", studentHandler.Merge)
 		studentRoutes.PUT("/:id", studentHandler.Update)
 		studentRoutes.DELETE("/:id", studentHandler.Delete)
 	}
@@ -132,7 +169,7 @@ func main() {
 	// ★★★ 已移除 Analysis 相關程式碼 ★★★
 
 	// Import routes (Excel batch import)
-	importService := services.NewImportService(db)
+	importService := services.NewImportService(db, config.GetRedisClient())
 	templateService := services.NewTemplateService(db)
 	importHandler := handlers.NewImportHandler(importService, templateService)
 
@@ -143,6 +180,13 @@ func main() {
 		// Template downloads
 		importRoutes.GET("/templates/students", importHandler.DownloadStudentTemplate)
 		importRoutes.GET("/templates/records", importHandler.DownloadRecordsTemplate)
+		importRoutes.GET("/templates/schools", importHandler.DownloadSchoolTemplate)
+
+		// Column detection and saved column mappings
+		importRoutes.POST("/columns/detect", importHandler.DetectColumns)
+		importRoutes.GET("/column-mappings", importHandler.ListColumnMappings)
+		importRoutes.PUT("/column-mappings", importHandler.SaveColumnMapping)
+		importRoutes.DELETE("/column-mappings/:mapping_id", importHandler.DeleteColumnMapping)
 
 		// Student import
 		importRoutes.POST("/students/preview", importHandler.PreviewStudentImport)
@@ -152,8 +196,40 @@ func main() {
 		importRoutes.POST("/records/preview", importHandler.PreviewRecordsImport)
 		importRoutes.POST("/records/execute", importHandler.ExecuteRecordsImport)
 
-		// Cancel preview
+		// School import
+		importRoutes.POST("/schools/preview", importHandler.PreviewSchoolImport)
+		importRoutes.POST("/schools/execute", importHandler.ExecuteSchoolImport)
+
+		// Cancel preview / download annotated file
 		importRoutes.DELETE("/preview/:preview_id", importHandler.CancelPreview)
+		importRoutes.GET("/preview/:preview_id/annotated", importHandler.DownloadAnnotatedWorkbook)
+		importRoutes.PATCH("/preview/:preview_id/rows/:row_number", importHandler.PatchPreviewRow)
+		importRoutes.PUT("/preview/:preview_id/rows/:row_number/student", importHandler.SelectStudent)
+
+		// Background import jobs
+		importRoutes.GET("/jobs/:job_id", importHandler.GetJob)
+		importRoutes.GET("/jobs/:job_id/events", importHandler.StreamJob)
+		importRoutes.DELETE("/jobs/:job_id", importHandler.CancelJob)
+
+		// Import history
+		importRoutes.GET("/batches", importHandler.ListBatches)
+		importRoutes.GET("/batches/:batch_id", importHandler.GetBatch)
+		importRoutes.POST("/batches/:batch_id/undo", importHandler.UndoBatch)
+	}
+
+	// Export routes (students and sport records as xlsx or CSV)
+	exportService := services.NewExportService(db)
+	exportHandler := handlers.NewExportHandler(exportService)
+
+	exportRoutes := v1.Group("/export")
+	// TODO: Add auth middleware when available from 001-user-auth
+	// exportRoutes.Use(authMiddleware())
+	{
+		exportRoutes.GET("/students", exportHandler.ExportStudents)
+		exportRoutes.GET("/records", exportHandler.ExportRecords)
+		exportRoutes.GET("/moe", exportHandler.ExportMOE)
+		exportRoutes.GET("/moe/layout", exportHandler.MOELayout)
+		exportRoutes.GET("/moe/validate", exportHandler.ValidateMOE)
 	}
 
 	// ========== 🎯 在這裡加入統計路由 ==========
@@ -169,14 +245,52 @@ func main() {
 		statisticsRoutes.GET("/grade-comparison/:studentId", statisticsHandler.GetGradeComparison)
 		statisticsRoutes.GET("/county-comparison/:studentId", statisticsHandler.GetCountyComparison)
 		statisticsRoutes.GET("/county-sport-averages/:countyName", statisticsHandler.GetCountySportAverages)
+		statisticsRoutes.GET("/district-sport-averages/:countyName/:district", statisticsHandler.GetDistrictSportAverages)
+		statisticsRoutes.GET("/group-sport-averages", statisticsHandler.GetGroupSportAverages)
 		statisticsRoutes.GET("/national-averages", statisticsHandler.GetNationalAverages)
 		statisticsRoutes.POST("/national-averages/calculate", statisticsHandler.CalculateNationalAverages)
+
 		statisticsRoutes.GET("/school-champions", statisticsHandler.GetSchoolChampions)
 		statisticsRoutes.GET("/top-schools", statisticsHandler.GetAllTopSchools)
 		statisticsRoutes.GET("/top-schools/:sportTypeId", statisticsHandler.GetTopSchoolsBySport)
+
+		// 官方常模
+		statisticsRoutes.GET("/norms", statisticsHandler.ListNormTables)
+		statisticsRoutes.POST("/norms/import", statisticsHandler.ImportNormTable)
+		statisticsRoutes.GET("/norms/:id", statisticsHandler.GetNormTable)
+		statisticsRoutes.PUT("/norms/:id/activate", statisticsHandler.ActivateNormTable)
+		statisticsRoutes.GET("/norm-source", statisticsHandler.GetNormSource)
+		statisticsRoutes.PUT("/norm-source", statisticsHandler.SetNormSource)
 	}
 	// ========== 統計路由結束 ==========
 
+	// Open data routes (GeoJSON and CSV files for GIS tools)
+	openDataService := services.NewOpenDataService(db, statisticsService, countyService)
+	openDataHandler := handlers.NewOpenDataHandler(openDataService)
+
+	openDataRoutes := v1.Group("/open-data")
+	{
+		openDataRoutes.GET("/datapackage.json", openDataHandler.Package)
+		openDataRoutes.GET("/schools.geojson", openDataHandler.SchoolsGeoJSON)
+		openDataRoutes.GET("/counties.geojson", openDataHandler.CountiesGeoJSON)
+		openDataRoutes.GET("/schools.csv", openDataHandler.SchoolsCSV)
+		openDataRoutes.GET("/counties.csv", openDataHandler.CountiesCSV)
+	}
+
+	// Report routes (printable report cards and summary workbooks)
+	reportCardService := services.NewReportCardService(db, statisticsService, sportRecordService)
+	schoolSummaryService := services.NewSchoolSummaryService(db, statisticsService)
+	reportHandler := handlers.NewReportHandler(reportCardService, schoolSummaryService)
+
+	reportRoutes := v1.Group("/reports")
+	// TODO: Add auth middleware when available from 001-user-auth
+	// reportRoutes.Use(authMiddleware())
+	{
+		reportRoutes.GET("/students/:id/report-card", reportHandler.StudentReportCard)
+		reportRoutes.GET("/report-cards", reportHandler.ClassReportCards)
+		reportRoutes.GET("/schools/:id/annual-summary", reportHandler.SchoolAnnualSummary)
+	}
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
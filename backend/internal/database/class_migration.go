@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MergeDuplicateClasses merges classes sharing a school, academic year, grade
+// and name, which concurrent imports could create before the class index was
+// unique, and then makes the index unique. Students of the duplicates move to
+// the kept class: the oldest live one, or the oldest when all are deleted.
+func MergeDuplicateClasses(db *gorm.DB) error {
+	type classKey struct {
+		SchoolID     uint
+		AcademicYear int
+		Grade        int
+		Name         string
+	}
+	var keys []classKey
+	err := db.Unscoped().Model(&models.Class{}).
+		Select("school_id, academic_year, grade, name").
+		Group("school_id, academic_year, grade, name").
+		Having("COUNT(*) > 1").
+		Scan(&keys).Error
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate classes: %w", err)
+	}
+
+	merged := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			var classes []models.Class
+			err := tx.Unscoped().
+				Where("school_id = ? AND academic_year = ? AND grade = ? AND name = ?",
+					key.SchoolID, key.AcademicYear, key.Grade, key.Name).
+				Order("deleted_at IS NOT NULL, id").
+				Find(&classes).Error
+			if err != nil {
+				return fmt.Errorf("failed to load duplicate classes: %w", err)
+			}
+			if len(classes) < 2 {
+				continue
+			}
+
+			kept := classes[0]
+			duplicateIDs := make([]uint, 0, len(classes)-1)
+			for _, class := range classes[1:] {
+				duplicateIDs = append(duplicateIDs, class.ID)
+			}
+
+			if err := tx.Unscoped().Model(&models.Student{}).
+				Where("class_id IN ?", duplicateIDs).
+				Update("class_id", kept.ID).Error; err != nil {
+				return fmt.Errorf("failed to move students to class %d: %w", kept.ID, err)
+			}
+			if err := tx.Unscoped().Where("id IN ?", duplicateIDs).Delete(&models.Class{}).Error; err != nil {
+				return fmt.Errorf("failed to remove duplicate classes: %w", err)
+			}
+			merged += len(duplicateIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if merged > 0 {
+		fmt.Printf("✓ Merged %d duplicate classes\n", merged)
+	}
+
+	return ensureUniqueClassIndex(db)
+}
+
+// ensureUniqueClassIndex recreates the class index as unique on databases
+// migrated before it was; AutoMigrate only creates missing indexes
+func ensureUniqueClassIndex(db *gorm.DB) error {
+	const name = "idx_school_year_grade_class"
+
+	indexes, err := db.Migrator().GetIndexes(&models.Class{})
+	if err != nil {
+		return fmt.Errorf("failed to read class indexes: %w", err)
+	}
+	for _, index := range indexes {
+		if index.Name() != name {
+			continue
+		}
+		if unique, ok := index.Unique(); !ok || unique {
+			return nil
+		}
+		if err := db.Migrator().DropIndex(&models.Class{}, name); err != nil {
+			return fmt.Errorf("failed to drop class index: %w", err)
+		}
+		break
+	}
+
+	if err := db.Migrator().CreateIndex(&models.Class{}, name); err != nil {
+		return fmt.Errorf("failed to create unique class index: %w", err)
+	}
+	return nil
+}
@@ -16,10 +16,19 @@ func Migrate(db *gorm.DB) error {
 	err := db.AutoMigrate(
 		&models.School{},
 		&models.SportType{},
+		&models.Class{},
 		&models.Student{},
 		&models.SportRecord{},
 		&models.SportRecordAudit{},
 		&models.NationalAverage{},
+		&models.NormTable{},
+		&models.NormEntry{},
+		&models.AppSetting{},
+		&models.StudentAudit{},
+		&models.StudentMerge{},
+		&models.StudentNumberHistory{},
+		&models.ImportBatch{},
+		&models.ImportColumnMapping{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
@@ -35,6 +44,21 @@ func Migrate(db *gorm.DB) error {
 		}
 	}
 
+	// Infer the level of schools created before it was recorded
+	if err := BackfillSchoolLevels(db); err != nil {
+		return fmt.Errorf("failed to backfill school levels: %w", err)
+	}
+
+	// Extract the district of schools from their addresses
+	if err := BackfillSchoolDistricts(db); err != nil {
+		return fmt.Errorf("failed to backfill school districts: %w", err)
+	}
+
+	// Merge duplicate classes so the class index can be unique
+	if err := MergeDuplicateClasses(db); err != nil {
+		return fmt.Errorf("failed to merge duplicate classes: %w", err)
+	}
+
 	fmt.Println("✓ Database migrations completed successfully")
 	return nil
 }
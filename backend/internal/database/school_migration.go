@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// BackfillSchoolLevels fills in the level of schools that have none, inferred
+// from the school name. Schools whose level cannot be inferred are left empty.
+func BackfillSchoolLevels(db *gorm.DB) error {
+	var schools []models.School
+	if err := db.Where("level IS NULL OR level = ''").Find(&schools).Error; err != nil {
+		return fmt.Errorf("failed to load schools: %w", err)
+	}
+
+	updated := 0
+	for _, school := range schools {
+		level := models.InferSchoolLevel(school.Name)
+		if level == "" {
+			continue
+		}
+		if err := db.Model(&models.School{}).
+			Where("id = ?", school.ID).
+			Update("level", level).Error; err != nil {
+			return fmt.Errorf("failed to update level of school %d: %w", school.ID, err)
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		fmt.Printf("✓ Backfilled level for %d schools\n", updated)
+	}
+	return nil
+}
+
+// BackfillSchoolDistricts fills in the district of schools that have none,
+// extracted from the address against the county's official district list
+func BackfillSchoolDistricts(db *gorm.DB) error {
+	var schools []models.School
+	if err := db.Where("district IS NULL OR district = ''").Find(&schools).Error; err != nil {
+		return fmt.Errorf("failed to load schools: %w", err)
+	}
+
+	updated := 0
+	for _, school := range schools {
+		district := models.ExtractDistrict(school.CountyName, school.Address)
+		if district == "" {
+			continue
+		}
+		if err := db.Model(&models.School{}).
+			Where("id = ?", school.ID).
+			Update("district", district).Error; err != nil {
+			return fmt.Errorf("failed to update district of school %d: %w", school.ID, err)
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		fmt.Printf("✓ Backfilled district for %d schools\n", updated)
+	}
+	return nil
+}
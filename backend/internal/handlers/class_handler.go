@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wei979/ICACP/backend/internal/models"
+	"github.com/wei979/ICACP/backend/internal/services"
+)
+
+// ClassHandler handles HTTP requests for class endpoints
+type ClassHandler struct {
+	service *services.ClassService
+}
+
+// NewClassHandler creates a new ClassHandler instance
+func NewClassHandler(service *services.ClassService) *ClassHandler {
+	return &ClassHandler{
+		service: service,
+	}
+}
+
+// List handles GET /api/v1/classes
+// Returns classes filtered by school, academic year and grade
+func (h *ClassHandler) List(c *gin.Context) {
+	var params models.ClassSearchParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_PARAMS", "無效的查詢參數")
+		return
+	}
+
+	classes, err := h.service.List(&params)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得班級列表")
+		return
+	}
+
+	response := models.ClassListResponse{}
+	response.Data.Classes = classes
+	response.Data.Total = len(classes)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Get handles GET /api/v1/classes/:id
+// Returns a single class by ID
+func (h *ClassHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	class, err := h.service.GetByID(uint(id))
+	if err != nil {
+		if err.Error() == "class not found" {
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "班級不存在")
+			return
+		}
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得班級資料")
+		return
+	}
+
+	response := models.ClassResponse{}
+	response.Data.Class = *class
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Create handles POST /api/v1/classes
+// Creates a new class
+func (h *ClassHandler) Create(c *gin.Context) {
+	var req models.CreateClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_REQUEST", "請求資料格式錯誤")
+		return
+	}
+
+	class, err := h.service.Create(&req)
+	if err != nil {
+		switch err.Error() {
+		case "學校不存在":
+			h.sendErrorResponse(c, http.StatusBadRequest, "SCHOOL_NOT_FOUND", err.Error())
+		case "班級名稱不能為空":
+			h.sendErrorResponse(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		case "此班級已存在":
+			h.sendErrorResponse(c, http.StatusConflict, "DUPLICATE_CLASS", err.Error())
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法建立班級")
+		}
+		return
+	}
+
+	response := models.ClassResponse{}
+	response.Data.Class = *class
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// Update handles PUT /api/v1/classes/:id
+// Updates an existing class
+func (h *ClassHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	var req models.UpdateClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_REQUEST", "請求資料格式錯誤")
+		return
+	}
+
+	class, err := h.service.Update(uint(id), &req)
+	if err != nil {
+		switch err.Error() {
+		case "class not found":
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "班級不存在")
+		case "班級名稱不能為空":
+			h.sendErrorResponse(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		case "此班級已存在":
+			h.sendErrorResponse(c, http.StatusConflict, "DUPLICATE_CLASS", err.Error())
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法更新班級")
+		}
+		return
+	}
+
+	response := models.ClassResponse{}
+	response.Data.Class = *class
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete handles DELETE /api/v1/classes/:id
+// Soft deletes an empty class
+func (h *ClassHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		switch err.Error() {
+		case "class not found":
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "班級不存在")
+		case "班級仍有學生，無法刪除":
+			h.sendErrorResponse(c, http.StatusConflict, "CLASS_NOT_EMPTY", err.Error())
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法刪除班級")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"message": "班級已成功刪除",
+		},
+	})
+}
+
+// GetRoster handles GET /api/v1/classes/:id/students
+// Returns the students of a class
+func (h *ClassHandler) GetRoster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	class, students, err := h.service.GetRoster(uint(id))
+	if err != nil {
+		if err.Error() == "class not found" {
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "班級不存在")
+			return
+		}
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得班級名單")
+		return
+	}
+
+	h.sendRoster(c, class, students)
+}
+
+// AssignStudents handles POST /api/v1/classes/:id/students
+// Adds students to a class roster
+func (h *ClassHandler) AssignStudents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	var req models.AssignStudentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_REQUEST", "請求資料格式錯誤")
+		return
+	}
+
+	class, students, err := h.service.AssignStudents(uint(id), &req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case msg == "class not found":
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "班級不存在")
+		case strings.HasPrefix(msg, "找不到 ID 為"):
+			h.sendErrorResponse(c, http.StatusNotFound, "STUDENT_NOT_FOUND", msg)
+		case strings.HasPrefix(msg, "班級人數已達上限"):
+			h.sendErrorResponse(c, http.StatusConflict, "CLASS_FULL", msg)
+		case strings.HasPrefix(msg, "學生 "):
+			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_STUDENT", msg)
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法加入學生")
+		}
+		return
+	}
+
+	h.sendRoster(c, class, students)
+}
+
+// RemoveStudent handles DELETE /api/v1/classes/:id/students/:studentId
+// Removes a student from a class roster
+func (h *ClassHandler) RemoveStudent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的班級 ID")
+		return
+	}
+
+	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	if err := h.service.RemoveStudent(uint(id), uint(studentID)); err != nil {
+		if err.Error() == "學生不在此班級" {
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+			return
+		}
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法移除學生")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"message": "學生已移出班級",
+		},
+	})
+}
+
+// sendRoster writes a class roster response
+func (h *ClassHandler) sendRoster(c *gin.Context, class *models.Class, students []models.Student) {
+	response := models.ClassRosterResponse{}
+	response.Data.Class = *class
+	response.Data.Students = students
+	response.Data.Total = len(students)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Helper function to send error responses
+func (h *ClassHandler) sendErrorResponse(c *gin.Context, statusCode int, errorCode string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"code":    errorCode,
+			"message": message,
+			"status":  statusCode,
+		},
+	})
+}
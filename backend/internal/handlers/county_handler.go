@@ -84,6 +84,76 @@ func (h *CountyHandler) GetCountyStatistics(c *gin.Context) {
 	})
 }
 
+// GetDistrictStatistics handles GET /api/v1/counties/:countyName/districts/statistics
+// Returns statistics for every official district of a county/city
+func (h *CountyHandler) GetDistrictStatistics(c *gin.Context) {
+	countyName := c.Param("countyName")
+	if !h.validateCountyName(countyName) {
+		h.sendErrorResponse(
+			c,
+			http.StatusNotFound,
+			"COUNTY_NOT_FOUND",
+			"Invalid county name: "+countyName,
+		)
+		return
+	}
+
+	stats, err := h.service.GetDistrictStatistics(countyName)
+	if err != nil {
+		h.sendErrorResponse(
+			c,
+			http.StatusInternalServerError,
+			"INTERNAL_ERROR",
+			"Unable to retrieve district statistics",
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AllDistrictStatisticsResponse{
+		Data: *stats,
+	})
+}
+
+// GetSingleDistrictStatistics handles GET /api/v1/counties/:countyName/districts/:district/statistics
+// Returns statistics for a specific district of a county/city
+func (h *CountyHandler) GetSingleDistrictStatistics(c *gin.Context) {
+	countyName := c.Param("countyName")
+	district := c.Param("district")
+	if !h.validateCountyName(countyName) {
+		h.sendErrorResponse(
+			c,
+			http.StatusNotFound,
+			"COUNTY_NOT_FOUND",
+			"Invalid county name: "+countyName,
+		)
+		return
+	}
+	if !models.IsValidDistrict(countyName, district) {
+		h.sendErrorResponse(
+			c,
+			http.StatusNotFound,
+			"DISTRICT_NOT_FOUND",
+			"Invalid district: "+district,
+		)
+		return
+	}
+
+	stats, err := h.service.GetSingleDistrictStatistics(countyName, district)
+	if err != nil {
+		h.sendErrorResponse(
+			c,
+			http.StatusInternalServerError,
+			"INTERNAL_ERROR",
+			"Unable to retrieve district statistics",
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.DistrictStatisticsResponse{
+		Data: *stats,
+	})
+}
+
 // Helper function to send error responses
 func (h *CountyHandler) sendErrorResponse(c *gin.Context, statusCode int, errorCode string, message string) {
 	c.JSON(statusCode, gin.H{
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wei979/ICACP/backend/internal/models"
+	"github.com/wei979/ICACP/backend/internal/services"
+)
+
+// ExportHandler handles HTTP requests for data exports
+type ExportHandler struct {
+	service *services.ExportService
+}
+
+// NewExportHandler creates a new ExportHandler instance
+func NewExportHandler(service *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+	}
+}
+
+// ExportStudents handles GET /api/v1/export/students
+// Supports query parameters: school_id, grade, class, class_id, sport_type_id,
+// term, start_date, end_date and format (xlsx or csv)
+func (h *ExportHandler) ExportStudents(c *gin.Context) {
+	var params models.ExportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	export, err := h.service.ExportStudents(&params)
+	if err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	h.stream(c, export)
+}
+
+// ExportRecords handles GET /api/v1/export/records
+// Supports the student export filters plus layout: by_sport (a sheet per sport
+// type for xlsx, one record per row for CSV) or wide (a column per sport type)
+func (h *ExportHandler) ExportRecords(c *gin.Context) {
+	var params models.ExportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	export, err := h.service.ExportRecords(&params)
+	if err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	h.stream(c, export)
+}
+
+// MOELayout handles GET /api/v1/export/moe/layout
+// Returns the columns, units and codes of the MOE upload file
+func (h *ExportHandler) MOELayout(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"columns":       models.MOEIdentityColumns,
+		"items":         models.MOEItems,
+		"cardio_column": models.MOECardioColumn,
+		"cardio_codes":  models.MOECardioCodes,
+		"gender_codes": gin.H{
+			"male":   models.MOEGenderMale,
+			"female": models.MOEGenderFemale,
+		},
+	}})
+}
+
+// ValidateMOE handles GET /api/v1/export/moe/validate
+// Requires query parameter school_id; term defaults to the current term.
+// Lists the rows that miss data the MOE fitness platform requires.
+func (h *ExportHandler) ValidateMOE(c *gin.Context) {
+	var params models.MOEExportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	validation, err := h.service.ValidateMOE(&params)
+	if err != nil {
+		h.sendMOEError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": validation})
+}
+
+// ExportMOE handles GET /api/v1/export/moe
+// Exports a school's results of a term in the MOE upload layout as xlsx or
+// CSV. Responds 422 with the offending rows when mandatory data is missing.
+func (h *ExportHandler) ExportMOE(c *gin.Context) {
+	var params models.MOEExportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendInvalidParams(c, err)
+		return
+	}
+
+	export, err := h.service.ExportMOE(&params)
+	if err != nil {
+		h.sendMOEError(c, err)
+		return
+	}
+
+	h.stream(c, export)
+}
+
+// sendMOEError responds with the error of an MOE export
+func (h *ExportHandler) sendMOEError(c *gin.Context, err error) {
+	var validationErr *services.MOEValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "MOE_VALIDATION_FAILED",
+				"message": err.Error(),
+				"status":  422,
+				"details": validationErr.Validation,
+			},
+		})
+	case err.Error() == "學校不存在":
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": err.Error(),
+				"status":  404,
+			},
+		})
+	case strings.HasPrefix(err.Error(), "查詢"):
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "EXPORT_ERROR",
+				"message": "匯出失敗: " + err.Error(),
+				"status":  500,
+			},
+		})
+	default:
+		h.sendInvalidParams(c, err)
+	}
+}
+
+// stream writes the export as a file download. Once rows have been sent the
+// status can no longer change, so later errors are only logged.
+func (h *ExportHandler) stream(c *gin.Context, export *services.Export) {
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Content-Disposition", "attachment; filename="+export.Filename)
+
+	if err := export.Write(c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "EXPORT_ERROR",
+					"message": "匯出失敗: " + err.Error(),
+					"status":  500,
+				},
+			})
+			return
+		}
+		log.Printf("匯出 %s 中斷: %v", export.Filename, err)
+		c.Abort()
+	}
+}
+
+// sendInvalidParams responds with an invalid export parameters error
+func (h *ExportHandler) sendInvalidParams(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": gin.H{
+			"code":    "INVALID_EXPORT_PARAMS",
+			"message": err.Error(),
+			"status":  400,
+		},
+	})
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wei979/ICACP/backend/internal/models"
@@ -47,7 +50,8 @@ func (h *ImportHandler) DownloadStudentTemplate(c *gin.Context) {
 
 // DownloadRecordsTemplate handles GET /api/v1/import/templates/records
 // Supports optional query parameters: school_id, grade, class
-// If provided, generates template with actual students from that school/grade/class
+// If provided, generates template with actual students from that school/grade/class;
+// school_id alone generates a whole-school workbook with a sheet per class
 func (h *ImportHandler) DownloadRecordsTemplate(c *gin.Context) {
 	var buffer *bytes.Buffer
 	var err error
@@ -86,6 +90,22 @@ func (h *ImportHandler) DownloadRecordsTemplate(c *gin.Context) {
 
 		buffer, err = h.templateService.GenerateRecordsTemplateWithStudents(uint(schoolID), grade, class)
 		filename = "sport-records-template.xlsx"
+	} else if schoolIDStr != "" {
+		// Generate whole-school workbook with a sheet per class
+		schoolID, parseErr := strconv.ParseUint(schoolIDStr, 10, 64)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_SCHOOL_ID",
+					"message": "無效的學校 ID",
+					"status":  400,
+				},
+			})
+			return
+		}
+
+		buffer, err = h.templateService.GenerateSchoolRecordsTemplate(uint(schoolID))
+		filename = "sport-records-template-school.xlsx"
 	} else {
 		// Generate generic template
 		buffer, err = h.templateService.GenerateRecordsTemplate()
@@ -117,7 +137,7 @@ func (h *ImportHandler) PreviewStudentImport(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
 				"code":    "MISSING_FILE",
-				"message": "請上傳 Excel 檔案",
+				"message": "請上傳匯入檔案（.xlsx、.xls、.ods 或 .csv）",
 				"status":  400,
 			},
 		})
@@ -163,8 +183,27 @@ func (h *ImportHandler) PreviewStudentImport(c *gin.Context) {
 		return
 	}
 
+	// Get import mode (create_only, update_only or upsert)
+	mode := models.ImportMode(c.DefaultPostForm("mode", string(models.ImportModeCreateOnly)))
+	if !models.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_MODE",
+				"message": "匯入模式必須是 create_only、update_only 或 upsert",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// Get the column mapping confirmed by the client, if any
+	columns, ok := h.columnMappingOptions(c)
+	if !ok {
+		return
+	}
+
 	// Preview import
-	preview, err := h.service.PreviewStudentImport(file, header.Filename, uint(schoolID))
+	preview, err := h.service.PreviewStudentImport(file, header.Filename, uint(schoolID), mode, columns)
 	if err != nil {
 		// Check if it's a "not found" error
 		if contains(err.Error(), "找不到") {
@@ -205,45 +244,162 @@ func (h *ImportHandler) ExecuteStudentImport(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.ExecuteStudentImport(req.PreviewID, req.IncludeWarnings)
-	if err != nil {
-		// Check error type
-		if contains(err.Error(), "不存在或已過期") {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": gin.H{
-					"code":    "PREVIEW_NOT_FOUND",
-					"message": err.Error(),
-					"status":  404,
-				},
-			})
-			return
-		}
+	// TODO: Get actual user ID from auth context
+	uploadedBy := uint(1) // Placeholder
 
-		if contains(err.Error(), "已被執行") {
-			c.JSON(http.StatusConflict, gin.H{
-				"error": gin.H{
-					"code":    "PREVIEW_ALREADY_EXECUTED",
-					"message": err.Error(),
-					"status":  409,
-				},
-			})
+	if req.Async {
+		job, err := h.service.StartStudentImportJob(&req, uploadedBy)
+		if err != nil {
+			h.sendExecuteError(c, err)
 			return
 		}
+		c.JSON(http.StatusAccepted, gin.H{"data": job})
+		return
+	}
+
+	result, err := h.service.ExecuteStudentImport(&req, uploadedBy)
+	if err != nil {
+		h.sendExecuteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
 
+// DownloadSchoolTemplate handles GET /api/v1/import/templates/schools
+func (h *ImportHandler) DownloadSchoolTemplate(c *gin.Context) {
+	// Generate template
+	buffer, err := h.templateService.GenerateSchoolTemplate()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
-				"code":    "IMPORT_ERROR",
-				"message": err.Error(),
+				"code":    "TEMPLATE_ERROR",
+				"message": "無法產生模板: " + err.Error(),
 				"status":  500,
 			},
 		})
 		return
 	}
 
+	// Set headers for file download
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", "attachment; filename=school-list-template.xlsx")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buffer.Bytes())
+}
+
+// PreviewSchoolImport handles POST /api/v1/import/schools/preview
+func (h *ImportHandler) PreviewSchoolImport(c *gin.Context) {
+	// Get uploaded file
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "MISSING_FILE",
+				"message": "請上傳匯入檔案（.xlsx、.xls、.ods 或 .csv）",
+				"status":  400,
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	// Validate file format
+	if err := h.service.ValidateFileFormat(header.Filename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_FILE_FORMAT",
+				"message": err.Error(),
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// Validate file size
+	if err := h.service.ValidateFileSize(header.Size); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "FILE_TOO_LARGE",
+				"message": err.Error(),
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// Get import mode (create_only, update_only or upsert)
+	mode := models.ImportMode(c.DefaultPostForm("mode", string(models.ImportModeCreateOnly)))
+	if !models.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_MODE",
+				"message": "匯入模式必須是 create_only、update_only 或 upsert",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// Get the column mapping confirmed by the client, if any
+	columns, ok := h.columnMappingOptions(c)
+	if !ok {
+		return
+	}
+
+	// Preview import
+	preview, err := h.service.PreviewSchoolImport(file, header.Filename, mode, columns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "PARSE_ERROR",
+				"message": err.Error(),
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// ExecuteSchoolImport handles POST /api/v1/import/schools/execute
+func (h *ImportHandler) ExecuteSchoolImport(c *gin.Context) {
+	var req models.ExecuteImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供有效的請求資料",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	uploadedBy := uint(1) // Placeholder
+
+	if req.Async {
+		job, err := h.service.StartSchoolImportJob(&req, uploadedBy)
+		if err != nil {
+			h.sendExecuteError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"data": job})
+		return
+	}
+
+	result, err := h.service.ExecuteSchoolImport(&req, uploadedBy)
+	if err != nil {
+		h.sendExecuteError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": result})
 }
 
 // PreviewRecordsImport handles POST /api/v1/import/records/preview
+// Without a grade the upload is previewed as a whole-school workbook
 func (h *ImportHandler) PreviewRecordsImport(c *gin.Context) {
 	// Get uploaded file
 	file, header, err := c.Request.FormFile("file")
@@ -251,7 +407,7 @@ func (h *ImportHandler) PreviewRecordsImport(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
 				"code":    "MISSING_FILE",
-				"message": "請上傳 Excel 檔案",
+				"message": "請上傳匯入檔案（.xlsx、.xls、.ods 或 .csv）",
 				"status":  400,
 			},
 		})
@@ -297,8 +453,25 @@ func (h *ImportHandler) PreviewRecordsImport(c *gin.Context) {
 		return
 	}
 
-	// Get grade
+	// Get the column mapping confirmed by the client, if any
+	columns, ok := h.columnMappingOptions(c)
+	if !ok {
+		return
+	}
+
+	// Without a grade the file is a whole-school workbook with a sheet per class
 	gradeStr := c.PostForm("grade")
+	if gradeStr == "" {
+		preview, err := h.service.PreviewSchoolRecordsImport(file, header.Filename, uint(schoolID), columns)
+		if err != nil {
+			h.sendRecordsPreviewError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": preview})
+		return
+	}
+
+	// Get grade
 	grade, err := strconv.Atoi(gradeStr)
 	if err != nil || grade < 1 || grade > 12 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -315,22 +488,80 @@ func (h *ImportHandler) PreviewRecordsImport(c *gin.Context) {
 	class := c.PostForm("class")
 
 	// Preview import
-	preview, err := h.service.PreviewRecordsImport(file, header.Filename, uint(schoolID), grade, class)
+	preview, err := h.service.PreviewRecordsImport(file, header.Filename, uint(schoolID), grade, class, columns)
 	if err != nil {
-		if contains(err.Error(), "找不到") {
-			c.JSON(http.StatusNotFound, gin.H{
+		h.sendRecordsPreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// columnMappingOptions reads the optional header_row, column_map (a JSON
+// object of field -> zero-based column) and save_mapping form fields of a
+// preview upload. It returns nil options when none are given and false after
+// sending an error response.
+func (h *ImportHandler) columnMappingOptions(c *gin.Context) (*models.ColumnMappingOptions, bool) {
+	headerRowStr := c.PostForm("header_row")
+	columnMapStr := c.PostForm("column_map")
+	save := c.PostForm("save_mapping") == "true"
+	if headerRowStr == "" && columnMapStr == "" && !save {
+		return nil, true
+	}
+
+	options := &models.ColumnMappingOptions{Save: save}
+	if headerRowStr != "" {
+		headerRow, err := strconv.Atoi(headerRowStr)
+		if err != nil || headerRow < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"code":    "SCHOOL_NOT_FOUND",
-					"message": err.Error(),
-					"status":  404,
+					"code":    "INVALID_HEADER_ROW",
+					"message": "標題列必須是大於 0 的整數",
+					"status":  400,
 				},
 			})
-			return
+			return nil, false
+		}
+		options.HeaderRow = headerRow
+	}
+	if columnMapStr != "" {
+		if err := json.Unmarshal([]byte(columnMapStr), &options.ColumnMap); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_COLUMN_MAP",
+					"message": "欄位對應格式不正確",
+					"status":  400,
+				},
+			})
+			return nil, false
 		}
+	}
+	return options, true
+}
+
+// DetectColumns handles POST /api/v1/import/columns/detect
+// Detects the header row of an uploaded file and suggests the column of each
+// field; the client confirms or overrides it and sends it with the preview
+func (h *ImportHandler) DetectColumns(c *gin.Context) {
+	// Get uploaded file
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "MISSING_FILE",
+				"message": "請上傳匯入檔案（.xlsx、.xls、.ods 或 .csv）",
+				"status":  400,
+			},
+		})
+		return
+	}
+	defer file.Close()
 
+	// Validate file format
+	if err := h.service.ValidateFileFormat(header.Filename); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"code":    "PARSE_ERROR",
+				"code":    "INVALID_FILE_FORMAT",
 				"message": err.Error(),
 				"status":  400,
 			},
@@ -338,88 +569,659 @@ func (h *ImportHandler) PreviewRecordsImport(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": preview})
-}
+	// Validate file size
+	if err := h.service.ValidateFileSize(header.Size); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "FILE_TOO_LARGE",
+				"message": err.Error(),
+				"status":  400,
+			},
+		})
+		return
+	}
 
-// ExecuteRecordsImport handles POST /api/v1/import/records/execute
-func (h *ImportHandler) ExecuteRecordsImport(c *gin.Context) {
-	var req models.ExecuteImportRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// Get import type
+	importType := models.ImportType(c.PostForm("type"))
+	if importType != models.ImportTypeStudents && importType != models.ImportTypeRecords &&
+		importType != models.ImportTypeSchools {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"code":    "INVALID_REQUEST",
-				"message": "請提供有效的請求資料",
+				"code":    "INVALID_TYPE",
+				"message": "匯入類型必須是 students、records 或 schools",
 				"status":  400,
 			},
 		})
 		return
 	}
 
-	result, err := h.service.ExecuteRecordsImport(req.PreviewID, req.IncludeWarnings)
-	if err != nil {
-		if contains(err.Error(), "不存在或已過期") {
-			c.JSON(http.StatusNotFound, gin.H{
+	// Get school ID; school imports are not tied to a school
+	var schoolID uint64
+	if importType != models.ImportTypeSchools {
+		schoolID, err = strconv.ParseUint(c.PostForm("school_id"), 10, 64)
+		if err != nil || schoolID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"code":    "PREVIEW_NOT_FOUND",
-					"message": err.Error(),
-					"status":  404,
+					"code":    "INVALID_SCHOOL_ID",
+					"message": "請提供有效的學校 ID",
+					"status":  400,
 				},
 			})
 			return
 		}
+	}
 
-		if contains(err.Error(), "已被執行") {
-			c.JSON(http.StatusConflict, gin.H{
+	detection, err := h.service.DetectColumns(file, header.Filename, uint(schoolID), importType, c.PostForm("sheet"))
+	if err != nil {
+		if contains(err.Error(), "找不到 ID") {
+			c.JSON(http.StatusNotFound, gin.H{
 				"error": gin.H{
-					"code":    "PREVIEW_ALREADY_EXECUTED",
+					"code":    "SCHOOL_NOT_FOUND",
 					"message": err.Error(),
-					"status":  409,
+					"status":  404,
 				},
 			})
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"code":    "IMPORT_ERROR",
+				"code":    "PARSE_ERROR",
 				"message": err.Error(),
-				"status":  500,
+				"status":  400,
 			},
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": result})
+	c.JSON(http.StatusOK, gin.H{"data": detection})
 }
 
-// CancelPreview handles DELETE /api/v1/import/preview/:preview_id
-func (h *ImportHandler) CancelPreview(c *gin.Context) {
-	previewID := c.Param("preview_id")
-	if previewID == "" {
+// ListColumnMappings handles GET /api/v1/import/column-mappings?school_id=
+func (h *ImportHandler) ListColumnMappings(c *gin.Context) {
+	schoolID, err := strconv.ParseUint(c.Query("school_id"), 10, 32)
+	if err != nil || schoolID == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"code":    "INVALID_PREVIEW_ID",
-				"message": "請提供預覽 ID",
+				"code":    "INVALID_SCHOOL_ID",
+				"message": "請提供有效的學校 ID",
 				"status":  400,
 			},
 		})
 		return
 	}
 
-	if !h.service.CancelPreview(previewID) {
-		c.JSON(http.StatusNotFound, gin.H{
+	mappings, err := h.service.ListColumnMappings(uint(schoolID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
-				"code":    "PREVIEW_NOT_FOUND",
-				"message": "預覽資料不存在或已過期",
-				"status":  404,
+				"code":    "INTERNAL_ERROR",
+				"message": "無法取得欄位對應",
+				"status":  500,
 			},
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"message": "預覽已取消",
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"mappings": mappings}})
+}
+
+// SaveColumnMapping handles PUT /api/v1/import/column-mappings
+// Creates or replaces the saved column mapping of a school for an import type
+func (h *ImportHandler) SaveColumnMapping(c *gin.Context) {
+	var req models.SaveColumnMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供有效的請求資料",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	mapping, err := h.service.SaveColumnMapping(&req)
+	if err != nil {
+		if contains(err.Error(), "無效") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_COLUMN_MAP",
+					"message": err.Error(),
+					"status":  400,
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": err.Error(),
+				"status":  500,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"mapping": mapping}})
+}
+
+// DeleteColumnMapping handles DELETE /api/v1/import/column-mappings/:mapping_id
+func (h *ImportHandler) DeleteColumnMapping(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("mapping_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "無效的欄位對應 ID",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	if err := h.service.DeleteColumnMapping(uint(id)); err != nil {
+		if err.Error() == "column mapping not found" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "欄位對應不存在",
+					"status":  404,
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "無法刪除欄位對應",
+				"status":  500,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"message": "欄位對應已刪除"}})
+}
+
+// sendRecordsPreviewError maps records preview errors to HTTP responses
+func (h *ImportHandler) sendRecordsPreviewError(c *gin.Context, err error) {
+	if contains(err.Error(), "找不到 ID") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "SCHOOL_NOT_FOUND",
+				"message": err.Error(),
+				"status":  404,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": gin.H{
+			"code":    "PARSE_ERROR",
+			"message": err.Error(),
+			"status":  400,
+		},
+	})
+}
+
+// ExecuteRecordsImport handles POST /api/v1/import/records/execute
+func (h *ImportHandler) ExecuteRecordsImport(c *gin.Context) {
+	var req models.ExecuteImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供有效的請求資料",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	uploadedBy := uint(1) // Placeholder
+
+	if req.Async {
+		job, err := h.service.StartRecordsImportJob(&req, uploadedBy)
+		if err != nil {
+			h.sendExecuteError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"data": job})
+		return
+	}
+
+	result, err := h.service.ExecuteRecordsImport(&req, uploadedBy)
+	if err != nil {
+		h.sendExecuteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// CancelPreview handles DELETE /api/v1/import/preview/:preview_id
+func (h *ImportHandler) CancelPreview(c *gin.Context) {
+	previewID := c.Param("preview_id")
+	if previewID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_PREVIEW_ID",
+				"message": "請提供預覽 ID",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	if !h.service.CancelPreview(previewID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "PREVIEW_NOT_FOUND",
+				"message": "預覽資料不存在或已過期",
+				"status":  404,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"message": "預覽已取消",
+		},
+	})
+}
+
+// PatchPreviewRow handles PATCH /api/v1/import/preview/:preview_id/rows/:row_number
+// Changes values of a preview row and revalidates it, so small mistakes can be
+// fixed without uploading the file again
+func (h *ImportHandler) PatchPreviewRow(c *gin.Context) {
+	rowNumber, ok := previewRowNumber(c)
+	if !ok {
+		return
+	}
+
+	var req models.PatchPreviewRowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供有效的請求資料",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	editedBy := uint(1) // Placeholder
+
+	preview, err := h.service.PatchPreviewRow(c.Param("preview_id"), rowNumber, &req, editedBy)
+	if err != nil {
+		sendPreviewRowError(c, err, "PATCH_ROW_FAILED")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// SelectStudent handles PUT /api/v1/import/preview/:preview_id/rows/:row_number/student
+// Assigns a student (usually a suggested one) to a records row that matched no student
+func (h *ImportHandler) SelectStudent(c *gin.Context) {
+	rowNumber, ok := previewRowNumber(c)
+	if !ok {
+		return
+	}
+
+	var req models.SelectStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供有效的請求資料",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	preview, err := h.service.SelectStudent(c.Param("preview_id"), rowNumber, &req)
+	if err != nil {
+		sendPreviewRowError(c, err, "SELECT_STUDENT_FAILED")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// previewRowNumber parses the row number of a preview row route, answering
+// 400 when it is invalid
+func previewRowNumber(c *gin.Context) (int, bool) {
+	rowNumber, err := strconv.Atoi(c.Param("row_number"))
+	if err != nil || rowNumber < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ROW_NUMBER",
+				"message": "列號必須是正整數",
+				"status":  400,
+			},
+		})
+		return 0, false
+	}
+	return rowNumber, true
+}
+
+// sendPreviewRowError maps an error from changing a preview row to a response
+func sendPreviewRowError(c *gin.Context, err error, code string) {
+	status := http.StatusBadRequest
+	switch {
+	case contains(err.Error(), "不存在或已過期"):
+		status = http.StatusNotFound
+		code = "PREVIEW_NOT_FOUND"
+	case contains(err.Error(), "已被執行"):
+		status = http.StatusConflict
+		code = "PREVIEW_ALREADY_EXECUTED"
+	}
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": err.Error(),
+			"status":  status,
+		},
+	})
+}
+
+// DownloadAnnotatedWorkbook handles GET /api/v1/import/preview/:preview_id/annotated
+// Returns the uploaded file with problem cells highlighted and commented
+func (h *ImportHandler) DownloadAnnotatedWorkbook(c *gin.Context) {
+	preview := h.service.GetPreview(c.Param("preview_id"))
+	if preview == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "PREVIEW_NOT_FOUND",
+				"message": "預覽資料不存在或已過期",
+				"status":  404,
+			},
+		})
+		return
+	}
+
+	buffer, err := h.templateService.GenerateAnnotatedWorkbook(preview)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "ANNOTATION_ERROR",
+				"message": "無法產生檢查結果檔案: " + err.Error(),
+				"status":  500,
+			},
+		})
+		return
+	}
+
+	// Set headers for file download
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", "attachment; filename=import-check-result.xlsx")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buffer.Bytes())
+}
+
+// GetJob handles GET /api/v1/import/jobs/:job_id
+// Returns the status and progress of a background import job
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	job, ok := h.service.GetJob(c.Param("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "JOB_NOT_FOUND",
+				"message": "匯入工作不存在",
+				"status":  404,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// CancelJob handles DELETE /api/v1/import/jobs/:job_id
+// Cancels a running import job; its changes are rolled back
+func (h *ImportHandler) CancelJob(c *gin.Context) {
+	if err := h.service.CancelJob(c.Param("job_id")); err != nil {
+		status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+		switch err.Error() {
+		case "匯入工作不存在":
+			status, code = http.StatusNotFound, "JOB_NOT_FOUND"
+		case "匯入工作已結束，無法取消":
+			status, code = http.StatusConflict, "JOB_FINISHED"
+		}
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"code":    code,
+				"message": err.Error(),
+				"status":  status,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data": gin.H{
+			"message": "已要求取消匯入",
+		},
+	})
+}
+
+// StreamJob handles GET /api/v1/import/jobs/:job_id/events
+// Streams job progress as Server-Sent Events until the job finishes
+func (h *ImportHandler) StreamJob(c *gin.Context) {
+	updates, unsubscribe, ok := h.service.SubscribeJob(c.Param("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "JOB_NOT_FOUND",
+				"message": "匯入工作不存在",
+				"status":  404,
+			},
+		})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				// The job expired while it was followed
+				return false
+			}
+			if job.Status.IsFinished() {
+				c.SSEvent("done", job)
+				return false
+			}
+			c.SSEvent("progress", job)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// ListBatches handles GET /api/v1/import/batches
+// Returns the import history filtered by school and type
+func (h *ImportHandler) ListBatches(c *gin.Context) {
+	var params models.ImportBatchSearchParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_PARAMS",
+				"message": "無效的查詢參數",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	batches, pagination, err := h.service.ListBatches(&params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "無法取得匯入紀錄",
+				"status":  500,
+			},
+		})
+		return
+	}
+
+	response := models.ImportBatchListResponse{}
+	response.Data.Batches = batches
+	response.Data.Pagination = *pagination
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBatch handles GET /api/v1/import/batches/:batch_id
+// Returns an import batch with the IDs it created
+func (h *ImportHandler) GetBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("batch_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "無效的匯入批次 ID",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	batch, err := h.service.GetBatch(uint(id))
+	if err != nil {
+		h.sendBatchError(c, err, "無法取得匯入紀錄")
+		return
+	}
+
+	response := models.ImportBatchResponse{}
+	response.Data.Batch = *batch
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UndoBatch handles POST /api/v1/import/batches/:batch_id/undo
+// Removes everything an import batch created
+func (h *ImportHandler) UndoBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("batch_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "無效的匯入批次 ID",
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	undoneBy := uint(1) // Placeholder
+
+	batch, err := h.service.UndoBatch(uint(id), undoneBy)
+	if err != nil {
+		h.sendBatchError(c, err, "無法復原匯入")
+		return
+	}
+
+	response := models.ImportBatchResponse{}
+	response.Data.Batch = *batch
+
+	c.JSON(http.StatusOK, response)
+}
+
+// sendBatchError maps errors from import batch operations to responses
+func (h *ImportHandler) sendBatchError(c *gin.Context, err error, fallback string) {
+	msg := err.Error()
+	switch {
+	case msg == "import batch not found":
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "BATCH_NOT_FOUND",
+				"message": "匯入紀錄不存在",
+				"status":  404,
+			},
+		})
+	case msg == "此匯入批次已復原":
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "BATCH_ALREADY_UNDONE",
+				"message": msg,
+				"status":  409,
+			},
+		})
+	case strings.HasPrefix(msg, "此批次"):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "BATCH_MODIFIED",
+				"message": msg,
+				"status":  409,
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": fallback,
+				"status":  500,
+			},
+		})
+	}
+}
+
+// sendExecuteError maps errors from executing a preview to responses
+func (h *ImportHandler) sendExecuteError(c *gin.Context, err error) {
+	if contains(err.Error(), "不存在或已過期") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "PREVIEW_NOT_FOUND",
+				"message": err.Error(),
+				"status":  404,
+			},
+		})
+		return
+	}
+
+	if contains(err.Error(), "無效的重複記錄處理方式") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_DUPLICATE_POLICY",
+				"message": err.Error(),
+				"status":  400,
+			},
+		})
+		return
+	}
+
+	if contains(err.Error(), "已被執行") {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "PREVIEW_ALREADY_EXECUTED",
+				"message": err.Error(),
+				"status":  409,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": gin.H{
+			"code":    "IMPORT_ERROR",
+			"message": err.Error(),
+			"status":  500,
 		},
 	})
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wei979/ICACP/backend/internal/models"
+	"github.com/wei979/ICACP/backend/internal/services"
+)
+
+// OpenDataHandler handles HTTP requests for the open data files
+type OpenDataHandler struct {
+	service *services.OpenDataService
+}
+
+// NewOpenDataHandler creates a new OpenDataHandler instance
+func NewOpenDataHandler(service *services.OpenDataService) *OpenDataHandler {
+	return &OpenDataHandler{
+		service: service,
+	}
+}
+
+// Package handles GET /api/v1/open-data/datapackage.json
+// Describes the open data files, their fields and licence
+func (h *OpenDataHandler) Package(c *gin.Context) {
+	pkg, err := h.service.Package()
+	if err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.licenseLink(c, pkg.LicenseURL)
+	c.JSON(http.StatusOK, pkg)
+}
+
+// SchoolsGeoJSON handles GET /api/v1/open-data/schools.geojson
+// Returns the located schools as a GeoJSON FeatureCollection
+func (h *OpenDataHandler) SchoolsGeoJSON(c *gin.Context) {
+	collection, err := h.service.SchoolsGeoJSON(c.Request.Context())
+	if err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.sendGeoJSON(c, collection)
+}
+
+// CountiesGeoJSON handles GET /api/v1/open-data/counties.geojson
+// Returns the county statistics as a GeoJSON FeatureCollection
+func (h *OpenDataHandler) CountiesGeoJSON(c *gin.Context) {
+	collection, err := h.service.CountiesGeoJSON()
+	if err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.sendGeoJSON(c, collection)
+}
+
+// SchoolsCSV handles GET /api/v1/open-data/schools.csv
+// Returns every school with its aggregates as CSV
+func (h *OpenDataHandler) SchoolsCSV(c *gin.Context) {
+	var buffer bytes.Buffer
+	if err := h.service.WriteSchoolsCSV(c.Request.Context(), &buffer); err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.sendCSV(c, "schools.csv", &buffer)
+}
+
+// CountiesCSV handles GET /api/v1/open-data/counties.csv
+// Returns the county statistics as CSV
+func (h *OpenDataHandler) CountiesCSV(c *gin.Context) {
+	var buffer bytes.Buffer
+	if err := h.service.WriteCountiesCSV(&buffer); err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.sendCSV(c, "counties.csv", &buffer)
+}
+
+// sendGeoJSON responds with a feature collection as application/geo+json
+func (h *OpenDataHandler) sendGeoJSON(c *gin.Context, collection *models.FeatureCollection) {
+	body, err := json.Marshal(collection)
+	if err != nil {
+		h.sendError(c, err)
+		return
+	}
+
+	h.licenseLink(c, collection.Metadata.LicenseURL)
+	c.Data(http.StatusOK, "application/geo+json", body)
+}
+
+// sendCSV responds with a CSV file; the licence is linked from the headers
+// since CSV has no place for it
+func (h *OpenDataHandler) sendCSV(c *gin.Context, filename string, buffer *bytes.Buffer) {
+	h.licenseLink(c, services.OpenDataLicenseURL())
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buffer.Bytes())
+}
+
+// licenseLink links the licence of the response (RFC 8288)
+func (h *OpenDataHandler) licenseLink(c *gin.Context, url string) {
+	c.Header("Link", "<"+url+`>; rel="license"`)
+}
+
+// sendError sends a standardized error response
+func (h *OpenDataHandler) sendError(c *gin.Context, err error) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": gin.H{
+			"code":    "OPEN_DATA_ERROR",
+			"message": "無法產生開放資料: " + err.Error(),
+			"status":  http.StatusInternalServerError,
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wei979/ICACP/backend/internal/services"
+)
+
+// ReportHandler handles HTTP requests for printable reports
+type ReportHandler struct {
+	service *services.ReportCardService
+	summary *services.SchoolSummaryService
+}
+
+// NewReportHandler creates a new ReportHandler instance
+func NewReportHandler(service *services.ReportCardService, summary *services.SchoolSummaryService) *ReportHandler {
+	return &ReportHandler{
+		service: service,
+		summary: summary,
+	}
+}
+
+// StudentReportCard handles GET /api/v1/reports/students/:id/report-card
+// Returns the student's fitness report card (體適能成績單) as PDF
+func (h *ReportHandler) StudentReportCard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	buffer, filename, err := h.service.StudentReportCard(c.Request.Context(), uint(id))
+	if err != nil {
+		if err.Error() == "學生不存在" {
+			h.sendError(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+			return
+		}
+		h.sendError(c, http.StatusInternalServerError, "REPORT_ERROR", "無法產生成績單: "+err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", attachmentDisposition(filename))
+	c.Data(http.StatusOK, "application/pdf", buffer.Bytes())
+}
+
+// ClassReportCards handles GET /api/v1/reports/report-cards
+// Requires query parameters school_id, grade and class; format is zip (one
+// PDF per student, default) or pdf (all report cards merged for printing)
+func (h *ReportHandler) ClassReportCards(c *gin.Context) {
+	schoolID, err := strconv.ParseUint(c.Query("school_id"), 10, 32)
+	if err != nil || schoolID == 0 {
+		h.sendError(c, http.StatusBadRequest, "INVALID_SCHOOL_ID", "無效的學校 ID")
+		return
+	}
+	grade, err := strconv.Atoi(c.Query("grade"))
+	if err != nil || grade < 1 {
+		h.sendError(c, http.StatusBadRequest, "INVALID_GRADE", "無效的年級")
+		return
+	}
+	class := c.Query("class")
+	if class == "" {
+		h.sendError(c, http.StatusBadRequest, "INVALID_CLASS", "請指定班級")
+		return
+	}
+
+	batch, err := h.service.ClassReportCards(c.Request.Context(), uint(schoolID), grade, class, c.Query("format"))
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "REPORT_ERROR", err.Error())
+		return
+	}
+
+	c.Header("Content-Type", batch.ContentType)
+	c.Header("Content-Disposition", attachmentDisposition(batch.Filename))
+	if err := batch.Write(c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			h.sendError(c, http.StatusInternalServerError, "REPORT_ERROR", "無法產生成績單: "+err.Error())
+			return
+		}
+		log.Printf("產生 %s 中斷: %v", batch.Filename, err)
+		c.Abort()
+	}
+}
+
+// SchoolAnnualSummary handles GET /api/v1/reports/schools/:id/annual-summary
+// Returns the school's fitness summary workbook for the term given by the
+// term query parameter ("113-1"), or a whole academic year ("113", default
+// the current one)
+func (h *ReportHandler) SchoolAnnualSummary(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "INVALID_ID", "無效的學校 ID")
+		return
+	}
+
+	buffer, filename, err := h.summary.SchoolAnnualSummary(c.Request.Context(), uint(id), c.Query("term"))
+	if err != nil {
+		switch {
+		case err.Error() == "學校不存在":
+			h.sendError(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case strings.HasPrefix(err.Error(), "學期格式錯誤"):
+			h.sendError(c, http.StatusBadRequest, "INVALID_TERM", err.Error())
+		default:
+			h.sendError(c, http.StatusInternalServerError, "REPORT_ERROR", "無法產生年度統計: "+err.Error())
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", attachmentDisposition(filename))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buffer.Bytes())
+}
+
+// sendError sends a standardized error response
+func (h *ReportHandler) sendError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+			"status":  status,
+		},
+	})
+}
+
+// attachmentDisposition builds a download header for a file name that may
+// contain Chinese characters
+func attachmentDisposition(filename string) string {
+	return "attachment; filename*=UTF-8''" + url.PathEscape(filename)
+}
@@ -42,22 +42,16 @@ func (h *SchoolHandler) GetForMap(c *gin.Context) {
 }
 
 // List handles GET /api/v1/schools
-// Returns paginated list of schools
+// Returns paginated list of schools, filtered by county, level, indigenous
+// key-school flag, urbanisation class and enrolment size
 func (h *SchoolHandler) List(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	countyName := c.Query("county_name")
-
-	var schools []models.School
-	var pagination *models.Pagination
-	var err error
-
-	if countyName != "" {
-		schools, pagination, err = h.service.ListByCounty(countyName, page, pageSize)
-	} else {
-		schools, pagination, err = h.service.List(page, pageSize)
+	var params models.SchoolSearchParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_PARAMS", "無效的查詢參數")
+		return
 	}
 
+	schools, pagination, err := h.service.List(&params)
 	if err != nil {
 		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得學校列表")
 		return
@@ -117,6 +111,14 @@ func (h *SchoolHandler) Create(c *gin.Context) {
 			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_COUNTY", err.Error())
 			return
 		}
+		if err.Error() == "無效的學校層級" || err.Error() == "無效的都市化程度" || err.Error() == "無效的鄉鎮市區" {
+			h.sendErrorResponse(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+		if err.Error() == "此學校代碼已存在" {
+			h.sendErrorResponse(c, http.StatusConflict, "DUPLICATE_SCHOOL_CODE", err.Error())
+			return
+		}
 		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法建立學校")
 		return
 	}
@@ -155,6 +157,14 @@ func (h *SchoolHandler) Update(c *gin.Context) {
 			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_COUNTY", err.Error())
 			return
 		}
+		if err.Error() == "無效的學校層級" || err.Error() == "無效的都市化程度" || err.Error() == "無效的鄉鎮市區" {
+			h.sendErrorResponse(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+		if err.Error() == "此學校代碼已存在" {
+			h.sendErrorResponse(c, http.StatusConflict, "DUPLICATE_SCHOOL_CODE", err.Error())
+			return
+		}
 		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法更新學校")
 		return
 	}
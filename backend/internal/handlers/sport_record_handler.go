@@ -168,12 +168,13 @@ func (h *SportRecordHandler) Get(c *gin.Context) {
 
 // Create godoc
 // @Summary Create a new sport record
-// @Description Create a new sport record for a student
+// @Description Create a new sport record for a student. A record of the same sport on the same test date is a duplicate, handled by duplicate_policy (skip, replace, keep_both; default keep_both) and reported as duplicate_of
 // @Tags sport-records
 // @Accept json
 // @Produce json
 // @Param record body models.CreateSportRecordRequest true "Sport Record data"
 // @Success 201 {object} map[string]interface{}
+// @Success 200 {object} map[string]interface{} "Duplicate skipped or replaced"
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/sport-records [post]
@@ -218,7 +219,10 @@ func (h *SportRecordHandler) Create(c *gin.Context) {
 		return
 	}
 
-	record, err := h.service.Create(&req)
+	// TODO: Get actual user ID from auth context
+	changedByUserID := uint(1) // Placeholder
+
+	result, err := h.service.Create(&req, changedByUserID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
@@ -229,10 +233,22 @@ func (h *SportRecordHandler) Create(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	status, message := http.StatusCreated, "運動記錄建立成功"
+	switch result.Outcome {
+	case models.RecordOutcomeSkipped:
+		status, message = http.StatusOK, "已有相同記錄，未新增"
+	case models.RecordOutcomeReplaced:
+		status, message = http.StatusOK, "已取代既有記錄"
+	case models.RecordOutcomeCreatedDuplicate:
+		message = "運動記錄建立成功，但同日已有相同項目的記錄"
+	}
+
+	c.JSON(status, gin.H{
 		"data": gin.H{
-			"record":  record,
-			"message": "運動記錄建立成功",
+			"record":       result.Record,
+			"outcome":      result.Outcome,
+			"duplicate_of": result.DuplicateOf,
+			"message":      message,
 		},
 	})
 }
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/wei979/ICACP/backend/internal/models"
 	"github.com/wei979/ICACP/backend/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -140,6 +142,77 @@ func (h *StatisticsHandler) GetCountySportAverages(c *gin.Context) {
 	})
 }
 
+// GetDistrictSportAverages 取得鄉鎮市區各運動項目平均成績
+// GET /api/v1/statistics/district-sport-averages/:countyName/:district
+func (h *StatisticsHandler) GetDistrictSportAverages(c *gin.Context) {
+	countyName := c.Param("countyName")
+	district := c.Param("district")
+	if !models.IsValidDistrict(countyName, district) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_DISTRICT",
+				"message": "無效的縣市或鄉鎮市區名稱",
+			},
+		})
+		return
+	}
+
+	averages, err := h.service.GetDistrictSportAverages(c.Request.Context(), countyName, district)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "QUERY_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"county_name": countyName,
+			"district":    district,
+			"averages":    averages,
+		},
+	})
+}
+
+// GetGroupSportAverages 依學校屬性分組取得各運動項目平均成績
+// GET /api/v1/statistics/group-sport-averages?group_by=level&grade=5&gender=male
+func (h *StatisticsHandler) GetGroupSportAverages(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "county")
+	grade, _ := strconv.Atoi(c.Query("grade"))
+	gender := c.Query("gender")
+
+	groups, err := h.service.GetGroupSportAverages(c.Request.Context(), groupBy, grade, gender)
+	if err != nil {
+		if err.Error() == "無效的分組方式" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_GROUP_BY",
+					"message": err.Error(),
+				},
+				"valid_group_by": services.SchoolGroupDimensions,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "QUERY_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"group_by": groupBy,
+			"groups":   groups,
+		},
+	})
+}
+
 // CalculateNationalAverages 計算全國平均值
 // POST /api/v1/statistics/national-averages/calculate
 func (h *StatisticsHandler) CalculateNationalAverages(c *gin.Context) {
@@ -285,3 +358,187 @@ func (h *StatisticsHandler) GetAllTopSchools(c *gin.Context) {
 		},
 	})
 }
+
+// ImportNormTable 匯入官方常模（CSV），建立新的常模版本
+// POST /api/v1/statistics/norms/import
+func (h *StatisticsHandler) ImportNormTable(c *gin.Context) {
+	var req models.ImportNormRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "請提供常模名稱: " + err.Error(),
+			},
+		})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "MISSING_FILE",
+				"message": "請上傳常模檔案（.csv）",
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	// TODO: Get actual user ID from auth context
+	importedBy := uint(1) // Placeholder
+
+	table, err := h.service.ImportNormTable(c.Request.Context(), file, header.Filename, &req, importedBy)
+	if err != nil {
+		var rowErrors *services.NormImportErrors
+		if errors.As(err, &rowErrors) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_NORM_FILE",
+					"message": err.Error(),
+					"details": rowErrors.Errors,
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "IMPORT_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": table})
+}
+
+// ListNormTables 取得所有官方常模版本
+// GET /api/v1/statistics/norms
+func (h *StatisticsHandler) ListNormTables(c *gin.Context) {
+	tables, err := h.service.ListNormTables(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "QUERY_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"norm_tables": tables,
+		},
+	})
+}
+
+// GetNormTable 取得常模版本及其數值
+// GET /api/v1/statistics/norms/:id
+func (h *StatisticsHandler) GetNormTable(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "常模版本 ID 格式錯誤",
+			},
+		})
+		return
+	}
+
+	table, err := h.service.GetNormTable(c.Request.Context(), uint(id))
+	if err != nil {
+		status, code := http.StatusInternalServerError, "QUERY_ERROR"
+		if err.Error() == "常模版本不存在" {
+			status, code = http.StatusNotFound, "NOT_FOUND"
+		}
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"code":    code,
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": table})
+}
+
+// ActivateNormTable 啟用指定常模版本
+// PUT /api/v1/statistics/norms/:id/activate
+func (h *StatisticsHandler) ActivateNormTable(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "常模版本 ID 格式錯誤",
+			},
+		})
+		return
+	}
+
+	table, err := h.service.ActivateNormTable(c.Request.Context(), uint(id))
+	if err != nil {
+		status, code := http.StatusInternalServerError, "UPDATE_ERROR"
+		if err.Error() == "常模版本不存在" {
+			status, code = http.StatusNotFound, "NOT_FOUND"
+		}
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"code":    code,
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": table})
+}
+
+// GetNormSource 取得學生比較使用的基準來源
+// GET /api/v1/statistics/norm-source
+func (h *StatisticsHandler) GetNormSource(c *gin.Context) {
+	setting, err := h.service.GetNormSource(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "QUERY_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": setting})
+}
+
+// SetNormSource 設定學生比較使用官方常模或系統計算的平均
+// PUT /api/v1/statistics/norm-source
+func (h *StatisticsHandler) SetNormSource(c *gin.Context) {
+	var req models.NormSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "基準來源必須是 official 或 computed",
+			},
+		})
+		return
+	}
+
+	setting, err := h.service.SetNormSource(c.Request.Context(), req.Source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "UPDATE_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": setting})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wei979/ICACP/backend/internal/models"
@@ -134,6 +135,8 @@ func (h *StudentHandler) Create(c *gin.Context) {
 			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_DATE", err.Error())
 		case "出生日期不能是未來日期":
 			h.sendErrorResponse(c, http.StatusBadRequest, "FUTURE_DATE", err.Error())
+		case "班級不存在", "班級不屬於該學校", "學生年級與班級年級不符":
+			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_CLASS", err.Error())
 		default:
 			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法建立學生")
 		}
@@ -164,7 +167,10 @@ func (h *StudentHandler) Update(c *gin.Context) {
 		return
 	}
 
-	student, err := h.service.Update(uint(id), &req)
+	// TODO: Get actual user ID from auth context
+	changedBy := uint(1) // Placeholder
+
+	student, err := h.service.Update(uint(id), &req, changedBy)
 	if err != nil {
 		switch err.Error() {
 		case "student not found":
@@ -175,6 +181,8 @@ func (h *StudentHandler) Update(c *gin.Context) {
 			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_DATE", err.Error())
 		case "出生日期不能是未來日期":
 			h.sendErrorResponse(c, http.StatusBadRequest, "FUTURE_DATE", err.Error())
+		case "班級不存在", "班級不屬於該學校", "學生年級與班級年級不符":
+			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_CLASS", err.Error())
 		default:
 			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法更新學生")
 		}
@@ -215,6 +223,187 @@ func (h *StudentHandler) Delete(c *gin.Context) {
 	})
 }
 
+// FindDuplicates handles GET /api/v1/students/duplicates
+// Returns likely duplicate students within a school
+func (h *StudentHandler) FindDuplicates(c *gin.Context) {
+	schoolID, err := strconv.ParseUint(c.Query("school_id"), 10, 32)
+	if err != nil || schoolID == 0 {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_SCHOOL_ID", "請提供有效的學校 ID")
+		return
+	}
+
+	duplicates, err := h.service.FindDuplicates(uint(schoolID))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法檢查重複學生")
+		return
+	}
+
+	response := models.DuplicateStudentListResponse{}
+	response.Data.Duplicates = duplicates
+	response.Data.Total = len(duplicates)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Merge handles POST /api/v1/students/:id/merge
+// Merges a duplicate student into the student identified by :id
+func (h *StudentHandler) Merge(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	var req models.MergeStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_REQUEST", "請求資料格式錯誤")
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	mergedBy := uint(1) // Placeholder
+
+	merge, err := h.service.Merge(uint(id), &req, mergedBy)
+	if err != nil {
+		switch err.Error() {
+		case "student not found":
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", "學生不存在")
+		case "重複學生不存在":
+			h.sendErrorResponse(c, http.StatusNotFound, "DUPLICATE_NOT_FOUND", err.Error())
+		case "無法將學生與自己合併", "只能合併同一學校的學生":
+			h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_MERGE", err.Error())
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法合併學生")
+		}
+		return
+	}
+
+	response := models.StudentMergeResponse{}
+	response.Data.Merge = *merge
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevertMerge handles POST /api/v1/students/merges/:mergeId/revert
+// Reverts a previous merge and restores the merged student
+func (h *StudentHandler) RevertMerge(c *gin.Context) {
+	mergeID, err := strconv.ParseUint(c.Param("mergeId"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的合併記錄 ID")
+		return
+	}
+
+	// TODO: Get actual user ID from auth context
+	revertedBy := uint(1) // Placeholder
+
+	merge, err := h.service.RevertMerge(uint(mergeID), revertedBy)
+	if err != nil {
+		switch {
+		case err.Error() == "合併記錄不存在":
+			h.sendErrorResponse(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case err.Error() == "此合併已還原":
+			h.sendErrorResponse(c, http.StatusConflict, "ALREADY_REVERTED", err.Error())
+		case strings.HasPrefix(err.Error(), "無法還原合併"):
+			h.sendErrorResponse(c, http.StatusConflict, "DUPLICATE_STUDENT_NUMBER", err.Error())
+		default:
+			h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法還原合併")
+		}
+		return
+	}
+
+	response := models.StudentMergeResponse{}
+	response.Data.Merge = *merge
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListMerges handles GET /api/v1/students/:id/merges
+// Returns merges involving the student
+func (h *StudentHandler) ListMerges(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	merges, err := h.service.ListMerges(uint(id))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得合併記錄")
+		return
+	}
+
+	response := models.StudentMergeListResponse{}
+	response.Data.Merges = merges
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetHistory handles GET /api/v1/students/:id/history
+// Returns the audit history of a student
+func (h *StudentHandler) GetHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	audits, err := h.service.GetHistory(uint(id))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得變更記錄")
+		return
+	}
+
+	response := models.StudentAuditListResponse{}
+	response.Data.Audits = audits
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetNumberHistory handles GET /api/v1/students/:id/number-history
+// Returns the former student numbers of a student
+func (h *StudentHandler) GetNumberHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_ID", "無效的學生 ID")
+		return
+	}
+
+	history, err := h.service.GetNumberHistory(uint(id))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法取得座號變更記錄")
+		return
+	}
+
+	response := models.StudentNumberHistoryResponse{}
+	response.Data.History = history
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LookupNumber handles GET /api/v1/students/lookup-number
+// Returns the current and former holders of a student number in a school
+func (h *StudentHandler) LookupNumber(c *gin.Context) {
+	schoolID, err := strconv.ParseUint(c.Query("school_id"), 10, 32)
+	if err != nil || schoolID == 0 {
+		h.sendErrorResponse(c, http.StatusBadRequest, "INVALID_SCHOOL_ID", "請提供有效的學校 ID")
+		return
+	}
+
+	studentNumber := strings.TrimSpace(c.Query("student_number"))
+	if studentNumber == "" {
+		h.sendErrorResponse(c, http.StatusBadRequest, "VALIDATION_ERROR", "座號為必填")
+		return
+	}
+
+	lookup, err := h.service.LookupNumber(uint(schoolID), studentNumber)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, "INTERNAL_ERROR", "無法查詢座號")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StudentNumberLookupResponse{Data: *lookup})
+}
+
 // Helper function to send error responses
 func (h *StudentHandler) sendErrorResponse(c *gin.Context, statusCode int, errorCode string, message string) {
 	c.JSON(statusCode, gin.H{
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Portable archive format. An archive is a stream of JSON entries, one per
+// line: a header, the sport types, the norm tables, one entry per school with
+// its classes, students, records and audits, and an end entry with the
+// counts. Rows refer to each other by natural keys (school code or county and
+// name, student number, sport type name) instead of database IDs, so an
+// archive can be restored into another deployment.
+const (
+	ArchiveFormat  = "icacp-archive"
+	ArchiveVersion = 1
+)
+
+// Archive entry kinds
+const (
+	ArchiveEntryHeader    = "header"
+	ArchiveEntrySportType = "sport_type"
+	ArchiveEntryNormTable = "norm_table"
+	ArchiveEntrySchool    = "school"
+	ArchiveEntryEnd       = "end"
+)
+
+// ArchiveEntry is one line of an archive
+type ArchiveEntry struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ArchiveHeader identifies the archive format and where it was made
+type ArchiveHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"` // free text naming the exporting deployment
+}
+
+// ArchiveCounts counts the rows of an archive or of a restore
+type ArchiveCounts struct {
+	SportTypes      int `json:"sport_types"`
+	NormTables      int `json:"norm_tables"`
+	Schools         int `json:"schools"`
+	Classes         int `json:"classes"`
+	Students        int `json:"students"`
+	Records         int `json:"records"`
+	StudentAudits   int `json:"student_audits"`
+	RecordAudits    int `json:"record_audits"`
+	NumberHistories int `json:"number_histories"`
+}
+
+// ArchiveSportType is a sport type, identified by its name
+type ArchiveSportType struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	DefaultUnit string `json:"default_unit"`
+	ValueType   string `json:"value_type"`
+}
+
+// ArchiveNormTable is an official norm table with its entries
+type ArchiveNormTable struct {
+	Name       string             `json:"name"`
+	Source     string             `json:"source"`
+	Year       int                `json:"year"`
+	FileName   string             `json:"file_name"`
+	Active     bool               `json:"active"`
+	ImportedBy uint               `json:"imported_by"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Entries    []ArchiveNormEntry `json:"entries"`
+}
+
+// ArchiveNormEntry is one sport type, age and gender of a norm table
+type ArchiveNormEntry struct {
+	SportType    string  `json:"sport_type"`
+	Age          int     `json:"age"`
+	Gender       string  `json:"gender"`
+	AvgValue     float64 `json:"avg_value"`
+	SampleCount  int     `json:"sample_count"`
+	Percentile25 float64 `json:"percentile_25"`
+	Percentile50 float64 `json:"percentile_50"`
+	Percentile75 float64 `json:"percentile_75"`
+	Percentile90 float64 `json:"percentile_90"`
+}
+
+// ArchiveSchool is a school with everything that belongs to it. It is
+// identified by its school code, or by county and name without a code.
+type ArchiveSchool struct {
+	SchoolCode            *string          `json:"school_code"`
+	Name                  string           `json:"name"`
+	CountyName            string           `json:"county_name"`
+	District              string           `json:"district"`
+	Address               string           `json:"address"`
+	Phone                 string           `json:"phone"`
+	Level                 string           `json:"level"`
+	IsIndigenousKeySchool bool             `json:"is_indigenous_key_school"`
+	UrbanizationLevel     string           `json:"urbanization_level"`
+	EnrollmentSize        *int             `json:"enrollment_size"`
+	Latitude              *float64         `json:"latitude"`
+	Longitude             *float64         `json:"longitude"`
+	LastRecordsUploadedAt *time.Time       `json:"last_records_uploaded_at"`
+	CreatedAt             time.Time        `json:"created_at"`
+	Classes               []ArchiveClass   `json:"classes"`
+	Students              []ArchiveStudent `json:"students"`
+}
+
+// ArchiveClassKey identifies a class within its school
+type ArchiveClassKey struct {
+	AcademicYear int    `json:"academic_year"`
+	Grade        int    `json:"grade"`
+	Name         string `json:"name"`
+}
+
+// ArchiveClass is a class of a school
+type ArchiveClass struct {
+	ArchiveClassKey
+	HomeroomTeacherName string    `json:"homeroom_teacher_name"`
+	Capacity            int       `json:"capacity"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// ArchiveStudent is a student with their records and history. It is
+// identified by student number and name within the school.
+type ArchiveStudent struct {
+	StudentNumber string                        `json:"student_number"`
+	Name          string                        `json:"name"`
+	Grade         int                           `json:"grade"`
+	Class         string                        `json:"class"`
+	ClassKey      *ArchiveClassKey              `json:"class_key,omitempty"`
+	Gender        string                        `json:"gender"`
+	BirthDate     *string                       `json:"birth_date"` // YYYY-MM-DD
+	CreatedAt     time.Time                     `json:"created_at"`
+	Records       []ArchiveRecord               `json:"records"`
+	Audits        []ArchiveStudentAudit         `json:"audits,omitempty"`
+	NumberHistory []ArchiveStudentNumberHistory `json:"number_history,omitempty"`
+}
+
+// ArchiveRecord is a sport record with its audit trail
+type ArchiveRecord struct {
+	SportType string               `json:"sport_type"`
+	Value     float64              `json:"value"`
+	TestDate  string               `json:"test_date"` // YYYY-MM-DD
+	Notes     string               `json:"notes,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	Audits    []ArchiveRecordAudit `json:"audits,omitempty"`
+}
+
+// ArchiveRecordAudit is a change of a sport record
+type ArchiveRecordAudit struct {
+	OldValue  *float64  `json:"old_value"`
+	NewValue  *float64  `json:"new_value"`
+	ChangedBy uint      `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	Reason    string    `json:"reason"`
+}
+
+// ArchiveStudentAudit is a change of a student
+type ArchiveStudentAudit struct {
+	Action    string    `json:"action"`
+	Field     string    `json:"field,omitempty"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	ChangedBy uint      `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	Reason    string    `json:"reason"`
+}
+
+// ArchiveStudentNumberHistory is a student number a student no longer uses
+type ArchiveStudentNumberHistory struct {
+	StudentNumber string    `json:"student_number"`
+	ReplacedBy    string    `json:"replaced_by"`
+	Source        string    `json:"source"`
+	ChangedBy     uint      `json:"changed_by"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
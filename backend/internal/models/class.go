@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Class represents a class (班級) of a school in a given academic year (學年度).
+// Name holds the normalized class name, e.g. "1" for 一班, 甲班 and 01.
+// A class is unique per school, academic year, grade and name; the unique
+// index also covers soft-deleted classes.
+type Class struct {
+	ID                  uint           `gorm:"primarykey" json:"id"`
+	SchoolID            uint           `gorm:"not null;uniqueIndex:idx_school_year_grade_class,priority:1" json:"school_id"`
+	AcademicYear        int            `gorm:"not null;uniqueIndex:idx_school_year_grade_class,priority:2" json:"academic_year"`
+	Grade               int            `gorm:"not null;uniqueIndex:idx_school_year_grade_class,priority:3" json:"grade"`
+	Name                string         `gorm:"size:20;not null;uniqueIndex:idx_school_year_grade_class,priority:4" json:"name"`
+	HomeroomTeacherID   *uint          `gorm:"index" json:"homeroom_teacher_id"`
+	HomeroomTeacherName string         `gorm:"size:50" json:"homeroom_teacher_name"`
+	Capacity            int            `gorm:"not null;default:0" json:"capacity"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+	School              School         `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
+	StudentCount        int            `gorm:"->;-:migration" json:"student_count"`
+}
+
+// TableName specifies the table name for Class
+func (Class) TableName() string {
+	return "classes"
+}
+
+// CreateClassRequest represents the request body for creating a class
+type CreateClassRequest struct {
+	SchoolID            uint   `json:"school_id" binding:"required"`
+	AcademicYear        int    `json:"academic_year" binding:"omitempty,min=1"`
+	Grade               int    `json:"grade" binding:"required,min=1,max=12"`
+	Name                string `json:"name" binding:"required,max=20"`
+	HomeroomTeacherID   *uint  `json:"homeroom_teacher_id"`
+	HomeroomTeacherName string `json:"homeroom_teacher_name" binding:"max=50"`
+	Capacity            int    `json:"capacity" binding:"min=0"`
+}
+
+// UpdateClassRequest represents the request body for updating a class
+type UpdateClassRequest struct {
+	Name                string `json:"name" binding:"required,max=20"`
+	HomeroomTeacherID   *uint  `json:"homeroom_teacher_id"`
+	HomeroomTeacherName string `json:"homeroom_teacher_name" binding:"max=50"`
+	Capacity            int    `json:"capacity" binding:"min=0"`
+}
+
+// ClassSearchParams represents query parameters for class search
+type ClassSearchParams struct {
+	SchoolID     uint `form:"school_id"`
+	AcademicYear int  `form:"academic_year"`
+	Grade        int  `form:"grade"`
+}
+
+// AssignStudentsRequest represents the request body for adding students to a class
+type AssignStudentsRequest struct {
+	StudentIDs []uint `json:"student_ids" binding:"required,min=1"`
+}
+
+// ClassResponse is the API response wrapper for a single class
+type ClassResponse struct {
+	Data struct {
+		Class Class `json:"class"`
+	} `json:"data"`
+}
+
+// ClassListResponse is the API response wrapper for class list
+type ClassListResponse struct {
+	Data struct {
+		Classes []Class `json:"classes"`
+		Total   int     `json:"total"`
+	} `json:"data"`
+}
+
+// ClassRosterResponse is the API response wrapper for a class roster
+type ClassRosterResponse struct {
+	Data struct {
+		Class    Class     `json:"class"`
+		Students []Student `json:"students"`
+		Total    int       `json:"total"`
+	} `json:"data"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Export file formats
+const (
+	ExportFormatXLSX = "xlsx"
+	ExportFormatCSV  = "csv"
+)
+
+// Record export layouts
+const (
+	// ExportLayoutBySport lists one record per row; xlsx files get a sheet
+	// per sport type
+	ExportLayoutBySport = "by_sport"
+	// ExportLayoutWide lists one row per student and test date with a column
+	// per sport type
+	ExportLayoutWide = "wide"
+)
+
+// ExportParams represents the query parameters of student and record exports
+type ExportParams struct {
+	SchoolID    uint   `form:"school_id"`
+	Grade       int    `form:"grade"`
+	Class       string `form:"class"`
+	ClassID     uint   `form:"class_id"`
+	SportTypeID uint   `form:"sport_type_id"`
+	Term        string `form:"term"`       // academic term, e.g. "113-1", or year "113"
+	StartDate   string `form:"start_date"` // inclusive, YYYY-MM-DD
+	EndDate     string `form:"end_date"`   // inclusive, YYYY-MM-DD
+	Format      string `form:"format"`     // xlsx (default) or csv
+	Layout      string `form:"layout"`     // by_sport (default) or wide, records only
+}
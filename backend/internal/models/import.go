@@ -8,6 +8,7 @@ type ImportType string
 const (
 	ImportTypeStudents ImportType = "students"
 	ImportTypeRecords  ImportType = "records"
+	ImportTypeSchools  ImportType = "schools"
 )
 
 // RowStatus represents the validation status of an import row
@@ -19,30 +20,118 @@ const (
 	RowStatusError   RowStatus = "error"
 )
 
+// ImportMode controls how rows matching an existing student (by number) or
+// school (by code, or name and county) are handled
+type ImportMode string
+
+const (
+	ImportModeCreateOnly ImportMode = "create_only" // existing numbers are errors (default)
+	ImportModeUpdateOnly ImportMode = "update_only" // unmatched rows are errors
+	ImportModeUpsert     ImportMode = "upsert"      // update existing, create the rest
+)
+
+// IsValidImportMode checks if a mode is one of the supported import modes
+func IsValidImportMode(mode ImportMode) bool {
+	return mode == ImportModeCreateOnly || mode == ImportModeUpdateOnly || mode == ImportModeUpsert
+}
+
+// RowAction is what executing a preview will do with a row
+type RowAction string
+
+const (
+	RowActionCreate    RowAction = "create"
+	RowActionUpdate    RowAction = "update"
+	RowActionUnchanged RowAction = "unchanged"
+)
+
+// FieldChange describes a field an import will change on an existing student
+// or school
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// RecordConflict describes a sport value in a records import that has the
+// same student, sport and test date as an existing record or an earlier row
+type RecordConflict struct {
+	Sport            string  `json:"sport"`
+	ExistingRecordID uint    `json:"existing_record_id,omitempty"` // record already stored
+	ExistingValue    float64 `json:"existing_value,omitempty"`
+	ConflictSheet    string  `json:"conflict_sheet,omitempty"` // sheet of the earlier row (multi-sheet imports)
+	ConflictRow      int     `json:"conflict_row,omitempty"`   // earlier row of the same file
+	NewValue         float64 `json:"new_value"`
+}
+
+// StudentSuggestion is a student a records row may belong to when its
+// number and name match no student exactly
+type StudentSuggestion struct {
+	StudentID     uint    `json:"student_id"`
+	StudentNumber string  `json:"student_number"`
+	Name          string  `json:"name"`
+	Grade         int     `json:"grade"`
+	Class         string  `json:"class"`
+	Score         float64 `json:"score"` // 0-1, higher is a closer match
+}
+
+// ImportSheetSummary holds the counts of one sheet of a multi-sheet import
+type ImportSheetSummary struct {
+	Name         string         `json:"name"`
+	Grade        int            `json:"grade,omitempty"`
+	Class        string         `json:"class,omitempty"`
+	TotalRows    int            `json:"total_rows"`
+	ValidRows    int            `json:"valid_rows"`
+	WarningRows  int            `json:"warning_rows"`
+	ErrorRows    int            `json:"error_rows"`
+	ConflictRows int            `json:"conflict_rows,omitempty"`
+	Skipped      bool           `json:"skipped,omitempty"`
+	Reason       string         `json:"reason,omitempty"`     // why the sheet was skipped
+	ColumnMap    map[string]int `json:"column_map,omitempty"` // row field -> zero-based column in the sheet
+}
+
 // ImportPreview represents a preview session for batch import
 type ImportPreview struct {
-	ID          string                   `json:"preview_id"`
-	Type        ImportType               `json:"type"`
-	SchoolID    uint                     `json:"school_id"`
-	Grade       int                      `json:"grade,omitempty"`
-	Class       string                   `json:"class,omitempty"`
-	FileName    string                   `json:"file_name"`
-	TotalRows   int                      `json:"total_rows"`
-	ValidRows   int                      `json:"valid_rows"`
-	WarningRows int                      `json:"warning_rows"`
-	ErrorRows   int                      `json:"error_rows"`
-	Rows        []ImportRow              `json:"rows"`
-	CreatedAt   time.Time                `json:"created_at"`
-	ExpiresAt   time.Time                `json:"expires_at"`
-	Executed    bool                     `json:"-"` // Internal flag to track if preview was executed
+	ID              string               `json:"preview_id"`
+	Type            ImportType           `json:"type"`
+	SchoolID        uint                 `json:"school_id"`
+	Mode            ImportMode           `json:"mode,omitempty"` // student and school imports only
+	Grade           int                  `json:"grade,omitempty"`
+	Class           string               `json:"class,omitempty"`
+	FileName        string               `json:"file_name"`
+	FileFormat      string               `json:"file_format,omitempty"` // xlsx, xls, ods or csv
+	Encoding        string               `json:"encoding,omitempty"`    // detected text encoding (csv only)
+	FileHash        string               `json:"file_hash"`
+	SheetName       string               `json:"sheet_name,omitempty"`
+	ColumnMap       map[string]int       `json:"column_map,omitempty"` // row field -> zero-based column in the sheet
+	FileData        []byte               `json:"-"`                    // original upload, for the annotated workbook
+	TotalRows       int                  `json:"total_rows"`
+	ValidRows       int                  `json:"valid_rows"`
+	WarningRows     int                  `json:"warning_rows"`
+	ErrorRows       int                  `json:"error_rows"`
+	CreateRows      int                  `json:"create_rows,omitempty"`      // student and school imports: rows that create an entry
+	UpdateRows      int                  `json:"update_rows,omitempty"`      // student and school imports: rows that change an entry
+	SameRows        int                  `json:"unchanged_rows,omitempty"`   // student and school imports: rows matching an entry as is
+	ConflictRows    int                  `json:"conflict_rows,omitempty"`    // records imports: rows with duplicate records
+	ConflictRecords int                  `json:"conflict_records,omitempty"` // records imports: duplicate sport values
+	Sheets          []ImportSheetSummary `json:"sheets,omitempty"`           // whole-school records imports: counts per class sheet
+	Edits           []PreviewRowEdit     `json:"edits,omitempty"`            // changes made to rows before execution
+	Rows            []ImportRow          `json:"rows"`
+	CreatedAt       time.Time            `json:"created_at"`
+	ExpiresAt       time.Time            `json:"expires_at"`
+	Executed        bool                 `json:"-"` // Internal flag to track if preview was executed
 }
 
 // ImportRow represents a single row in the import preview
 type ImportRow struct {
-	RowNumber int                    `json:"row_number"`
-	Status    RowStatus              `json:"status"`
-	Data      map[string]interface{} `json:"data"`
-	Errors    []RowError             `json:"errors"`
+	Sheet       string                 `json:"sheet,omitempty"` // multi-sheet imports: sheet the row comes from
+	RowNumber   int                    `json:"row_number"`
+	Status      RowStatus              `json:"status"`
+	Data        map[string]interface{} `json:"data"`
+	Errors      []RowError             `json:"errors"`
+	Action      RowAction              `json:"action,omitempty"`
+	Changes     []FieldChange          `json:"changes,omitempty"`     // field-level diff of update rows
+	Conflicts   []RecordConflict       `json:"conflicts,omitempty"`   // duplicate sport records of records rows
+	Suggestions []StudentSuggestion    `json:"suggestions,omitempty"` // records rows without a matching student
 }
 
 // RowError represents a validation error for a specific field
@@ -55,16 +144,26 @@ type RowError struct {
 
 // ImportResult represents the outcome of an import execution
 type ImportResult struct {
-	PreviewID    string          `json:"preview_id"`
-	Type         ImportType      `json:"type"`
-	SuccessCount int             `json:"success_count"`
-	SkipCount    int             `json:"skip_count"`
-	Errors       []ImportedError `json:"errors"`
-	ExecutedAt   time.Time       `json:"executed_at"`
+	PreviewID      string          `json:"preview_id"`
+	Type           ImportType      `json:"type"`
+	BatchID        uint            `json:"batch_id"` // import history entry, used to undo the import
+	SuccessCount   int             `json:"success_count"`
+	SkipCount      int             `json:"skip_count"`
+	CreatedCount   int             `json:"created_count,omitempty"`   // student and school imports
+	UpdatedCount   int             `json:"updated_count,omitempty"`   // student and school imports
+	UnchangedCount int             `json:"unchanged_count,omitempty"` // student and school imports
+	ReplacedCount  int             `json:"replaced_count,omitempty"`  // records imports: existing records overwritten
+	DuplicateCount int             `json:"duplicate_count,omitempty"` // records imports: duplicate values not imported
+	PartialCommit  bool            `json:"partial_commit,omitempty"`  // records imports: rows were written one by one
+	CommittedRows  int             `json:"committed_rows,omitempty"`  // partial commit: rows written
+	RejectedRows   int             `json:"rejected_rows,omitempty"`   // partial commit: rows the database refused, listed in Errors
+	Errors         []ImportedError `json:"errors"`
+	ExecutedAt     time.Time       `json:"executed_at"`
 }
 
 // ImportedError represents an error for a skipped row during import
 type ImportedError struct {
+	Sheet     string `json:"sheet,omitempty"`
 	RowNumber int    `json:"row_number"`
 	Field     string `json:"field,omitempty"`
 	Message   string `json:"message"`
@@ -74,6 +173,39 @@ type ImportedError struct {
 type ExecuteImportRequest struct {
 	PreviewID       string `json:"preview_id" binding:"required"`
 	IncludeWarnings bool   `json:"include_warnings"`
+	Async           bool   `json:"async"` // run as a background job and return its ID
+	// DuplicatePolicy decides how records imports treat values that duplicate
+	// an existing record or an earlier row (default skip)
+	DuplicatePolicy DuplicatePolicy `json:"duplicate_policy"`
+	// PartialCommit writes each records row under its own savepoint, so rows
+	// the database refuses are reported instead of aborting the whole import
+	PartialCommit bool `json:"partial_commit"`
+}
+
+// PreviewRowEdit records a cell a user changed in a preview row before the
+// import was executed
+type PreviewRowEdit struct {
+	Sheet     string    `json:"sheet,omitempty"`
+	RowNumber int       `json:"row_number"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	EditedBy  uint      `json:"edited_by"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// PatchPreviewRowRequest represents the request body for editing a preview
+// row. Data holds the new raw values keyed like ImportRow.Data.
+type PatchPreviewRowRequest struct {
+	Sheet string            `json:"sheet"` // multi-sheet previews only
+	Data  map[string]string `json:"data" binding:"required"`
+}
+
+// SelectStudentRequest represents the request body for assigning a student
+// to a records preview row
+type SelectStudentRequest struct {
+	Sheet     string `json:"sheet"` // multi-sheet previews only
+	StudentID uint   `json:"student_id" binding:"required"`
 }
 
 // Error codes for import validation
@@ -87,6 +219,8 @@ const (
 	ErrorCodeDuplicate     = "DUPLICATE"
 	ErrorCodeNotFound      = "NOT_FOUND"
 	ErrorCodeNoData        = "NO_DATA"
+	ErrorCodeFormerNumber  = "FORMER_NUMBER"
+	ErrorCodeNumberReused  = "NUMBER_REUSED"
 )
 
 // Student template column headers (Traditional Chinese)
@@ -99,6 +233,17 @@ var StudentTemplateHeaders = []string{
 	"生日",
 }
 
+// School template column headers (Traditional Chinese)
+var SchoolTemplateHeaders = []string{
+	"學校代碼",
+	"學校名稱*",
+	"縣市*",
+	"地址",
+	"電話",
+	"緯度",
+	"經度",
+}
+
 // Sport records template column headers (Traditional Chinese)
 var RecordsTemplateHeaders = []string{
 	"座號*",
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+)
+
+// ImportBatchStatus represents the state of an executed import
+type ImportBatchStatus string
+
+const (
+	ImportBatchCompleted ImportBatchStatus = "completed"
+	ImportBatchUndone    ImportBatchStatus = "undone"
+)
+
+// ImportBatch records an executed import: who uploaded which file with which
+// options, and the IDs of everything it created so the batch can be undone.
+// School imports are not tied to one school and leave SchoolID empty.
+// Students and schools updated by an upsert and records replaced by a
+// duplicate policy are listed too, but are not reverted by an undo; student
+// and record changes are kept in the audit logs. Cells edited in the preview
+// before execution are kept as well.
+type ImportBatch struct {
+	ID                uint              `gorm:"primarykey" json:"id"`
+	Type              ImportType        `gorm:"size:20;not null;index" json:"type"`
+	SchoolID          *uint             `gorm:"index" json:"school_id"`
+	PreviewID         string            `gorm:"size:36" json:"preview_id"`
+	FileName          string            `gorm:"size:255" json:"file_name"`
+	FileHash          string            `gorm:"size:64;index" json:"file_hash"` // SHA-256 of the uploaded file
+	FileFormat        string            `gorm:"size:10" json:"file_format"`
+	Grade             int               `json:"grade,omitempty"`
+	Class             string            `gorm:"size:50" json:"class,omitempty"`
+	Mode              ImportMode        `gorm:"size:20" json:"mode,omitempty"`
+	DuplicatePolicy   DuplicatePolicy   `gorm:"size:20" json:"duplicate_policy,omitempty"`
+	IncludeWarnings   bool              `json:"include_warnings"`
+	PartialCommit     bool              `json:"partial_commit"`
+	TotalRows         int               `json:"total_rows"`
+	SuccessCount      int               `json:"success_count"`
+	SkipCount         int               `json:"skip_count"`
+	RejectedRows      int               `json:"rejected_rows"` // partial commit: rows the database refused
+	CreatedStudentIDs []uint            `gorm:"serializer:json;type:mediumtext" json:"created_student_ids"`
+	CreatedRecordIDs  []uint            `gorm:"serializer:json;type:mediumtext" json:"created_record_ids"`
+	CreatedSchoolIDs  []uint            `gorm:"serializer:json;type:mediumtext" json:"created_school_ids"`
+	UpdatedStudentIDs []uint            `gorm:"serializer:json;type:mediumtext" json:"updated_student_ids"` // audited, not reverted by undo
+	ReplacedRecordIDs []uint            `gorm:"serializer:json;type:mediumtext" json:"replaced_record_ids"` // audited, not reverted by undo
+	UpdatedSchoolIDs  []uint            `gorm:"serializer:json;type:mediumtext" json:"updated_school_ids"`  // not reverted by undo
+	RowEdits          []PreviewRowEdit  `gorm:"serializer:json;type:mediumtext" json:"row_edits"`           // preview rows edited before execution
+	Status            ImportBatchStatus `gorm:"size:20;not null;default:'completed'" json:"status"`
+	UploadedBy        uint              `gorm:"not null" json:"uploaded_by"`
+	UploadedAt        time.Time         `json:"uploaded_at"`
+	UndoneBy          *uint             `json:"undone_by"`
+	UndoneAt          *time.Time        `json:"undone_at"`
+	School            *School           `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
+}
+
+// TableName specifies the table name for ImportBatch
+func (ImportBatch) TableName() string {
+	return "import_batches"
+}
+
+// ImportBatchSearchParams represents query parameters for listing import batches
+type ImportBatchSearchParams struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+	SchoolID uint   `form:"school_id"`
+	Type     string `form:"type"`
+}
+
+// ImportBatchListResponse is the API response wrapper for import history
+type ImportBatchListResponse struct {
+	Data struct {
+		Batches    []ImportBatch `json:"batches"`
+		Pagination Pagination    `json:"pagination"`
+	} `json:"data"`
+}
+
+// ImportBatchResponse is the API response wrapper for a single import batch
+type ImportBatchResponse struct {
+	Data struct {
+		Batch ImportBatch `json:"batch"`
+	} `json:"data"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// ImportField describes a field an import reads from the uploaded file. Key
+// matches the keys of the preview column map and of RowError.Field.
+type ImportField struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Required bool   `json:"required"`
+}
+
+// StudentImportFields lists the fields of a student import in template order
+var StudentImportFields = []ImportField{
+	{Key: "student_number", Label: "座號", Required: true},
+	{Key: "name", Label: "姓名", Required: true},
+	{Key: "gender", Label: "性別", Required: true},
+	{Key: "grade", Label: "年級", Required: true},
+	{Key: "class", Label: "班級"},
+	{Key: "birth_date", Label: "生日"},
+}
+
+// SchoolImportFields lists the fields of a school import in template order
+var SchoolImportFields = []ImportField{
+	{Key: "school_code", Label: "學校代碼"},
+	{Key: "school_name", Label: "學校名稱", Required: true},
+	{Key: "county_name", Label: "縣市", Required: true},
+	{Key: "address", Label: "地址"},
+	{Key: "phone", Label: "電話"},
+	{Key: "latitude", Label: "緯度"},
+	{Key: "longitude", Label: "經度"},
+}
+
+// RecordsImportFields lists the fields of a sport records import in template order
+var RecordsImportFields = []ImportField{
+	{Key: "student_number", Label: "座號", Required: true},
+	{Key: "name", Label: "姓名", Required: true},
+	{Key: "身高", Label: "身高(cm)"},
+	{Key: "體重", Label: "體重(kg)"},
+	{Key: "坐姿體前彎", Label: "坐姿體前彎(cm)"},
+	{Key: "立定跳遠", Label: "立定跳遠(cm)"},
+	{Key: "仰臥起坐", Label: "仰臥起坐(次/分鐘)"},
+	{Key: "心肺耐力", Label: "心肺耐力(秒)"},
+	{Key: "test_date", Label: "測驗日期", Required: true},
+}
+
+// ImportColumnMapping is a column mapping a school confirmed for its files,
+// stored as the header text of each field so it applies to any column order
+type ImportColumnMapping struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	SchoolID  uint              `gorm:"not null;uniqueIndex:idx_column_mapping_school_type" json:"school_id"`
+	Type      ImportType        `gorm:"size:20;not null;uniqueIndex:idx_column_mapping_school_type" json:"type"`
+	Headers   map[string]string `gorm:"serializer:json;type:text" json:"headers"` // field key -> header text
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// TableName specifies the table name for ImportColumnMapping
+func (ImportColumnMapping) TableName() string {
+	return "import_column_mappings"
+}
+
+// SaveColumnMappingRequest represents the request body for saving a mapping
+type SaveColumnMappingRequest struct {
+	SchoolID uint              `json:"school_id" binding:"required"`
+	Type     ImportType        `json:"type" binding:"required"`
+	Headers  map[string]string `json:"headers" binding:"required"`
+}
+
+// ColumnMappingOptions carries a column mapping the client confirmed for a
+// preview. HeaderRow is 1-based; 0 means the header row is detected.
+type ColumnMappingOptions struct {
+	HeaderRow int
+	ColumnMap map[string]int // field key -> zero-based column
+	Save      bool           // remember the mapping for the school
+}
+
+// ColumnSuggestion is the suggested column of one import field
+type ColumnSuggestion struct {
+	Field      string  `json:"field"`
+	Label      string  `json:"label"`
+	Required   bool    `json:"required"`
+	Column     int     `json:"column"` // zero-based, -1 when no column matched
+	Header     string  `json:"header,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source,omitempty"` // saved, alias or fuzzy
+}
+
+// ColumnDetection is the result of detecting the header row and columns of
+// an uploaded file, for the client to confirm before previewing
+type ColumnDetection struct {
+	Type            ImportType         `json:"type"`
+	SheetName       string             `json:"sheet_name"`
+	HeaderRow       int                `json:"header_row"` // 1-based
+	Headers         []string           `json:"headers"`
+	SampleRows      [][]string         `json:"sample_rows"`
+	Suggestions     []ColumnSuggestion `json:"suggestions"`
+	ColumnMap       map[string]int     `json:"column_map"` // suggested mapping, ready to send with the preview
+	MissingRequired []string           `json:"missing_required,omitempty"`
+	UsedSavedMap    bool               `json:"used_saved_mapping"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// ImportJobStatus represents the lifecycle state of a background import job
+type ImportJobStatus string
+
+const (
+	ImportJobQueued    ImportJobStatus = "queued"
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobFailed    ImportJobStatus = "failed"
+	ImportJobCancelled ImportJobStatus = "cancelled"
+)
+
+// IsFinished reports whether the job has reached a terminal state
+func (s ImportJobStatus) IsFinished() bool {
+	return s == ImportJobCompleted || s == ImportJobFailed || s == ImportJobCancelled
+}
+
+// ImportJob tracks an import executed in the background
+type ImportJob struct {
+	ID            string          `json:"job_id"`
+	Type          ImportType      `json:"type"`
+	PreviewID     string          `json:"preview_id"`
+	Status        ImportJobStatus `json:"status"`
+	TotalRows     int             `json:"total_rows"`
+	ProcessedRows int             `json:"processed_rows"`
+	InsertedCount int             `json:"inserted_count"` // students or sport records created
+	SkippedCount  int             `json:"skipped_count"`
+	Result        *ImportResult   `json:"result,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	FinishedAt    *time.Time      `json:"finished_at,omitempty"`
+}
+
+// ImportProgress is a progress update reported while an import runs
+type ImportProgress struct {
+	ProcessedRows int
+	InsertedCount int
+	SkippedCount  int
+}
@@ -0,0 +1,73 @@
+package models
+
+// MOE fitness platform (教育部體適能網站) upload layout. Every student is one
+// row with the columns below in this order; dates are ROC dates (YYYMMDD).
+const (
+	MOEGenderMale   = "1"
+	MOEGenderFemale = "2"
+)
+
+// MOEIdentityColumns are the leading columns of the upload layout
+var MOEIdentityColumns = []string{"學校代碼", "年級", "班級", "學號", "姓名", "性別", "出生日期", "檢測日期"}
+
+// MOEItem is a test item column of the upload layout
+type MOEItem struct {
+	Column     string   `json:"column"`      // header text
+	Unit       string   `json:"unit"`        // unit the platform expects
+	Decimals   int      `json:"decimals"`    // decimals kept in the file
+	Required   bool     `json:"required"`    // the platform rejects rows without it
+	SportTypes []string `json:"sport_types"` // our sport types recorded as this item
+}
+
+// MOEItems are the test items of the upload layout in column order
+var MOEItems = []MOEItem{
+	{Column: "身高", Unit: "公分", Decimals: 1, SportTypes: []string{"身高"}},
+	{Column: "體重", Unit: "公斤", Decimals: 1, SportTypes: []string{"體重"}},
+	{Column: "坐姿體前彎", Unit: "公分", Required: true, SportTypes: []string{"坐姿體前彎"}},
+	{Column: "立定跳遠", Unit: "公分", Required: true, SportTypes: []string{"立定跳遠"}},
+	{Column: "仰臥起坐", Unit: "次", Required: true, SportTypes: []string{"1分鐘屈膝仰臥起坐", "1分鐘仰臥起坐"}},
+	{Column: "心肺耐力", Unit: "秒", Required: true, SportTypes: []string{"800公尺", "1600公尺"}},
+}
+
+// MOECardioColumn is the column after the items holding the code of the run
+// recorded as 心肺耐力
+const MOECardioColumn = "心肺耐力項目"
+
+// MOECardioCodes maps the cardio sport types to their platform codes
+var MOECardioCodes = map[string]string{
+	"800公尺":  "1",
+	"1600公尺": "2",
+}
+
+// MOEExportParams represents the query parameters of the MOE upload export
+type MOEExportParams struct {
+	SchoolID uint   `form:"school_id" binding:"required"`
+	Term     string `form:"term"`   // academic term, e.g. "113-1"; defaults to the current term
+	Format   string `form:"format"` // xlsx (default) or csv
+}
+
+// MOEValidation is the result of checking a school's data against the
+// upload layout before exporting
+type MOEValidation struct {
+	SchoolID         uint       `json:"school_id"`
+	SchoolCode       string     `json:"school_code"`
+	Term             string     `json:"term"`
+	TotalRows        int        `json:"total_rows"`
+	ErrorRows        int        `json:"error_rows"`
+	WarningRows      int        `json:"warning_rows"`
+	SkippedStudents  int        `json:"skipped_students"` // students without results in the term
+	Errors           []RowError `json:"errors"`           // problems of the whole file
+	Issues           []MOEIssue `json:"issues"`
+	ReadyForDownload bool       `json:"ready_for_download"`
+}
+
+// MOEIssue lists the problems of one student's row
+type MOEIssue struct {
+	Row           int        `json:"row"` // line in the upload file, 0 when the student is left out
+	StudentID     uint       `json:"student_id"`
+	StudentNumber string     `json:"student_number"`
+	Name          string     `json:"name"`
+	Grade         int        `json:"grade"`
+	Class         string     `json:"class"`
+	Errors        []RowError `json:"errors"`
+}
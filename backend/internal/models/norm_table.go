@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+)
+
+// 比較基準來源
+const (
+	NormSourceOfficial = "official" // 官方常模（教育部體育署等公開資料）
+	NormSourceComputed = "computed" // 由本系統資料計算的全國平均
+)
+
+// NormTable 官方常模版本，每次匯入建立一個新版本
+type NormTable struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Version    int       `gorm:"not null;uniqueIndex" json:"version"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	Source     string    `gorm:"size:100" json:"source"`
+	Year       int       `json:"year"`
+	FileName   string    `gorm:"size:255" json:"file_name"`
+	EntryCount int       `gorm:"not null" json:"entry_count"`
+	Active     bool      `gorm:"not null;default:false;index" json:"active"`
+	ImportedBy uint      `json:"imported_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// 關聯
+	Entries []NormEntry `gorm:"foreignKey:NormTableID" json:"entries,omitempty"`
+}
+
+// TableName 指定資料表名稱
+func (NormTable) TableName() string {
+	return "norm_tables"
+}
+
+// NormEntry 常模中單一項目/年齡/性別的百分位數值
+type NormEntry struct {
+	ID           uint    `gorm:"primarykey" json:"id"`
+	NormTableID  uint    `gorm:"not null;uniqueIndex:idx_norm_sport_age_gender,priority:1" json:"norm_table_id"`
+	SportTypeID  uint    `gorm:"not null;uniqueIndex:idx_norm_sport_age_gender,priority:2" json:"sport_type_id"`
+	Age          int     `gorm:"not null;uniqueIndex:idx_norm_sport_age_gender,priority:3" json:"age"`
+	Gender       string  `gorm:"size:10;not null;uniqueIndex:idx_norm_sport_age_gender,priority:4" json:"gender"`
+	AvgValue     float64 `gorm:"type:decimal(10,2);not null" json:"avg_value"`
+	SampleCount  int     `json:"sample_count"`
+	Percentile25 float64 `gorm:"type:decimal(10,2)" json:"percentile_25"`
+	Percentile50 float64 `gorm:"type:decimal(10,2)" json:"percentile_50"`
+	Percentile75 float64 `gorm:"type:decimal(10,2)" json:"percentile_75"`
+	Percentile90 float64 `gorm:"type:decimal(10,2)" json:"percentile_90"`
+
+	// 關聯
+	SportType SportType `gorm:"foreignKey:SportTypeID" json:"sport_type,omitempty"`
+}
+
+// TableName 指定資料表名稱
+func (NormEntry) TableName() string {
+	return "norm_entries"
+}
+
+// AppSetting 系統設定（鍵值對）
+type AppSetting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"size:255;not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定資料表名稱
+func (AppSetting) TableName() string {
+	return "app_settings"
+}
+
+// SettingComparisonNormSource 學生比較使用的基準來源（official 或 computed）
+const SettingComparisonNormSource = "comparison_norm_source"
+
+// ImportNormRequest 匯入官方常模的表單欄位
+type ImportNormRequest struct {
+	Name     string `form:"name" binding:"required,max=100"`
+	Source   string `form:"source" binding:"max=100"`
+	Year     int    `form:"year"`
+	Activate bool   `form:"activate"`
+}
+
+// NormSourceRequest 設定比較基準來源
+type NormSourceRequest struct {
+	Source string `json:"source" binding:"required,oneof=official computed"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// GeoJSON types (RFC 7946) for the open data map layers
+
+// FeatureCollection is a GeoJSON feature collection. Metadata is a foreign
+// member carrying the licence, which GIS tools keep but do not interpret.
+type FeatureCollection struct {
+	Type     string            `json:"type"` // always "FeatureCollection"
+	Name     string            `json:"name,omitempty"`
+	Metadata *OpenDataMetadata `json:"metadata,omitempty"`
+	Features []Feature         `json:"features"`
+}
+
+// Feature is a GeoJSON feature; Geometry is null for features without a location
+type Feature struct {
+	Type       string                 `json:"type"` // always "Feature"
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON point geometry; coordinates are longitude, latitude
+type Geometry struct {
+	Type        string    `json:"type"` // always "Point"
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// NewPoint returns a point geometry at the given latitude and longitude
+func NewPoint(latitude, longitude float64) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+// OpenDataMetadata describes a published dataset and its licence
+type OpenDataMetadata struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Publisher   string    `json:"publisher"`
+	License     string    `json:"license"`
+	LicenseURL  string    `json:"license_url"`
+	Attribution string    `json:"attribution"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// OpenDataResource is one downloadable file of the open data package
+type OpenDataResource struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Format      string   `json:"format"`
+	MediaType   string   `json:"mediatype"`
+	Description string   `json:"description"`
+	Fields      []string `json:"fields,omitempty"`
+}
+
+// OpenDataPackage lists the open data files with their licence, loosely
+// following the Data Package (datapackage.json) layout
+type OpenDataPackage struct {
+	OpenDataMetadata
+	Resources []OpenDataResource `json:"resources"`
+}
+
+// CountyCodes are the Ministry of the Interior county codes (COUNTYCODE in
+// the official boundary data), so county layers can be joined with it
+var CountyCodes = map[string]string{
+	"臺北市": "63000",
+	"新北市": "65000",
+	"桃園市": "68000",
+	"臺中市": "66000",
+	"臺南市": "67000",
+	"高雄市": "64000",
+	"基隆市": "10017",
+	"新竹市": "10018",
+	"嘉義市": "10020",
+	"新竹縣": "10004",
+	"苗栗縣": "10005",
+	"彰化縣": "10007",
+	"南投縣": "10008",
+	"雲林縣": "10009",
+	"嘉義縣": "10010",
+	"屏東縣": "10013",
+	"宜蘭縣": "10002",
+	"花蓮縣": "10015",
+	"臺東縣": "10014",
+	"澎湖縣": "10016",
+	"金門縣": "09020",
+	"連江縣": "09007",
+}
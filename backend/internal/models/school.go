@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,10 +10,16 @@ import (
 // School represents a school entity
 type School struct {
 	ID                    uint           `gorm:"primarykey" json:"id"`
+	SchoolCode            *string        `gorm:"size:10;uniqueIndex" json:"school_code"`
 	Name                  string         `gorm:"size:100;not null" json:"name" binding:"required,max=100"`
 	CountyName            string         `gorm:"size:50;not null;index" json:"county_name" binding:"required"`
+	District              string         `gorm:"size:20;index" json:"district"`
 	Address               string         `gorm:"size:255" json:"address" binding:"max=255"`
 	Phone                 string         `gorm:"size:20" json:"phone" binding:"max=20"`
+	Level                 string         `gorm:"size:10;index" json:"level"`
+	IsIndigenousKeySchool bool           `gorm:"not null;default:false;index" json:"is_indigenous_key_school"`
+	UrbanizationLevel     string         `gorm:"size:10;index" json:"urbanization_level"`
+	EnrollmentSize        *int           `json:"enrollment_size"`
 	Latitude              *float64       `gorm:"type:decimal(10,8)" json:"latitude"`
 	Longitude             *float64       `gorm:"type:decimal(11,8)" json:"longitude"`
 	LastRecordsUploadedAt *time.Time     `gorm:"column:last_records_uploaded_at" json:"last_records_uploaded_at"`
@@ -49,22 +56,112 @@ func (School) TableName() string {
 
 // CreateSchoolRequest represents the request body for creating a school
 type CreateSchoolRequest struct {
-	Name       string   `json:"name" binding:"required,max=100"`
-	CountyName string   `json:"county_name" binding:"required"`
-	Address    string   `json:"address" binding:"max=255"`
-	Phone      string   `json:"phone" binding:"max=20"`
-	Latitude   *float64 `json:"latitude"`
-	Longitude  *float64 `json:"longitude"`
+	SchoolCode            string   `json:"school_code" binding:"max=10"`
+	Name                  string   `json:"name" binding:"required,max=100"`
+	CountyName            string   `json:"county_name" binding:"required"`
+	District              string   `json:"district" binding:"max=20"`
+	Address               string   `json:"address" binding:"max=255"`
+	Phone                 string   `json:"phone" binding:"max=20"`
+	Level                 string   `json:"level"`
+	IsIndigenousKeySchool bool     `json:"is_indigenous_key_school"`
+	UrbanizationLevel     string   `json:"urbanization_level"`
+	EnrollmentSize        *int     `json:"enrollment_size" binding:"omitempty,min=0"`
+	Latitude              *float64 `json:"latitude"`
+	Longitude             *float64 `json:"longitude"`
 }
 
-// UpdateSchoolRequest represents the request body for updating a school
+// UpdateSchoolRequest represents the request body for updating a school.
+// The MOE attributes are optional: those left out are not changed, so
+// clients that do not know about them keep them intact.
 type UpdateSchoolRequest struct {
-	Name       string   `json:"name" binding:"required,max=100"`
-	CountyName string   `json:"county_name" binding:"required"`
-	Address    string   `json:"address" binding:"max=255"`
-	Phone      string   `json:"phone" binding:"max=20"`
-	Latitude   *float64 `json:"latitude"`
-	Longitude  *float64 `json:"longitude"`
+	SchoolCode            *string  `json:"school_code" binding:"omitempty,max=10"`
+	Name                  string   `json:"name" binding:"required,max=100"`
+	CountyName            string   `json:"county_name" binding:"required"`
+	District              string   `json:"district" binding:"max=20"`
+	Address               string   `json:"address" binding:"max=255"`
+	Phone                 string   `json:"phone" binding:"max=20"`
+	Level                 *string  `json:"level"`
+	IsIndigenousKeySchool *bool    `json:"is_indigenous_key_school"`
+	UrbanizationLevel     *string  `json:"urbanization_level"`
+	EnrollmentSize        *int     `json:"enrollment_size" binding:"omitempty,min=0"`
+	Latitude              *float64 `json:"latitude"`
+	Longitude             *float64 `json:"longitude"`
+}
+
+// SchoolSearchParams represents query parameters for school search
+type SchoolSearchParams struct {
+	Page                  int    `form:"page"`
+	PageSize              int    `form:"page_size"`
+	Name                  string `form:"name"`
+	CountyName            string `form:"county_name"`
+	District              string `form:"district"`
+	SchoolCode            string `form:"school_code"`
+	Level                 string `form:"level"`
+	IsIndigenousKeySchool *bool  `form:"is_indigenous_key_school"`
+	UrbanizationLevel     string `form:"urbanization_level"`
+	MinEnrollment         int    `form:"min_enrollment"`
+	MaxEnrollment         int    `form:"max_enrollment"`
+}
+
+// School level constants
+const (
+	SchoolLevelElementary = "國小"
+	SchoolLevelJunior     = "國中"
+	SchoolLevelSenior     = "高中"
+)
+
+// SchoolLevels lists the valid school levels
+var SchoolLevels = []string{SchoolLevelElementary, SchoolLevelJunior, SchoolLevelSenior}
+
+// UrbanizationLevels lists the valid urbanisation classes, from the MOE
+// remote-area school classification plus 都市 for urban schools
+var UrbanizationLevels = []string{"都市", "一般", "偏遠", "特殊偏遠", "極度偏遠"}
+
+// IsValidSchoolLevel checks if a school level is valid (empty means unknown)
+func IsValidSchoolLevel(level string) bool {
+	return level == "" || containsString(SchoolLevels, level)
+}
+
+// IsValidUrbanizationLevel checks if an urbanisation class is valid (empty means unknown)
+func IsValidUrbanizationLevel(level string) bool {
+	return level == "" || containsString(UrbanizationLevels, level)
+}
+
+// InferSchoolLevel guesses the school level from its official name.
+// Combined schools such as 國民中小學 are left empty to be set by hand.
+func InferSchoolLevel(name string) string {
+	switch {
+	case strings.Contains(name, "中小學"):
+		return ""
+	case strings.Contains(name, "高級中學"), strings.Contains(name, "高中"):
+		return SchoolLevelSenior
+	case strings.Contains(name, "國民中學"), strings.Contains(name, "國中"):
+		return SchoolLevelJunior
+	case strings.Contains(name, "國民小學"), strings.Contains(name, "國小"):
+		return SchoolLevelElementary
+	}
+	return ""
+}
+
+// EnrollmentBands are the enrolment size groups used in statistics
+var EnrollmentBands = []struct {
+	Label string
+	Max   int // inclusive upper bound, 0 means no bound
+}{
+	{"50人以下", 50},
+	{"51-100人", 100},
+	{"101-300人", 300},
+	{"301-1000人", 1000},
+	{"1001人以上", 0},
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // SchoolResponse is the API response wrapper for a single school
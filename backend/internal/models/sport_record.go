@@ -33,6 +33,42 @@ type CreateSportRecordRequest struct {
 	Value       float64 `json:"value" binding:"required,gt=0"`
 	TestDate    string  `json:"test_date" binding:"required"`
 	Notes       string  `json:"notes" binding:"max=500"`
+	// DuplicatePolicy decides what happens when the student already has a
+	// record of this sport on the test date. Empty keeps both records, as
+	// manual entry always did, and reports the duplicate in the result.
+	DuplicatePolicy DuplicatePolicy `json:"duplicate_policy"`
+}
+
+// DuplicatePolicy controls what happens when a new sport record has the same
+// student, sport type and test date as an existing record
+type DuplicatePolicy string
+
+const (
+	DuplicatePolicySkip     DuplicatePolicy = "skip"      // keep the existing record
+	DuplicatePolicyReplace  DuplicatePolicy = "replace"   // overwrite the existing value (audited)
+	DuplicatePolicyKeepBoth DuplicatePolicy = "keep_both" // add the new record as well
+)
+
+// IsValidDuplicatePolicy checks if a policy is one of the supported policies
+func IsValidDuplicatePolicy(policy DuplicatePolicy) bool {
+	return policy == DuplicatePolicySkip || policy == DuplicatePolicyReplace || policy == DuplicatePolicyKeepBoth
+}
+
+// Outcomes of saving a sport record under a duplicate policy
+const (
+	RecordOutcomeCreated          = "created"
+	RecordOutcomeCreatedDuplicate = "created_duplicate" // created next to an existing record (keep_both)
+	RecordOutcomeSkipped          = "skipped"
+	RecordOutcomeReplaced         = "replaced"
+)
+
+// CreateSportRecordResult is the outcome of creating a sport record
+type CreateSportRecordResult struct {
+	Record  *SportRecord `json:"record"`
+	Outcome string       `json:"outcome"`
+	// DuplicateOf is the existing record of the same sport and test date,
+	// set for every outcome except created
+	DuplicateOf *uint `json:"duplicate_of,omitempty"`
 }
 
 // UpdateSportRecordRequest represents the request body for updating a sport record
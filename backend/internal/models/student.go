@@ -14,6 +14,7 @@ type Student struct {
 	Name          string         `gorm:"size:50;not null;index" json:"name" binding:"required,max=50"`
 	Grade         int            `gorm:"not null" json:"grade" binding:"required,min=1,max=12"`
 	Class         string         `gorm:"size:20" json:"class" binding:"max=20"`
+	ClassID       *uint          `gorm:"index" json:"class_id"`
 	Gender        string         `gorm:"size:10;not null" json:"gender" binding:"required,oneof=male female"`
 	BirthDate     *time.Time     `gorm:"type:date" json:"birth_date"`
 	CreatedAt     time.Time      `json:"created_at"`
@@ -21,6 +22,7 @@ type Student struct {
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 	School        School         `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
 	SportRecords  []SportRecord  `gorm:"foreignKey:StudentID" json:"sport_records,omitempty"`
+	ClassInfo     *Class         `gorm:"foreignKey:ClassID" json:"class_info,omitempty"`
 }
 
 // TableName specifies the table name for Student
@@ -35,6 +37,7 @@ type CreateStudentRequest struct {
 	Name          string `json:"name" binding:"required,max=50"`
 	Grade         int    `json:"grade" binding:"required,min=1,max=12"`
 	Class         string `json:"class" binding:"max=20"`
+	ClassID       *uint  `json:"class_id"`
 	Gender        string `json:"gender" binding:"required,oneof=male female"`
 	BirthDate     string `json:"birth_date"`
 }
@@ -45,6 +48,7 @@ type UpdateStudentRequest struct {
 	Name          string `json:"name" binding:"required,max=50"`
 	Grade         int    `json:"grade" binding:"required,min=1,max=12"`
 	Class         string `json:"class" binding:"max=20"`
+	ClassID       *uint  `json:"class_id"`
 	Gender        string `json:"gender" binding:"required,oneof=male female"`
 	BirthDate     string `json:"birth_date"`
 }
@@ -57,6 +61,7 @@ type StudentSearchParams struct {
 	SchoolID uint   `form:"school_id"`
 	Grade    int    `form:"grade"`
 	Gender   string `form:"gender"`
+	ClassID  uint   `form:"class_id"`
 }
 
 // StudentResponse is the API response wrapper for a single student
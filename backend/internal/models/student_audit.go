@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// StudentAudit tracks changes to student records for audit purposes
+type StudentAudit struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	StudentID uint      `gorm:"not null;index" json:"student_id"`
+	Action    string    `gorm:"size:30;not null" json:"action"`
+	Field     string    `gorm:"size:50" json:"field,omitempty"`
+	OldValue  string    `gorm:"size:255" json:"old_value,omitempty"`
+	NewValue  string    `gorm:"size:255" json:"new_value,omitempty"`
+	ChangedBy uint      `gorm:"not null" json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	Reason    string    `gorm:"size:255" json:"reason"`
+}
+
+// TableName specifies the table name for StudentAudit
+func (StudentAudit) TableName() string {
+	return "student_audits"
+}
+
+// StudentAudit action constants
+const (
+	StudentAuditActionMerge        = "merge"         // 其他學生的資料併入此學生
+	StudentAuditActionMergedInto   = "merged_into"   // 此學生被併入其他學生
+	StudentAuditActionUnmerge      = "unmerge"       // 合併已還原
+	StudentAuditActionNumberChange = "number_change" // 座號變更
+	StudentAuditActionImportUpdate = "import_update" // 批次匯入更新資料
+)
+
+// StudentAuditListResponse is the API response wrapper for student audit history
+type StudentAuditListResponse struct {
+	Data struct {
+		Audits []StudentAudit `json:"audits"`
+	} `json:"data"`
+}
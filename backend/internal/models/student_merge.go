@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// StudentMerge records a merge of a duplicate student into a surviving student.
+// The moved record, audit and number history IDs are kept so the merge can be reverted.
+type StudentMerge struct {
+	ID                    uint       `gorm:"primarykey" json:"id"`
+	SchoolID              uint       `gorm:"not null;index" json:"school_id"`
+	SurvivorID            uint       `gorm:"not null;index" json:"survivor_id"`
+	MergedID              uint       `gorm:"not null;index" json:"merged_id"`
+	MovedRecordIDs        []uint     `gorm:"serializer:json;type:text" json:"moved_record_ids"`
+	MovedAuditIDs         []uint     `gorm:"serializer:json;type:text" json:"moved_audit_ids"`
+	MovedNumberHistoryIDs []uint     `gorm:"serializer:json;type:text" json:"moved_number_history_ids"`
+	Reason                string     `gorm:"size:255" json:"reason"`
+	MergedBy              uint       `gorm:"not null" json:"merged_by"`
+	MergedAt              time.Time  `json:"merged_at"`
+	RevertedBy            *uint      `json:"reverted_by"`
+	RevertedAt            *time.Time `json:"reverted_at"`
+}
+
+// TableName specifies the table name for StudentMerge
+func (StudentMerge) TableName() string {
+	return "student_merges"
+}
+
+// MergeStudentRequest represents the request body for merging a duplicate student
+type MergeStudentRequest struct {
+	DuplicateID uint   `json:"duplicate_id" binding:"required"`
+	Reason      string `json:"reason" binding:"max=255"`
+}
+
+// DuplicateStudentPair represents two students that are likely the same child
+type DuplicateStudentPair struct {
+	StudentA       Student  `json:"student_a"`
+	StudentB       Student  `json:"student_b"`
+	RecordCountA   int      `json:"record_count_a"`
+	RecordCountB   int      `json:"record_count_b"`
+	NameSimilarity float64  `json:"name_similarity"`
+	Reasons        []string `json:"reasons"`
+}
+
+// DuplicateStudentListResponse is the API response wrapper for duplicate detection
+type DuplicateStudentListResponse struct {
+	Data struct {
+		Duplicates []DuplicateStudentPair `json:"duplicates"`
+		Total      int                    `json:"total"`
+	} `json:"data"`
+}
+
+// StudentMergeResponse is the API response wrapper for a single merge
+type StudentMergeResponse struct {
+	Data struct {
+		Merge StudentMerge `json:"merge"`
+	} `json:"data"`
+}
+
+// StudentMergeListResponse is the API response wrapper for merge history
+type StudentMergeListResponse struct {
+	Data struct {
+		Merges []StudentMerge `json:"merges"`
+	} `json:"data"`
+}
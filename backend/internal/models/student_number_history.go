@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// StudentNumberHistory records a student number that a student no longer uses
+type StudentNumberHistory struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	StudentID     uint      `gorm:"not null;index" json:"student_id"`
+	SchoolID      uint      `gorm:"not null;index:idx_school_former_number,priority:1" json:"school_id"`
+	StudentNumber string    `gorm:"size:20;not null;index:idx_school_former_number,priority:2" json:"student_number"`
+	ReplacedBy    string    `gorm:"size:20" json:"replaced_by"`
+	Source        string    `gorm:"size:20;not null" json:"source"`
+	MergeID       *uint     `gorm:"index" json:"merge_id,omitempty"`
+	ChangedBy     uint      `gorm:"not null" json:"changed_by"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// TableName specifies the table name for StudentNumberHistory
+func (StudentNumberHistory) TableName() string {
+	return "student_number_histories"
+}
+
+// StudentNumberHistory source constants
+const (
+	NumberHistorySourceUpdate = "update" // 座號於編輯學生時變更
+	NumberHistorySourceMerge  = "merge"  // 重複學生合併時保留其座號
+)
+
+// FormerNumberHolder is a student who used a given student number in the past
+type FormerNumberHolder struct {
+	Student   Student   `json:"student"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// StudentNumberLookup is the result of looking up a student number in a school
+type StudentNumberLookup struct {
+	StudentNumber string               `json:"student_number"`
+	Current       *Student             `json:"current"`
+	Former        []FormerNumberHolder `json:"former"`
+}
+
+// StudentNumberHistoryResponse is the API response wrapper for number history
+type StudentNumberHistoryResponse struct {
+	Data struct {
+		History []StudentNumberHistory `json:"history"`
+	} `json:"data"`
+}
+
+// StudentNumberLookupResponse is the API response wrapper for number lookup
+type StudentNumberLookupResponse struct {
+	Data StudentNumberLookup `json:"data"`
+}
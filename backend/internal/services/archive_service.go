@@ -0,0 +1,911 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ArchiveService writes the database to portable archives and restores them,
+// mapping the natural keys of the archive to the IDs of the target database
+type ArchiveService struct {
+	db *gorm.DB
+}
+
+// NewArchiveService creates a new ArchiveService
+func NewArchiveService(db *gorm.DB) *ArchiveService {
+	return &ArchiveService{db: db}
+}
+
+// ArchiveFilter selects schools by school code or name and by county; an
+// empty filter selects every school
+type ArchiveFilter struct {
+	Schools  []string
+	Counties []string
+}
+
+// matches reports whether the filter selects the school
+func (f ArchiveFilter) matches(code *string, county, name string) bool {
+	if len(f.Counties) > 0 {
+		found := false
+		for _, c := range f.Counties {
+			if c == county {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Schools) == 0 {
+		return true
+	}
+	for _, s := range f.Schools {
+		if s == name || (code != nil && strings.EqualFold(s, *code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveExportOptions controls what an export contains
+type ArchiveExportOptions struct {
+	Filter    ArchiveFilter
+	Source    string // names the exporting deployment in the header
+	SkipNorms bool
+}
+
+// ArchiveImportOptions controls what a restore writes
+type ArchiveImportOptions struct {
+	Filter    ArchiveFilter
+	SkipNorms bool
+	DryRun    bool // run the restore and roll it back
+}
+
+// ArchiveImportResult reports what a restore created and what it found
+// already present
+type ArchiveImportResult struct {
+	Header   models.ArchiveHeader `json:"header"`
+	DryRun   bool                 `json:"dry_run"`
+	Created  models.ArchiveCounts `json:"created"`
+	Existing models.ArchiveCounts `json:"existing"` // rows matched by natural key and left unchanged
+	Skipped  []string             `json:"skipped"`  // rows that conflict with the target and were not restored
+}
+
+// archiveWriter writes archive entries, one JSON value per line
+type archiveWriter struct {
+	enc *json.Encoder
+}
+
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &archiveWriter{enc: enc}
+}
+
+func (w *archiveWriter) write(kind string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return w.enc.Encode(models.ArchiveEntry{Kind: kind, Data: raw})
+}
+
+// countSchool adds the rows of an archived school to the counts
+func countSchool(counts *models.ArchiveCounts, school *models.ArchiveSchool) {
+	counts.Schools++
+	counts.Classes += len(school.Classes)
+	for _, student := range school.Students {
+		counts.Students++
+		counts.StudentAudits += len(student.Audits)
+		counts.NumberHistories += len(student.NumberHistory)
+		for _, record := range student.Records {
+			counts.Records++
+			counts.RecordAudits += len(record.Audits)
+		}
+	}
+}
+
+// Export writes the sport types, norm tables and selected schools to w
+func (s *ArchiveService) Export(w io.Writer, opts ArchiveExportOptions) (*models.ArchiveCounts, error) {
+	out := newArchiveWriter(w)
+	counts := &models.ArchiveCounts{}
+
+	if err := out.write(models.ArchiveEntryHeader, models.ArchiveHeader{
+		Format:    models.ArchiveFormat,
+		Version:   models.ArchiveVersion,
+		CreatedAt: time.Now(),
+		Source:    opts.Source,
+	}); err != nil {
+		return nil, err
+	}
+
+	var sportTypes []models.SportType
+	if err := s.db.Order("id").Find(&sportTypes).Error; err != nil {
+		return nil, fmt.Errorf("查詢運動項目失敗: %w", err)
+	}
+	sportNames := make(map[uint]string, len(sportTypes))
+	for _, sportType := range sportTypes {
+		sportNames[sportType.ID] = sportType.Name
+		if err := out.write(models.ArchiveEntrySportType, models.ArchiveSportType{
+			Name:        sportType.Name,
+			Category:    sportType.Category,
+			DefaultUnit: sportType.DefaultUnit,
+			ValueType:   sportType.ValueType,
+		}); err != nil {
+			return nil, err
+		}
+		counts.SportTypes++
+	}
+
+	if !opts.SkipNorms {
+		var tables []models.NormTable
+		if err := s.db.Preload("Entries").Order("version").Find(&tables).Error; err != nil {
+			return nil, fmt.Errorf("查詢常模失敗: %w", err)
+		}
+		for _, table := range tables {
+			archived := models.ArchiveNormTable{
+				Name:       table.Name,
+				Source:     table.Source,
+				Year:       table.Year,
+				FileName:   table.FileName,
+				Active:     table.Active,
+				ImportedBy: table.ImportedBy,
+				CreatedAt:  table.CreatedAt,
+				Entries:    make([]models.ArchiveNormEntry, 0, len(table.Entries)),
+			}
+			for _, entry := range table.Entries {
+				archived.Entries = append(archived.Entries, models.ArchiveNormEntry{
+					SportType:    sportNames[entry.SportTypeID],
+					Age:          entry.Age,
+					Gender:       entry.Gender,
+					AvgValue:     entry.AvgValue,
+					SampleCount:  entry.SampleCount,
+					Percentile25: entry.Percentile25,
+					Percentile50: entry.Percentile50,
+					Percentile75: entry.Percentile75,
+					Percentile90: entry.Percentile90,
+				})
+			}
+			if err := out.write(models.ArchiveEntryNormTable, archived); err != nil {
+				return nil, err
+			}
+			counts.NormTables++
+		}
+	}
+
+	var schools []models.School
+	if err := s.db.Order("county_name, name").Find(&schools).Error; err != nil {
+		return nil, fmt.Errorf("查詢學校失敗: %w", err)
+	}
+	for _, school := range schools {
+		if !opts.Filter.matches(school.SchoolCode, school.CountyName, school.Name) {
+			continue
+		}
+		archived, err := s.exportSchool(school, sportNames)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", school.Name, err)
+		}
+		if err := out.write(models.ArchiveEntrySchool, archived); err != nil {
+			return nil, err
+		}
+		countSchool(counts, archived)
+	}
+
+	if err := out.write(models.ArchiveEntryEnd, counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// exportSchool reads a school with its classes, students, records and audits
+func (s *ArchiveService) exportSchool(school models.School, sportNames map[uint]string) (*models.ArchiveSchool, error) {
+	archived := &models.ArchiveSchool{
+		SchoolCode:            school.SchoolCode,
+		Name:                  school.Name,
+		CountyName:            school.CountyName,
+		District:              school.District,
+		Address:               school.Address,
+		Phone:                 school.Phone,
+		Level:                 school.Level,
+		IsIndigenousKeySchool: school.IsIndigenousKeySchool,
+		UrbanizationLevel:     school.UrbanizationLevel,
+		EnrollmentSize:        school.EnrollmentSize,
+		Latitude:              school.Latitude,
+		Longitude:             school.Longitude,
+		LastRecordsUploadedAt: school.LastRecordsUploadedAt,
+		CreatedAt:             school.CreatedAt,
+		Classes:               []models.ArchiveClass{},
+		Students:              []models.ArchiveStudent{},
+	}
+
+	var classes []models.Class
+	if err := s.db.Where("school_id = ?", school.ID).Order("academic_year, grade, name").Find(&classes).Error; err != nil {
+		return nil, fmt.Errorf("查詢班級失敗: %w", err)
+	}
+	classKeys := make(map[uint]models.ArchiveClassKey, len(classes))
+	for _, class := range classes {
+		key := models.ArchiveClassKey{AcademicYear: class.AcademicYear, Grade: class.Grade, Name: class.Name}
+		classKeys[class.ID] = key
+		archived.Classes = append(archived.Classes, models.ArchiveClass{
+			ArchiveClassKey:     key,
+			HomeroomTeacherName: class.HomeroomTeacherName,
+			Capacity:            class.Capacity,
+			CreatedAt:           class.CreatedAt,
+		})
+	}
+
+	var students []models.Student
+	if err := s.db.Where("school_id = ?", school.ID).Order("grade, class, student_number, id").Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("查詢學生失敗: %w", err)
+	}
+
+	studentIndex := make(map[uint]int, len(students))
+	for i, student := range students {
+		studentIndex[student.ID] = i
+		archivedStudent := models.ArchiveStudent{
+			StudentNumber: student.StudentNumber,
+			Name:          student.Name,
+			Grade:         student.Grade,
+			Class:         student.Class,
+			Gender:        student.Gender,
+			CreatedAt:     student.CreatedAt,
+			Records:       []models.ArchiveRecord{},
+		}
+		if student.ClassID != nil {
+			if key, ok := classKeys[*student.ClassID]; ok {
+				archivedStudent.ClassKey = &key
+			}
+		}
+		if student.BirthDate != nil {
+			birthDate := student.BirthDate.Format("2006-01-02")
+			archivedStudent.BirthDate = &birthDate
+		}
+		archived.Students = append(archived.Students, archivedStudent)
+	}
+
+	for start := 0; start < len(students); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(students) {
+			end = len(students)
+		}
+		ids := make([]uint, 0, end-start)
+		for _, student := range students[start:end] {
+			ids = append(ids, student.ID)
+		}
+
+		var records []models.SportRecord
+		if err := s.db.Where("student_id IN ?", ids).Order("student_id, test_date, id").Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("查詢運動紀錄失敗: %w", err)
+		}
+		recordIDs := make([]uint, 0, len(records))
+		for _, record := range records {
+			recordIDs = append(recordIDs, record.ID)
+		}
+		recordAudits := make(map[uint][]models.ArchiveRecordAudit)
+		for i := 0; i < len(recordIDs); i += importBatchSize {
+			j := i + importBatchSize
+			if j > len(recordIDs) {
+				j = len(recordIDs)
+			}
+			var audits []models.SportRecordAudit
+			if err := s.db.Where("sport_record_id IN ?", recordIDs[i:j]).Order("changed_at, id").Find(&audits).Error; err != nil {
+				return nil, fmt.Errorf("查詢成績異動紀錄失敗: %w", err)
+			}
+			for _, audit := range audits {
+				recordAudits[audit.SportRecordID] = append(recordAudits[audit.SportRecordID], models.ArchiveRecordAudit{
+					OldValue:  audit.OldValue,
+					NewValue:  audit.NewValue,
+					ChangedBy: audit.ChangedBy,
+					ChangedAt: audit.ChangedAt,
+					Reason:    audit.Reason,
+				})
+			}
+		}
+		for _, record := range records {
+			student := &archived.Students[studentIndex[record.StudentID]]
+			student.Records = append(student.Records, models.ArchiveRecord{
+				SportType: sportNames[record.SportTypeID],
+				Value:     record.Value,
+				TestDate:  record.TestDate.Format("2006-01-02"),
+				Notes:     record.Notes,
+				CreatedAt: record.CreatedAt,
+				Audits:    recordAudits[record.ID],
+			})
+		}
+
+		var studentAudits []models.StudentAudit
+		if err := s.db.Where("student_id IN ?", ids).Order("changed_at, id").Find(&studentAudits).Error; err != nil {
+			return nil, fmt.Errorf("查詢學生異動紀錄失敗: %w", err)
+		}
+		for _, audit := range studentAudits {
+			student := &archived.Students[studentIndex[audit.StudentID]]
+			student.Audits = append(student.Audits, models.ArchiveStudentAudit{
+				Action:    audit.Action,
+				Field:     audit.Field,
+				OldValue:  audit.OldValue,
+				NewValue:  audit.NewValue,
+				ChangedBy: audit.ChangedBy,
+				ChangedAt: audit.ChangedAt,
+				Reason:    audit.Reason,
+			})
+		}
+
+		var histories []models.StudentNumberHistory
+		if err := s.db.Where("student_id IN ?", ids).Order("changed_at, id").Find(&histories).Error; err != nil {
+			return nil, fmt.Errorf("查詢座號歷史失敗: %w", err)
+		}
+		for _, history := range histories {
+			student := &archived.Students[studentIndex[history.StudentID]]
+			student.NumberHistory = append(student.NumberHistory, models.ArchiveStudentNumberHistory{
+				StudentNumber: history.StudentNumber,
+				ReplacedBy:    history.ReplacedBy,
+				Source:        history.Source,
+				ChangedBy:     history.ChangedBy,
+				ChangedAt:     history.ChangedAt,
+			})
+		}
+	}
+
+	return archived, nil
+}
+
+// Import restores an archive. Rows already present in the target, matched
+// by natural key, are kept unchanged; the whole restore runs in one
+// transaction that a dry run rolls back.
+func (s *ArchiveService) Import(r io.Reader, opts ArchiveImportOptions) (*ArchiveImportResult, error) {
+	dec := json.NewDecoder(r)
+
+	var entry models.ArchiveEntry
+	if err := dec.Decode(&entry); err != nil {
+		return nil, fmt.Errorf("無法讀取封存檔: %w", err)
+	}
+	var header models.ArchiveHeader
+	if entry.Kind != models.ArchiveEntryHeader || json.Unmarshal(entry.Data, &header) != nil ||
+		header.Format != models.ArchiveFormat {
+		return nil, fmt.Errorf("不是有效的封存檔")
+	}
+	if header.Version < 1 || header.Version > models.ArchiveVersion {
+		return nil, fmt.Errorf("不支援的封存檔版本: %d（支援至第 %d 版）", header.Version, models.ArchiveVersion)
+	}
+
+	result := &ArchiveImportResult{Header: header, DryRun: opts.DryRun, Skipped: []string{}}
+	restore := &archiveRestore{
+		result:     result,
+		opts:       opts,
+		sportTypes: make(map[string]uint),
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		restore.tx = tx
+		if err := restore.run(dec); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errArchiveDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errArchiveDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// errArchiveDryRun rolls back the transaction of a dry run
+var errArchiveDryRun = errors.New("dry run")
+
+// archiveRestore holds the state of one restore
+type archiveRestore struct {
+	tx         *gorm.DB
+	result     *ArchiveImportResult
+	opts       ArchiveImportOptions
+	sportTypes map[string]uint      // name -> ID in the target
+	read       models.ArchiveCounts // rows read, restored or not
+}
+
+// run restores the entries after the header
+func (r *archiveRestore) run(dec *json.Decoder) error {
+	ended := false
+	for {
+		var entry models.ArchiveEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("封存檔格式錯誤: %w", err)
+		}
+		if ended {
+			return fmt.Errorf("封存檔結尾之後還有資料")
+		}
+
+		var err error
+		switch entry.Kind {
+		case models.ArchiveEntrySportType:
+			var sportType models.ArchiveSportType
+			if err = json.Unmarshal(entry.Data, &sportType); err == nil {
+				err = r.restoreSportType(sportType)
+			}
+		case models.ArchiveEntryNormTable:
+			var table models.ArchiveNormTable
+			if err = json.Unmarshal(entry.Data, &table); err == nil {
+				err = r.restoreNormTable(table)
+			}
+		case models.ArchiveEntrySchool:
+			var school models.ArchiveSchool
+			if err = json.Unmarshal(entry.Data, &school); err == nil {
+				err = r.restoreSchool(&school)
+			}
+		case models.ArchiveEntryEnd:
+			var counts models.ArchiveCounts
+			if err = json.Unmarshal(entry.Data, &counts); err == nil && counts != r.read {
+				err = fmt.Errorf("封存檔內容與結尾記載的筆數不符，檔案可能已損毀")
+			}
+			ended = true
+		default:
+			err = fmt.Errorf("未知的封存資料類型: %s", entry.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ended {
+		return fmt.Errorf("封存檔不完整（缺少結尾），可能在匯出時中斷")
+	}
+	return nil
+}
+
+// restoreSportType maps a sport type to the target by name, creating it
+// when missing
+func (r *archiveRestore) restoreSportType(archived models.ArchiveSportType) error {
+	r.read.SportTypes++
+
+	var sportType models.SportType
+	err := r.tx.Where("name = ?", archived.Name).First(&sportType).Error
+	if err == nil {
+		r.result.Existing.SportTypes++
+		r.sportTypes[archived.Name] = sportType.ID
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查詢運動項目失敗: %w", err)
+	}
+
+	sportType = models.SportType{
+		Name:        archived.Name,
+		Category:    archived.Category,
+		DefaultUnit: archived.DefaultUnit,
+		ValueType:   archived.ValueType,
+	}
+	if err := r.tx.Create(&sportType).Error; err != nil {
+		return fmt.Errorf("建立運動項目 %s 失敗: %w", archived.Name, err)
+	}
+	r.result.Created.SportTypes++
+	r.sportTypes[archived.Name] = sportType.ID
+	return nil
+}
+
+// sportTypeID returns the target ID of an archived sport type
+func (r *archiveRestore) sportTypeID(name string) (uint, error) {
+	id, ok := r.sportTypes[name]
+	if !ok {
+		return 0, fmt.Errorf("運動項目 %s 不在封存檔中", name)
+	}
+	return id, nil
+}
+
+// restoreNormTable adds a norm table as a new version unless a table with
+// the same name, year and size exists. It is only activated when it was
+// active in the archive and the target has no active table.
+func (r *archiveRestore) restoreNormTable(archived models.ArchiveNormTable) error {
+	r.read.NormTables++
+	if r.opts.SkipNorms {
+		return nil
+	}
+
+	var existing int64
+	err := r.tx.Model(&models.NormTable{}).
+		Where("name = ? AND year = ? AND entry_count = ?", archived.Name, archived.Year, len(archived.Entries)).
+		Count(&existing).Error
+	if err != nil {
+		return fmt.Errorf("查詢常模失敗: %w", err)
+	}
+	if existing > 0 {
+		r.result.Existing.NormTables++
+		return nil
+	}
+
+	var maxVersion int
+	if err := r.tx.Model(&models.NormTable{}).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return fmt.Errorf("查詢常模版本失敗: %w", err)
+	}
+	table := models.NormTable{
+		Version:    maxVersion + 1,
+		Name:       archived.Name,
+		Source:     archived.Source,
+		Year:       archived.Year,
+		FileName:   archived.FileName,
+		EntryCount: len(archived.Entries),
+		ImportedBy: archived.ImportedBy,
+		CreatedAt:  archived.CreatedAt,
+	}
+	if archived.Active {
+		var active int64
+		if err := r.tx.Model(&models.NormTable{}).Where("active = ?", true).Count(&active).Error; err != nil {
+			return fmt.Errorf("查詢常模失敗: %w", err)
+		}
+		table.Active = active == 0
+	}
+	for _, entry := range archived.Entries {
+		sportTypeID, err := r.sportTypeID(entry.SportType)
+		if err != nil {
+			return fmt.Errorf("常模 %s: %w", archived.Name, err)
+		}
+		table.Entries = append(table.Entries, models.NormEntry{
+			SportTypeID:  sportTypeID,
+			Age:          entry.Age,
+			Gender:       entry.Gender,
+			AvgValue:     entry.AvgValue,
+			SampleCount:  entry.SampleCount,
+			Percentile25: entry.Percentile25,
+			Percentile50: entry.Percentile50,
+			Percentile75: entry.Percentile75,
+			Percentile90: entry.Percentile90,
+		})
+	}
+
+	if err := r.tx.Create(&table).Error; err != nil {
+		return fmt.Errorf("建立常模 %s 失敗: %w", archived.Name, err)
+	}
+	r.result.Created.NormTables++
+	return nil
+}
+
+// restoreSchool restores a selected school with its classes, students,
+// records and audits
+func (r *archiveRestore) restoreSchool(archived *models.ArchiveSchool) error {
+	countSchool(&r.read, archived)
+	if !r.opts.Filter.matches(archived.SchoolCode, archived.CountyName, archived.Name) {
+		return nil
+	}
+
+	schoolID, created, err := r.findOrCreateSchool(archived)
+	if err != nil {
+		return err
+	}
+	label := archived.CountyName + archived.Name
+	if schoolID == 0 {
+		r.result.Skipped = append(r.result.Skipped, fmt.Sprintf("%s: 學校代碼 %s 屬於已刪除的學校，未還原此學校與其學生",
+			label, *archived.SchoolCode))
+		return nil
+	}
+
+	classIDs, err := r.restoreClasses(schoolID, archived.Classes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+
+	// Existing students of the school by student number
+	existing := make(map[string][]models.Student)
+	if !created {
+		var students []models.Student
+		if err := r.tx.Where("school_id = ?", schoolID).Find(&students).Error; err != nil {
+			return fmt.Errorf("%s: 查詢學生失敗: %w", label, err)
+		}
+		for _, student := range students {
+			number := normalizeStudentNumber(student.StudentNumber)
+			existing[number] = append(existing[number], student)
+		}
+	}
+
+	// Match students by number and name; new students are created in batches
+	studentIDs := make([]uint, len(archived.Students))
+	var matchedIDs []uint
+	var newStudents []models.Student
+	var newIndexes []int
+	for i, student := range archived.Students {
+		number := normalizeStudentNumber(student.StudentNumber)
+		var match *models.Student
+		for j := range existing[number] {
+			if FoldName(existing[number][j].Name) == FoldName(student.Name) {
+				match = &existing[number][j]
+				break
+			}
+		}
+		if match != nil {
+			studentIDs[i] = match.ID
+			matchedIDs = append(matchedIDs, match.ID)
+			r.result.Existing.Students++
+			continue
+		}
+		if len(existing[number]) > 0 {
+			r.result.Skipped = append(r.result.Skipped, fmt.Sprintf("%s 學號 %s %s: 學號已由 %s 使用，未還原此學生與其成績",
+				label, student.StudentNumber, student.Name, existing[number][0].Name))
+			continue
+		}
+
+		newStudent := models.Student{
+			SchoolID:      schoolID,
+			StudentNumber: student.StudentNumber,
+			Name:          student.Name,
+			Grade:         student.Grade,
+			Class:         student.Class,
+			Gender:        student.Gender,
+			CreatedAt:     student.CreatedAt,
+		}
+		if student.ClassKey != nil {
+			if classID, ok := classIDs[*student.ClassKey]; ok {
+				newStudent.ClassID = &classID
+			}
+		}
+		if student.BirthDate != nil {
+			birthDate, err := time.Parse("2006-01-02", *student.BirthDate)
+			if err != nil {
+				return fmt.Errorf("%s 學號 %s: 生日格式錯誤: %s", label, student.StudentNumber, *student.BirthDate)
+			}
+			newStudent.BirthDate = &birthDate
+		}
+		newStudents = append(newStudents, newStudent)
+		newIndexes = append(newIndexes, i)
+	}
+	if len(newStudents) > 0 {
+		if err := r.tx.CreateInBatches(&newStudents, importBatchSize).Error; err != nil {
+			return fmt.Errorf("%s: 建立學生失敗: %w", label, err)
+		}
+	}
+	createdStudent := make(map[int]bool, len(newStudents))
+	for k, i := range newIndexes {
+		studentIDs[i] = newStudents[k].ID
+		createdStudent[i] = true
+	}
+	r.result.Created.Students += len(newStudents)
+
+	if err := r.restoreStudentHistory(archived, studentIDs, createdStudent, schoolID); err != nil {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	if err := r.restoreRecords(archived, studentIDs, matchedIDs); err != nil {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	return nil
+}
+
+// findOrCreateSchool finds the school by code, or by county and name among
+// schools without a code, and creates it when missing. It returns a zero ID
+// when the code belongs to a deleted school: codes stay unique across
+// deleted schools, and restoring the deleted one would undo its deletion.
+func (r *archiveRestore) findOrCreateSchool(archived *models.ArchiveSchool) (uint, bool, error) {
+	var school models.School
+	code := ""
+	if archived.SchoolCode != nil {
+		code = strings.TrimSpace(*archived.SchoolCode)
+	}
+
+	var err error
+	if code != "" {
+		err = r.tx.Where("school_code = ?", code).First(&school).Error
+	}
+	if code == "" || errors.Is(err, gorm.ErrRecordNotFound) {
+		err = r.tx.Where("county_name = ? AND name = ? AND (school_code IS NULL OR school_code = '')",
+			archived.CountyName, archived.Name).First(&school).Error
+	}
+	if err == nil {
+		r.result.Existing.Schools++
+		return school.ID, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, fmt.Errorf("查詢學校失敗: %w", err)
+	}
+	if code != "" {
+		var deleted int64
+		if err := r.tx.Unscoped().Model(&models.School{}).
+			Where("school_code = ? AND deleted_at IS NOT NULL", code).
+			Count(&deleted).Error; err != nil {
+			return 0, false, fmt.Errorf("查詢學校失敗: %w", err)
+		}
+		if deleted > 0 {
+			return 0, false, nil
+		}
+	}
+
+	school = models.School{
+		Name:                  archived.Name,
+		CountyName:            archived.CountyName,
+		District:              archived.District,
+		Address:               archived.Address,
+		Phone:                 archived.Phone,
+		Level:                 archived.Level,
+		IsIndigenousKeySchool: archived.IsIndigenousKeySchool,
+		UrbanizationLevel:     archived.UrbanizationLevel,
+		EnrollmentSize:        archived.EnrollmentSize,
+		Latitude:              archived.Latitude,
+		Longitude:             archived.Longitude,
+		LastRecordsUploadedAt: archived.LastRecordsUploadedAt,
+		CreatedAt:             archived.CreatedAt,
+	}
+	if code != "" {
+		school.SchoolCode = &code
+	}
+	if err := r.tx.Create(&school).Error; err != nil {
+		return 0, false, fmt.Errorf("建立學校 %s 失敗: %w", archived.Name, err)
+	}
+	r.result.Created.Schools++
+	return school.ID, true, nil
+}
+
+// restoreClasses maps the classes of a school, creating the missing ones
+func (r *archiveRestore) restoreClasses(schoolID uint, archived []models.ArchiveClass) (map[models.ArchiveClassKey]uint, error) {
+	var classes []models.Class
+	if err := r.tx.Where("school_id = ?", schoolID).Find(&classes).Error; err != nil {
+		return nil, fmt.Errorf("查詢班級失敗: %w", err)
+	}
+	ids := make(map[models.ArchiveClassKey]uint, len(classes))
+	for _, class := range classes {
+		ids[models.ArchiveClassKey{AcademicYear: class.AcademicYear, Grade: class.Grade, Name: class.Name}] = class.ID
+	}
+
+	for _, class := range archived {
+		if _, ok := ids[class.ArchiveClassKey]; ok {
+			r.result.Existing.Classes++
+			continue
+		}
+		created := models.Class{
+			SchoolID:            schoolID,
+			AcademicYear:        class.AcademicYear,
+			Grade:               class.Grade,
+			Name:                class.Name,
+			HomeroomTeacherName: class.HomeroomTeacherName,
+			Capacity:            class.Capacity,
+			CreatedAt:           class.CreatedAt,
+		}
+		if err := r.tx.Create(&created).Error; err != nil {
+			return nil, fmt.Errorf("建立班級失敗: %w", err)
+		}
+		ids[class.ArchiveClassKey] = created.ID
+		r.result.Created.Classes++
+	}
+	return ids, nil
+}
+
+// restoreStudentHistory restores the audits and former numbers of the
+// students the restore created; existing students keep their own history
+func (r *archiveRestore) restoreStudentHistory(archived *models.ArchiveSchool, studentIDs []uint, created map[int]bool, schoolID uint) error {
+	var audits []models.StudentAudit
+	var histories []models.StudentNumberHistory
+	for i, student := range archived.Students {
+		if !created[i] {
+			continue
+		}
+		for _, audit := range student.Audits {
+			audits = append(audits, models.StudentAudit{
+				StudentID: studentIDs[i],
+				Action:    audit.Action,
+				Field:     audit.Field,
+				OldValue:  audit.OldValue,
+				NewValue:  audit.NewValue,
+				ChangedBy: audit.ChangedBy,
+				ChangedAt: audit.ChangedAt,
+				Reason:    audit.Reason,
+			})
+		}
+		for _, history := range student.NumberHistory {
+			histories = append(histories, models.StudentNumberHistory{
+				StudentID:     studentIDs[i],
+				SchoolID:      schoolID,
+				StudentNumber: history.StudentNumber,
+				ReplacedBy:    history.ReplacedBy,
+				Source:        history.Source,
+				ChangedBy:     history.ChangedBy,
+				ChangedAt:     history.ChangedAt,
+			})
+		}
+	}
+
+	if len(audits) > 0 {
+		if err := r.tx.CreateInBatches(&audits, importBatchSize).Error; err != nil {
+			return fmt.Errorf("建立學生異動紀錄失敗: %w", err)
+		}
+	}
+	if len(histories) > 0 {
+		if err := r.tx.CreateInBatches(&histories, importBatchSize).Error; err != nil {
+			return fmt.Errorf("建立座號歷史失敗: %w", err)
+		}
+	}
+	r.result.Created.StudentAudits += len(audits)
+	r.result.Created.NumberHistories += len(histories)
+	return nil
+}
+
+// restoreRecords creates the records of the restored students with their
+// audits. Records already stored for a student with the same sport type,
+// test date and value are left as they are.
+func (r *archiveRestore) restoreRecords(archived *models.ArchiveSchool, studentIDs, matchedIDs []uint) error {
+	existing := make(map[string]bool)
+	for start := 0; start < len(matchedIDs); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(matchedIDs) {
+			end = len(matchedIDs)
+		}
+		var records []models.SportRecord
+		if err := r.tx.Where("student_id IN ?", matchedIDs[start:end]).Find(&records).Error; err != nil {
+			return fmt.Errorf("查詢運動紀錄失敗: %w", err)
+		}
+		for _, record := range records {
+			existing[archiveRecordKey(record.StudentID, record.SportTypeID, record.TestDate, record.Value)] = true
+		}
+	}
+
+	var records []models.SportRecord
+	var recordAudits [][]models.ArchiveRecordAudit
+	for i, student := range archived.Students {
+		if studentIDs[i] == 0 {
+			continue // skipped conflict
+		}
+		for _, record := range student.Records {
+			sportTypeID, err := r.sportTypeID(record.SportType)
+			if err != nil {
+				return err
+			}
+			testDate, err := time.Parse("2006-01-02", record.TestDate)
+			if err != nil {
+				return fmt.Errorf("學號 %s: 測驗日期格式錯誤: %s", student.StudentNumber, record.TestDate)
+			}
+
+			key := archiveRecordKey(studentIDs[i], sportTypeID, testDate, record.Value)
+			if existing[key] {
+				r.result.Existing.Records++
+				continue
+			}
+
+			records = append(records, models.SportRecord{
+				StudentID:   studentIDs[i],
+				SportTypeID: sportTypeID,
+				Value:       record.Value,
+				TestDate:    testDate,
+				Notes:       record.Notes,
+				CreatedAt:   record.CreatedAt,
+			})
+			recordAudits = append(recordAudits, record.Audits)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := r.tx.CreateInBatches(&records, importBatchSize).Error; err != nil {
+		return fmt.Errorf("建立運動紀錄失敗: %w", err)
+	}
+	r.result.Created.Records += len(records)
+
+	var audits []models.SportRecordAudit
+	for i, recordAudit := range recordAudits {
+		for _, audit := range recordAudit {
+			audits = append(audits, models.SportRecordAudit{
+				SportRecordID: records[i].ID,
+				OldValue:      audit.OldValue,
+				NewValue:      audit.NewValue,
+				ChangedBy:     audit.ChangedBy,
+				ChangedAt:     audit.ChangedAt,
+				Reason:        audit.Reason,
+			})
+		}
+	}
+	if len(audits) > 0 {
+		if err := r.tx.CreateInBatches(&audits, importBatchSize).Error; err != nil {
+			return fmt.Errorf("建立成績異動紀錄失敗: %w", err)
+		}
+	}
+	r.result.Created.RecordAudits += len(audits)
+	return nil
+}
+
+// archiveRecordKey identifies a record by student, sport type, test date and value
+func archiveRecordKey(studentID, sportTypeID uint, testDate time.Time, value float64) string {
+	return fmt.Sprintf("%s|%.2f", recordKey(studentID, sportTypeID, testDate), value)
+}
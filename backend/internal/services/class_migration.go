@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MigrateStudentClasses normalizes the free-text class of existing students and
+// links each of them to a Class of the current academic year, creating classes
+// as needed. Students already linked to a class are left alone, so it is safe
+// to run on every start, after the database migrations.
+func MigrateStudentClasses(db *gorm.DB) error {
+	var students []models.Student
+	err := db.Where("class_id IS NULL AND class IS NOT NULL AND class != ''").
+		Find(&students).Error
+	if err != nil {
+		return fmt.Errorf("failed to load students: %w", err)
+	}
+	if len(students) == 0 {
+		return nil
+	}
+
+	academicYear := CurrentAcademicYear()
+	linked := 0
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, student := range students {
+			class, err := ResolveClass(tx, student.SchoolID, academicYear, student.Grade, student.Class)
+			if err != nil {
+				return err
+			}
+			if class == nil {
+				continue
+			}
+
+			if err := tx.Model(&models.Student{}).
+				Where("id = ?", student.ID).
+				Updates(map[string]interface{}{"class_id": class.ID, "class": class.Name}).Error; err != nil {
+				return fmt.Errorf("failed to link student %d to class: %w", student.ID, err)
+			}
+			linked++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Linked %d students to classes (學年度 %d)\n", linked, academicYear)
+	return nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/width"
+)
+
+// chineseDigits maps Chinese numerals used in class names to their values
+var chineseDigits = map[rune]int{
+	'一': 1, '二': 2, '三': 3, '四': 4, '五': 5,
+	'六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// heavenlyStems maps 甲乙丙… class names to their ordinal values
+var heavenlyStems = map[rune]int{
+	'甲': 1, '乙': 2, '丙': 3, '丁': 4, '戊': 5,
+	'己': 6, '庚': 7, '辛': 8, '壬': 9, '癸': 10,
+}
+
+// NormalizeClassName converts the many ways a class is written into one
+// canonical name, so "1", "01", "一班", "甲" and "3年1班" all become "1".
+// Names that are not ordinal (e.g. "音樂班") are returned trimmed but unchanged.
+func NormalizeClassName(class string) string {
+	name := strings.TrimSpace(width.Narrow.String(class))
+	if name == "" {
+		return ""
+	}
+
+	// Drop a grade prefix such as "3年" and the 班 suffix
+	ordinal := name
+	if idx := strings.LastIndex(ordinal, "年"); idx >= 0 && idx+len("年") < len(ordinal) {
+		ordinal = ordinal[idx+len("年"):]
+	}
+	ordinal = strings.TrimSpace(strings.TrimSuffix(ordinal, "班"))
+
+	if n, err := strconv.Atoi(ordinal); err == nil && n >= 0 {
+		return strconv.Itoa(n)
+	}
+	if n, ok := parseChineseNumber(ordinal); ok {
+		return strconv.Itoa(n)
+	}
+	if runes := []rune(ordinal); len(runes) == 1 {
+		if n, ok := heavenlyStems[runes[0]]; ok {
+			return strconv.Itoa(n)
+		}
+	}
+
+	return name
+}
+
+// parseChineseNumber parses Chinese numerals from 一 to 九十九
+func parseChineseNumber(s string) (int, bool) {
+	runes := []rune(s)
+	switch len(runes) {
+	case 1:
+		if runes[0] == '十' {
+			return 10, true
+		}
+		n, ok := chineseDigits[runes[0]]
+		return n, ok
+	case 2:
+		if runes[0] == '十' {
+			n, ok := chineseDigits[runes[1]]
+			return 10 + n, ok
+		}
+		if runes[1] == '十' {
+			n, ok := chineseDigits[runes[0]]
+			return n * 10, ok
+		}
+	case 3:
+		if runes[1] == '十' {
+			tens, ok1 := chineseDigits[runes[0]]
+			ones, ok2 := chineseDigits[runes[2]]
+			return tens*10 + ones, ok1 && ok2
+		}
+	}
+	return 0, false
+}
+
+// ParseSheetClass reads the grade and class from a worksheet name such as
+// "3年1班", "三年甲班", "3年級1班", "3-1" or "301". A name with only a grade
+// ("3年級") returns an empty class, meaning the whole grade. It reports false
+// for names that are not a class, such as "使用說明".
+func ParseSheetClass(name string) (int, string, bool) {
+	s := strings.TrimSpace(width.Narrow.String(name))
+	if s == "" {
+		return 0, "", false
+	}
+
+	var gradePart, classPart string
+	if idx := strings.Index(s, "年"); idx > 0 {
+		gradePart = s[:idx]
+		classPart = strings.TrimPrefix(s[idx+len("年"):], "級")
+	} else if idx := strings.IndexAny(s, "-_ "); idx > 0 {
+		gradePart = s[:idx]
+		classPart = s[idx+1:]
+		if strings.TrimSpace(classPart) == "" {
+			return 0, "", false
+		}
+	} else if n, err := strconv.Atoi(s); err == nil && (len(s) == 3 || len(s) == 4) && n%100 > 0 {
+		// Class codes: 301 is grade 3 class 1, 1205 is grade 12 class 5
+		gradePart = strconv.Itoa(n / 100)
+		classPart = strconv.Itoa(n % 100)
+	} else {
+		return 0, "", false
+	}
+
+	gradePart = strings.TrimSpace(gradePart)
+	grade, err := strconv.Atoi(gradePart)
+	if err != nil {
+		n, ok := parseChineseNumber(gradePart)
+		if !ok {
+			return 0, "", false
+		}
+		grade = n
+	}
+	if grade < 1 || grade > 12 {
+		return 0, "", false
+	}
+
+	return grade, NormalizeClassName(classPart), true
+}
+
+// ClassSheetName returns the worksheet name used for a class in whole-school
+// workbooks, e.g. "3年1班", "3年音樂班" or "3年級" for students without a class.
+// ParseSheetClass reads it back.
+func ClassSheetName(grade int, class string) string {
+	if class == "" {
+		return fmt.Sprintf("%d年級", grade)
+	}
+	if _, err := strconv.Atoi(class); err == nil {
+		return fmt.Sprintf("%d年%s班", grade, class)
+	}
+	return safeSheetName(fmt.Sprintf("%d年%s", grade, class))
+}
+
+// AcademicYear returns the ROC academic year (學年度) that the given date falls in.
+// An academic year starts on August 1, so 2024-09-01 belongs to 113 and
+// 2025-03-01 also belongs to 113.
+func AcademicYear(t time.Time) int {
+	year := t.Year() - 1911
+	if t.Month() < time.August {
+		year--
+	}
+	return year
+}
+
+// CurrentAcademicYear returns the ROC academic year for today
+func CurrentAcademicYear() int {
+	return AcademicYear(time.Now())
+}
+
+// AcademicTerm returns the term (學期) label of the given date, e.g. "113-1".
+// The first term runs from August to January, the second from February to July.
+func AcademicTerm(t time.Time) string {
+	semester := 2
+	if t.Month() >= time.August || t.Month() == time.January {
+		semester = 1
+	}
+	return fmt.Sprintf("%d-%d", AcademicYear(t), semester)
+}
+
+// ParseAcademicTerm reads a term label such as "113-1" or "113-2", or an
+// academic year such as "113" for both terms, and returns the first day of
+// the period and the first day after it
+func ParseAcademicTerm(term string) (time.Time, time.Time, error) {
+	yearPart, semesterPart, hasSemester := strings.Cut(strings.TrimSpace(term), "-")
+	year, err := strconv.Atoi(yearPart)
+	if err != nil || year < 1 {
+		return time.Time{}, time.Time{}, fmt.Errorf("學期格式錯誤: %s（例如 113-1 或 113）", term)
+	}
+
+	start := time.Date(year+1911, time.August, 1, 0, 0, 0, 0, time.UTC)
+	if !hasSemester {
+		return start, start.AddDate(1, 0, 0), nil
+	}
+
+	semester, err := strconv.Atoi(semesterPart)
+	if err != nil || (semester != 1 && semester != 2) {
+		return time.Time{}, time.Time{}, fmt.Errorf("學期格式錯誤: %s（例如 113-1 或 113）", term)
+	}
+	if semester == 2 {
+		start = time.Date(year+1912, time.February, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return start, start.AddDate(0, 6, 0), nil
+}
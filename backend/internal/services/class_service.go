@@ -0,0 +1,378 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClassService handles business logic for class operations
+type ClassService struct {
+	db *gorm.DB
+}
+
+// NewClassService creates a new ClassService instance
+func NewClassService(db *gorm.DB) *ClassService {
+	return &ClassService{
+		db: db,
+	}
+}
+
+// classWithCountSelect selects classes with the number of students in each
+const classWithCountSelect = "classes.*, (SELECT COUNT(*) FROM students WHERE students.class_id = classes.id AND students.deleted_at IS NULL) as student_count"
+
+// ResolveClass finds the class with the given (normalized) name in a school,
+// academic year and grade, creating it when it does not exist yet.
+// It returns nil when the class name is empty.
+//
+// A class is unique per school, academic year, grade and name, so when a
+// concurrent import creates the same class first, the insert fails on the
+// unique index and the class it created is read back instead.
+func ResolveClass(db *gorm.DB, schoolID uint, academicYear int, grade int, className string) (*models.Class, error) {
+	name := NormalizeClassName(className)
+	if name == "" {
+		return nil, nil
+	}
+
+	class, err := findClass(db, schoolID, academicYear, grade, name)
+	if err != nil {
+		return nil, err
+	}
+	if class != nil {
+		return class, nil
+	}
+
+	if err := releaseDeletedClass(db, schoolID, academicYear, grade, name); err != nil {
+		return nil, err
+	}
+
+	class = &models.Class{
+		SchoolID:     schoolID,
+		AcademicYear: academicYear,
+		Grade:        grade,
+		Name:         name,
+	}
+	if createErr := db.Create(class).Error; createErr != nil {
+		// A locking read sees the row committed by the concurrent insert,
+		// which a consistent read inside this transaction might not
+		existing, err := findClass(db.Clauses(clause.Locking{Strength: "SHARE"}), schoolID, academicYear, grade, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, fmt.Errorf("failed to resolve class: %w", createErr)
+		}
+		return existing, nil
+	}
+
+	return class, nil
+}
+
+// findClass returns the class with the given key, or nil when there is none
+func findClass(db *gorm.DB, schoolID uint, academicYear, grade int, name string) (*models.Class, error) {
+	var class models.Class
+	err := db.Where("school_id = ? AND academic_year = ? AND grade = ? AND name = ?",
+		schoolID, academicYear, grade, name).
+		First(&class).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve class: %w", err)
+	}
+	return &class, nil
+}
+
+// releaseDeletedClass permanently removes a soft-deleted class with the given
+// key, since the unique index also covers deleted rows and would otherwise
+// keep the name from being used again. Deleted classes have no students left,
+// but soft-deleted students may still point to it and are unlinked.
+func releaseDeletedClass(db *gorm.DB, schoolID uint, academicYear, grade int, name string) error {
+	var deleted []models.Class
+	err := db.Unscoped().
+		Where("school_id = ? AND academic_year = ? AND grade = ? AND name = ? AND deleted_at IS NOT NULL",
+			schoolID, academicYear, grade, name).
+		Find(&deleted).Error
+	if err != nil {
+		return fmt.Errorf("failed to check deleted classes: %w", err)
+	}
+
+	for _, class := range deleted {
+		if err := db.Unscoped().Model(&models.Student{}).
+			Where("class_id = ?", class.ID).
+			Update("class_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to unlink students: %w", err)
+		}
+		if err := db.Unscoped().Delete(&class).Error; err != nil {
+			return fmt.Errorf("failed to remove deleted class: %w", err)
+		}
+	}
+	return nil
+}
+
+// List retrieves classes matching the search parameters
+func (s *ClassService) List(params *models.ClassSearchParams) ([]models.Class, error) {
+	query := s.db.Model(&models.Class{}).Select(classWithCountSelect)
+
+	if params.SchoolID > 0 {
+		query = query.Where("classes.school_id = ?", params.SchoolID)
+	}
+	if params.AcademicYear > 0 {
+		query = query.Where("classes.academic_year = ?", params.AcademicYear)
+	}
+	if params.Grade > 0 {
+		query = query.Where("classes.grade = ?", params.Grade)
+	}
+
+	var classes []models.Class
+	err := query.
+		Order("classes.academic_year DESC, classes.grade ASC, LENGTH(classes.name) ASC, classes.name ASC").
+		Find(&classes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list classes: %w", err)
+	}
+
+	return classes, nil
+}
+
+// GetByID retrieves a class by ID
+func (s *ClassService) GetByID(id uint) (*models.Class, error) {
+	var class models.Class
+	err := s.db.Model(&models.Class{}).
+		Select(classWithCountSelect).
+		Preload("School").
+		First(&class, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("class not found")
+		}
+		return nil, fmt.Errorf("failed to get class: %w", err)
+	}
+	return &class, nil
+}
+
+// Create creates a new class
+func (s *ClassService) Create(req *models.CreateClassRequest) (*models.Class, error) {
+	var school models.School
+	if err := s.db.First(&school, req.SchoolID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("學校不存在")
+		}
+		return nil, fmt.Errorf("failed to check school: %w", err)
+	}
+
+	academicYear := req.AcademicYear
+	if academicYear == 0 {
+		academicYear = CurrentAcademicYear()
+	}
+
+	name := NormalizeClassName(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("班級名稱不能為空")
+	}
+
+	if err := s.checkDuplicate(req.SchoolID, academicYear, req.Grade, name, 0); err != nil {
+		return nil, err
+	}
+	if err := releaseDeletedClass(s.db, req.SchoolID, academicYear, req.Grade, name); err != nil {
+		return nil, err
+	}
+
+	class := &models.Class{
+		SchoolID:            req.SchoolID,
+		AcademicYear:        academicYear,
+		Grade:               req.Grade,
+		Name:                name,
+		HomeroomTeacherID:   req.HomeroomTeacherID,
+		HomeroomTeacherName: req.HomeroomTeacherName,
+		Capacity:            req.Capacity,
+	}
+
+	if err := s.db.Create(class).Error; err != nil {
+		return nil, fmt.Errorf("failed to create class: %w", err)
+	}
+
+	return s.GetByID(class.ID)
+}
+
+// Update updates an existing class. Renaming a class also renames the class
+// of every student on its roster.
+func (s *ClassService) Update(id uint, req *models.UpdateClassRequest) (*models.Class, error) {
+	var class models.Class
+	if err := s.db.First(&class, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("class not found")
+		}
+		return nil, fmt.Errorf("failed to get class: %w", err)
+	}
+
+	name := NormalizeClassName(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("班級名稱不能為空")
+	}
+
+	if name != class.Name {
+		if err := s.checkDuplicate(class.SchoolID, class.AcademicYear, class.Grade, name, class.ID); err != nil {
+			return nil, err
+		}
+		if err := releaseDeletedClass(s.db, class.SchoolID, class.AcademicYear, class.Grade, name); err != nil {
+			return nil, err
+		}
+	}
+
+	class.Name = name
+	class.HomeroomTeacherID = req.HomeroomTeacherID
+	class.HomeroomTeacherName = req.HomeroomTeacherName
+	class.Capacity = req.Capacity
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("School").Save(&class).Error; err != nil {
+			return fmt.Errorf("failed to update class: %w", err)
+		}
+		if err := tx.Model(&models.Student{}).
+			Where("class_id = ?", class.ID).
+			Update("class", class.Name).Error; err != nil {
+			return fmt.Errorf("failed to update students: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(class.ID)
+}
+
+// Delete soft deletes a class that has no students
+func (s *ClassService) Delete(id uint) error {
+	var class models.Class
+	if err := s.db.First(&class, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("class not found")
+		}
+		return fmt.Errorf("failed to get class: %w", err)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Student{}).Where("class_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count students: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("班級仍有學生，無法刪除")
+	}
+
+	if err := s.db.Delete(&class).Error; err != nil {
+		return fmt.Errorf("failed to delete class: %w", err)
+	}
+
+	return nil
+}
+
+// GetRoster retrieves a class with its students ordered by student number
+func (s *ClassService) GetRoster(id uint) (*models.Class, []models.Student, error) {
+	class, err := s.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var students []models.Student
+	err = s.db.Where("class_id = ?", id).
+		Order("LENGTH(student_number) ASC, student_number ASC").
+		Find(&students).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get roster: %w", err)
+	}
+
+	return class, students, nil
+}
+
+// AssignStudents adds students to a class. Students must belong to the same
+// school and grade, and the class capacity (when set) may not be exceeded.
+func (s *ClassService) AssignStudents(id uint, req *models.AssignStudentsRequest) (*models.Class, []models.Student, error) {
+	var class models.Class
+	if err := s.db.First(&class, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, fmt.Errorf("class not found")
+		}
+		return nil, nil, fmt.Errorf("failed to get class: %w", err)
+	}
+
+	var students []models.Student
+	if err := s.db.Where("id IN ?", req.StudentIDs).Find(&students).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get students: %w", err)
+	}
+
+	found := make(map[uint]bool, len(students))
+	for _, student := range students {
+		found[student.ID] = true
+	}
+	for _, studentID := range req.StudentIDs {
+		if !found[studentID] {
+			return nil, nil, fmt.Errorf("找不到 ID 為 %d 的學生", studentID)
+		}
+	}
+
+	joining := 0
+	for _, student := range students {
+		if student.SchoolID != class.SchoolID {
+			return nil, nil, fmt.Errorf("學生 %s 不屬於此班級的學校", student.Name)
+		}
+		if student.Grade != class.Grade {
+			return nil, nil, fmt.Errorf("學生 %s 的年級與班級年級不符", student.Name)
+		}
+		if student.ClassID == nil || *student.ClassID != class.ID {
+			joining++
+		}
+	}
+
+	if class.Capacity > 0 {
+		var current int64
+		if err := s.db.Model(&models.Student{}).Where("class_id = ?", class.ID).Count(&current).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to count students: %w", err)
+		}
+		if int(current)+joining > class.Capacity {
+			return nil, nil, fmt.Errorf("班級人數已達上限（%d 人）", class.Capacity)
+		}
+	}
+
+	err := s.db.Model(&models.Student{}).
+		Where("id IN ?", req.StudentIDs).
+		Updates(map[string]interface{}{"class_id": class.ID, "class": class.Name}).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to assign students: %w", err)
+	}
+
+	return s.GetRoster(class.ID)
+}
+
+// RemoveStudent removes a student from a class roster
+func (s *ClassService) RemoveStudent(id uint, studentID uint) error {
+	result := s.db.Model(&models.Student{}).
+		Where("id = ? AND class_id = ?", studentID, id).
+		Updates(map[string]interface{}{"class_id": nil, "class": ""})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove student: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("學生不在此班級")
+	}
+	return nil
+}
+
+// checkDuplicate returns an error if another class with the same name exists
+func (s *ClassService) checkDuplicate(schoolID uint, academicYear, grade int, name string, excludeID uint) error {
+	var count int64
+	err := s.db.Model(&models.Class{}).
+		Where("school_id = ? AND academic_year = ? AND grade = ? AND name = ? AND id != ?",
+			schoolID, academicYear, grade, name, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check class: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此班級已存在")
+	}
+	return nil
+}
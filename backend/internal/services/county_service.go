@@ -145,6 +145,122 @@ func (s *CountyService) GetCountyStatistics(countyName string) (*models.CountySt
 	return &stats, nil
 }
 
+// GetDistrictStatistics retrieves statistics for every official district of a
+// county with Redis caching. Districts without schools are included with zero counts.
+// Cache key: "county:stats:{countyName}:districts"
+// TTL: 900 seconds (15 minutes)
+func (s *CountyService) GetDistrictStatistics(countyName string) (*models.AllDistrictStatistics, error) {
+	if !models.IsValidCountyName(countyName) {
+		return nil, fmt.Errorf("invalid county name: %s", countyName)
+	}
+
+	cacheKey := fmt.Sprintf("county:stats:%s:districts", countyName)
+	var cached models.AllDistrictStatistics
+	hit, err := s.getCachedJSON(cacheKey, &cached)
+	if err != nil {
+		fmt.Printf("Warning: Redis cache error for districts of %s (will query database): %v\n", countyName, err)
+	}
+	if hit {
+		fmt.Printf("Cache hit for district statistics: %s\n", countyName)
+		return &cached, nil
+	}
+
+	fmt.Printf("Cache miss for district statistics: %s - querying database\n", countyName)
+
+	var rows []models.DistrictStatistics
+	err = s.districtStatsQuery().
+		Where("schools.county_name = ? AND schools.deleted_at IS NULL", countyName).
+		Group("schools.county_name, schools.district").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %w", err)
+	}
+
+	byDistrict := make(map[string]models.DistrictStatistics, len(rows))
+	for _, row := range rows {
+		byDistrict[row.District] = row
+	}
+
+	// Follow the official district order; schools without a district are left out
+	stats := make([]models.DistrictStatistics, 0, len(models.TaiwanDistricts[countyName]))
+	for _, district := range models.TaiwanDistricts[countyName] {
+		row, exists := byDistrict[district]
+		if !exists {
+			row = models.DistrictStatistics{CountyName: countyName, District: district}
+		}
+		stats = append(stats, row)
+	}
+
+	result := &models.AllDistrictStatistics{
+		CountyName: countyName,
+		Districts:  stats,
+		Total:      len(stats),
+	}
+
+	if err := s.cacheJSON(cacheKey, result); err != nil {
+		fmt.Printf("Warning: Failed to cache district statistics for %s: %v\n", countyName, err)
+	}
+
+	return result, nil
+}
+
+// GetSingleDistrictStatistics retrieves statistics for one district with Redis caching
+// Cache key: "county:stats:{countyName}:district:{district}"
+// TTL: 900 seconds (15 minutes)
+func (s *CountyService) GetSingleDistrictStatistics(countyName, district string) (*models.DistrictStatistics, error) {
+	if !models.IsValidDistrict(countyName, district) {
+		return nil, fmt.Errorf("invalid district: %s %s", countyName, district)
+	}
+
+	cacheKey := fmt.Sprintf("county:stats:%s:district:%s", countyName, district)
+	var cached models.DistrictStatistics
+	hit, err := s.getCachedJSON(cacheKey, &cached)
+	if err != nil {
+		fmt.Printf("Warning: Redis cache error for district %s %s (will query database): %v\n", countyName, district, err)
+	}
+	if hit {
+		return &cached, nil
+	}
+
+	var stats models.DistrictStatistics
+	err = s.districtStatsQuery().
+		Where("schools.county_name = ? AND schools.district = ? AND schools.deleted_at IS NULL", countyName, district).
+		Group("schools.county_name, schools.district").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %w", err)
+	}
+
+	// If no data found for the district, return empty stats with has_data = false
+	if stats.District == "" {
+		stats = models.DistrictStatistics{
+			CountyName: countyName,
+			District:   district,
+		}
+	}
+
+	if err := s.cacheJSON(cacheKey, &stats); err != nil {
+		fmt.Printf("Warning: Failed to cache district statistics for %s %s: %v\n", countyName, district, err)
+	}
+
+	return &stats, nil
+}
+
+// districtStatsQuery builds the aggregation query shared by the district statistics
+func (s *CountyService) districtStatsQuery() *gorm.DB {
+	return s.db.Table("schools").
+		Select(`
+			schools.county_name,
+			schools.district,
+			COUNT(DISTINCT schools.id) as school_count,
+			COUNT(DISTINCT students.id) as student_count,
+			COUNT(sport_records.id) as record_count,
+			(COUNT(DISTINCT schools.id) > 0) as has_data
+		`).
+		Joins("LEFT JOIN students ON students.school_id = schools.id AND students.deleted_at IS NULL").
+		Joins("LEFT JOIN sport_records ON sport_records.student_id = students.id AND sport_records.deleted_at IS NULL")
+}
+
 // Helper methods for caching (to be implemented in user story tasks)
 
 func (s *CountyService) getCachedAllStats() (*models.AllCountyStatistics, error) {
@@ -224,3 +340,39 @@ func (s *CountyService) cacheCountyStats(stats *models.CountyStatistics) error {
 
 	return nil
 }
+
+func (s *CountyService) getCachedJSON(cacheKey string, dest interface{}) (bool, error) {
+	if s.redisClient == nil {
+		return false, nil // Redis not available, skip caching
+	}
+	cached, err := s.redisClient.Get(s.ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return false, nil // Cache miss
+	}
+	if err != nil {
+		return false, fmt.Errorf("redis get error: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(cached), dest); err != nil {
+		return false, fmt.Errorf("json unmarshal error: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *CountyService) cacheJSON(cacheKey string, value interface{}) error {
+	if s.redisClient == nil {
+		return nil // Redis not available, skip caching
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("json marshal error: %w", err)
+	}
+
+	// Set cache with 15-minute TTL (900 seconds)
+	if err := s.redisClient.Set(s.ctx, cacheKey, jsonData, 900*time.Second).Err(); err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+
+	return nil
+}
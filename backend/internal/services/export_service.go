@@ -0,0 +1,423 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ExportService streams students and sport records out as xlsx or CSV files
+type ExportService struct {
+	db *gorm.DB
+}
+
+// NewExportService creates a new ExportService
+func NewExportService(db *gorm.DB) *ExportService {
+	return &ExportService{db: db}
+}
+
+// Export is a file ready to be written. Its filters are checked when it is
+// created, so writing only fails on database or output errors.
+type Export struct {
+	Filename    string
+	ContentType string
+	format      string
+	write       func(out tableWriter) error
+}
+
+// Write streams the export file to w row by row
+func (e *Export) Write(w io.Writer) error {
+	var out tableWriter = newXLSXTableWriter(w)
+	if e.format == models.ExportFormatCSV {
+		out = newCSVTableWriter(w)
+	}
+	if err := e.write(out); err != nil {
+		out.Discard()
+		return err
+	}
+	return out.Close()
+}
+
+// exportFilter holds the checked filters of an export
+type exportFilter struct {
+	params *models.ExportParams
+	class  string
+	from   string // first test date, YYYY-MM-DD
+	until  string // first test date after the range, YYYY-MM-DD
+	format string
+	layout string
+}
+
+// newExportFilter checks the export parameters; a term and a date range
+// together select the dates that are in both
+func newExportFilter(params *models.ExportParams) (*exportFilter, error) {
+	filter := &exportFilter{
+		params: params,
+		class:  NormalizeClassName(params.Class),
+		format: strings.ToLower(strings.TrimSpace(params.Format)),
+		layout: strings.ToLower(strings.TrimSpace(params.Layout)),
+	}
+
+	if filter.format == "" {
+		filter.format = models.ExportFormatXLSX
+	}
+	if filter.format != models.ExportFormatXLSX && filter.format != models.ExportFormatCSV {
+		return nil, fmt.Errorf("不支援的匯出格式: %s（可用 xlsx 或 csv）", params.Format)
+	}
+	if filter.layout == "" {
+		filter.layout = models.ExportLayoutBySport
+	}
+	if filter.layout != models.ExportLayoutBySport && filter.layout != models.ExportLayoutWide {
+		return nil, fmt.Errorf("不支援的匯出版面: %s（可用 by_sport 或 wide）", params.Layout)
+	}
+
+	var from, until time.Time
+	if params.Term != "" {
+		start, end, err := ParseAcademicTerm(params.Term)
+		if err != nil {
+			return nil, err
+		}
+		from, until = start, end
+	}
+	if params.StartDate != "" {
+		start, err := ParseDate(params.StartDate)
+		if err != nil {
+			return nil, fmt.Errorf("開始日期: %v", err)
+		}
+		if start.After(from) {
+			from = start
+		}
+	}
+	if params.EndDate != "" {
+		end, err := ParseDate(params.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("結束日期: %v", err)
+		}
+		if end = end.AddDate(0, 0, 1); until.IsZero() || end.Before(until) {
+			until = end
+		}
+	}
+	if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+		return nil, fmt.Errorf("日期範圍內沒有任何日期")
+	}
+	if !from.IsZero() {
+		filter.from = from.Format("2006-01-02")
+	}
+	if !until.IsZero() {
+		filter.until = until.Format("2006-01-02")
+	}
+
+	return filter, nil
+}
+
+// filtersRecords reports whether any record filter is set
+func (f *exportFilter) filtersRecords() bool {
+	return f.params.SportTypeID > 0 || f.from != "" || f.until != ""
+}
+
+// students applies the student filters to a query on students s
+func (f *exportFilter) students(query *gorm.DB) *gorm.DB {
+	query = query.Where("s.deleted_at IS NULL")
+	if f.params.SchoolID > 0 {
+		query = query.Where("s.school_id = ?", f.params.SchoolID)
+	}
+	if f.params.Grade > 0 {
+		query = query.Where("s.grade = ?", f.params.Grade)
+	}
+	if f.class != "" {
+		query = query.Where("s.class = ?", f.class)
+	}
+	if f.params.ClassID > 0 {
+		query = query.Where("s.class_id = ?", f.params.ClassID)
+	}
+	return query
+}
+
+// recordConditions returns the record filters as a condition on sport_records sr
+func (f *exportFilter) recordConditions() (string, []interface{}) {
+	conditions := []string{"sr.deleted_at IS NULL"}
+	var args []interface{}
+	if f.params.SportTypeID > 0 {
+		conditions = append(conditions, "sr.sport_type_id = ?")
+		args = append(args, f.params.SportTypeID)
+	}
+	if f.from != "" {
+		conditions = append(conditions, "sr.test_date >= ?")
+		args = append(args, f.from)
+	}
+	if f.until != "" {
+		conditions = append(conditions, "sr.test_date < ?")
+		args = append(args, f.until)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// records builds the query of the filtered records with their students
+func (f *exportFilter) records(db *gorm.DB) *gorm.DB {
+	conditions, args := f.recordConditions()
+	query := db.Table("sport_records sr").
+		Joins("JOIN students s ON s.id = sr.student_id").
+		Joins("JOIN schools sc ON sc.id = s.school_id").
+		Joins("JOIN sport_types st ON st.id = sr.sport_type_id").
+		Where(conditions, args...)
+	return f.students(query)
+}
+
+// newExport names the export file and sets its content type
+func (f *exportFilter) newExport(name string, write func(out tableWriter) error) *Export {
+	export := &Export{
+		Filename:    fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), f.format),
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		format:      f.format,
+		write:       write,
+	}
+	if f.format == models.ExportFormatCSV {
+		export.ContentType = "text/csv; charset=utf-8"
+	}
+	return export
+}
+
+// exportStudentRow is one student as read for an export
+type exportStudentRow struct {
+	SchoolCode    *string
+	SchoolName    string
+	StudentNumber string
+	Name          string
+	Gender        string
+	Grade         int
+	Class         string
+	BirthDate     *time.Time
+}
+
+// exportRecordRow is one sport record with its student as read for an export
+type exportRecordRow struct {
+	StudentID     uint
+	SchoolName    string
+	StudentNumber string
+	Name          string
+	Gender        string
+	Grade         int
+	Class         string
+	SportTypeID   uint
+	SportTypeName string
+	Unit          string
+	Value         float64
+	TestDate      time.Time
+	Notes         string
+}
+
+var studentExportHeaders = []string{"學校代碼", "學校", "學號", "姓名", "性別", "年級", "班級", "生日"}
+
+var recordExportHeaders = []string{"學校", "年級", "班級", "學號", "姓名", "性別", "項目", "成績", "單位", "測驗日期", "學期", "備註"}
+
+// ExportStudents exports the filtered students. With a sport type, term or
+// date filter only students with matching records are included.
+func (s *ExportService) ExportStudents(params *models.ExportParams) (*Export, error) {
+	filter, err := newExportFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.newExport("students", func(out tableWriter) error {
+		query := filter.students(s.db.Table("students s").
+			Select("sc.school_code, sc.name AS school_name, s.student_number, s.name, s.gender, s.grade, s.class, s.birth_date").
+			Joins("JOIN schools sc ON sc.id = s.school_id"))
+		if filter.filtersRecords() {
+			conditions, args := filter.recordConditions()
+			query = query.Where("EXISTS (SELECT 1 FROM sport_records sr WHERE sr.student_id = s.id AND "+conditions+")", args...)
+		}
+
+		rows, err := query.Order("sc.name, s.grade, s.class, s.student_number").Rows()
+		if err != nil {
+			return fmt.Errorf("查詢學生失敗: %w", err)
+		}
+		defer rows.Close()
+
+		if err := out.StartSheet("學生名單", studentExportHeaders); err != nil {
+			return err
+		}
+		for rows.Next() {
+			var row exportStudentRow
+			if err := s.db.ScanRows(rows, &row); err != nil {
+				return fmt.Errorf("讀取學生失敗: %w", err)
+			}
+			code, birthDate := "", ""
+			if row.SchoolCode != nil {
+				code = *row.SchoolCode
+			}
+			if row.BirthDate != nil {
+				birthDate = row.BirthDate.Format("2006-01-02")
+			}
+			if err := out.WriteRow([]interface{}{
+				code, row.SchoolName, row.StudentNumber, row.Name,
+				genderLabel(row.Gender), row.Grade, row.Class, birthDate,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("讀取學生失敗: %w", err)
+		}
+		return nil
+	}), nil
+}
+
+// ExportRecords exports the filtered sport records, one record per row or
+// in the wide layout with a column per sport type. xlsx files in the
+// by_sport layout get a sheet per sport type.
+func (s *ExportService) ExportRecords(params *models.ExportParams) (*Export, error) {
+	filter, err := newExportFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.layout == models.ExportLayoutWide {
+		return filter.newExport("sport-records", func(out tableWriter) error {
+			return s.writeWideRecords(out, filter)
+		}), nil
+	}
+	return filter.newExport("sport-records", func(out tableWriter) error {
+		return s.writeRecordsBySport(out, filter)
+	}), nil
+}
+
+// writeRecordsBySport writes one record per row; xlsx files switch to a new
+// sheet for every sport type
+func (s *ExportService) writeRecordsBySport(out tableWriter, filter *exportFilter) error {
+	order := "sc.name, s.grade, s.class, s.student_number, sr.test_date, st.id"
+	perSheet := filter.format == models.ExportFormatXLSX
+	if perSheet {
+		order = "st.id, sc.name, s.grade, s.class, s.student_number, sr.test_date"
+	}
+
+	rows, err := filter.records(s.db).
+		Select("sr.student_id, sc.name AS school_name, s.student_number, s.name, s.gender, s.grade, s.class, " +
+			"sr.sport_type_id, st.name AS sport_type_name, st.default_unit AS unit, sr.value, sr.test_date, sr.notes").
+		Order(order).
+		Rows()
+	if err != nil {
+		return fmt.Errorf("查詢運動紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	var sheetSportType uint
+	started := false
+	for rows.Next() {
+		var row exportRecordRow
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			return fmt.Errorf("讀取運動紀錄失敗: %w", err)
+		}
+		if !started || (perSheet && row.SportTypeID != sheetSportType) {
+			name := "運動紀錄"
+			if perSheet {
+				name = row.SportTypeName
+			}
+			if err := out.StartSheet(name, recordExportHeaders); err != nil {
+				return err
+			}
+			sheetSportType, started = row.SportTypeID, true
+		}
+		if err := out.WriteRow([]interface{}{
+			row.SchoolName, row.Grade, row.Class, row.StudentNumber, row.Name, genderLabel(row.Gender),
+			row.SportTypeName, row.Value, row.Unit, row.TestDate.Format("2006-01-02"),
+			AcademicTerm(row.TestDate), row.Notes,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("讀取運動紀錄失敗: %w", err)
+	}
+	if !started {
+		if err := out.StartSheet("運動紀錄", recordExportHeaders); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeWideRecords writes a row per student and test date with a column per
+// sport type that has matching records
+func (s *ExportService) writeWideRecords(out tableWriter, filter *exportFilter) error {
+	var sportTypes []models.SportType
+	err := s.db.Where("id IN (?)",
+		filter.records(s.db).Distinct("sr.sport_type_id")).
+		Order("id").Find(&sportTypes).Error
+	if err != nil {
+		return fmt.Errorf("查詢運動項目失敗: %w", err)
+	}
+
+	headers := []string{"學校", "年級", "班級", "學號", "姓名", "性別", "測驗日期", "學期"}
+	const fixedColumns = 8
+	columns := make(map[uint]int, len(sportTypes))
+	for i, sportType := range sportTypes {
+		columns[sportType.ID] = fixedColumns + i
+		headers = append(headers, fmt.Sprintf("%s(%s)", sportType.Name, sportType.DefaultUnit))
+	}
+
+	rows, err := filter.records(s.db).
+		Select("sr.student_id, sc.name AS school_name, s.student_number, s.name, s.gender, s.grade, s.class, " +
+			"sr.sport_type_id, sr.value, sr.test_date").
+		Order("sc.name, s.grade, s.class, s.student_number, sr.student_id, sr.test_date, sr.id").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("查詢運動紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	if err := out.StartSheet("運動紀錄", headers); err != nil {
+		return err
+	}
+
+	// Records arrive grouped by student and test date; a row is written when
+	// the next group starts
+	var current []interface{}
+	var currentStudent uint
+	var currentDate time.Time
+	for rows.Next() {
+		var row exportRecordRow
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			return fmt.Errorf("讀取運動紀錄失敗: %w", err)
+		}
+		if current == nil || row.StudentID != currentStudent || !row.TestDate.Equal(currentDate) {
+			if current != nil {
+				if err := out.WriteRow(current); err != nil {
+					return err
+				}
+			}
+			current = make([]interface{}, len(headers))
+			copy(current, []interface{}{
+				row.SchoolName, row.Grade, row.Class, row.StudentNumber, row.Name, genderLabel(row.Gender),
+				row.TestDate.Format("2006-01-02"), AcademicTerm(row.TestDate),
+			})
+			currentStudent, currentDate = row.StudentID, row.TestDate
+		}
+		current[columns[row.SportTypeID]] = row.Value
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("讀取運動紀錄失敗: %w", err)
+	}
+	if current != nil {
+		if err := out.WriteRow(current); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// genderLabel returns the Chinese label of a stored gender
+func genderLabel(gender string) string {
+	switch gender {
+	case "male":
+		return "男"
+	case "female":
+		return "女"
+	}
+	return gender
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// tableWriter writes the rows of an export in one file format. Rows are
+// passed on as they come so large exports are never held in memory.
+type tableWriter interface {
+	// StartSheet begins a table with the given header. Formats without
+	// sheets keep writing to the one table and ignore later headers.
+	StartSheet(name string, headers []string) error
+	WriteRow(values []interface{}) error
+	// Close finishes the file; Discard drops it after an error
+	Close() error
+	Discard()
+}
+
+// csvFlushRows is how many CSV rows are written before the output is flushed
+// to the client
+const csvFlushRows = 1000
+
+// csvTableWriter writes UTF-8 CSV with a byte order mark so Excel opens
+// Chinese text correctly
+type csvTableWriter struct {
+	w       io.Writer
+	csv     *csv.Writer
+	started bool
+	rows    int
+}
+
+func newCSVTableWriter(w io.Writer) *csvTableWriter {
+	return &csvTableWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (t *csvTableWriter) StartSheet(name string, headers []string) error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+	if _, err := io.WriteString(t.w, "\ufeff"); err != nil {
+		return err
+	}
+	return t.csv.Write(headers)
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = cellText(value)
+	}
+	if err := t.csv.Write(record); err != nil {
+		return err
+	}
+
+	t.rows++
+	if t.rows%csvFlushRows == 0 {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *csvTableWriter) Close() error {
+	return t.flush()
+}
+
+func (t *csvTableWriter) Discard() {}
+
+// flush sends the buffered rows on, down to the client for HTTP responses
+func (t *csvTableWriter) flush() error {
+	t.csv.Flush()
+	if err := t.csv.Error(); err != nil {
+		return err
+	}
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// cellText formats a cell value for text output
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// xlsxTableWriter writes xlsx through excelize stream writers, which keep
+// large sheets in temporary files instead of memory
+type xlsxTableWriter struct {
+	w           io.Writer
+	f           *excelize.File
+	sheet       *excelize.StreamWriter
+	headerStyle int
+	sheets      int
+	row         int
+}
+
+func newXLSXTableWriter(w io.Writer) *xlsxTableWriter {
+	return &xlsxTableWriter{w: w, f: excelize.NewFile()}
+}
+
+func (t *xlsxTableWriter) StartSheet(name string, headers []string) error {
+	if t.sheet != nil {
+		if err := t.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+
+	name = safeSheetName(name)
+	if t.sheets == 0 {
+		if err := t.f.SetSheetName("Sheet1", name); err != nil {
+			return err
+		}
+		style, err := t.f.NewStyle(&excelize.Style{
+			Font: &excelize.Font{Bold: true},
+			Fill: excelize.Fill{Type: "pattern", Color: []string{"#E2EFDA"}, Pattern: 1},
+		})
+		if err != nil {
+			return err
+		}
+		t.headerStyle = style
+	} else if _, err := t.f.NewSheet(name); err != nil {
+		return err
+	}
+	t.sheets++
+
+	sheet, err := t.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	if err := sheet.SetColWidth(1, len(headers), 14); err != nil {
+		return err
+	}
+	if err := sheet.SetPanes(&excelize.Panes{
+		Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	cells := make([]interface{}, len(headers))
+	for i, header := range headers {
+		cells[i] = excelize.Cell{StyleID: t.headerStyle, Value: header}
+	}
+	if err := sheet.SetRow("A1", cells); err != nil {
+		return err
+	}
+
+	t.sheet = sheet
+	t.row = 1
+	return nil
+}
+
+func (t *xlsxTableWriter) WriteRow(values []interface{}) error {
+	t.row++
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+	return t.sheet.SetRow(cell, values)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.f.Close()
+	if t.sheet != nil {
+		if err := t.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+
+	out := bufio.NewWriter(t.w)
+	if _, err := t.f.WriteTo(out); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func (t *xlsxTableWriter) Discard() {
+	t.f.Close()
+}
+
+// safeSheetName replaces the characters Excel does not allow in sheet names
+// and cuts the name to 31 characters
+func safeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("[]:*?/\\", r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
@@ -0,0 +1,461 @@
+package services
+
+import (
+	"fmt"
+	"mime/multipart"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"golang.org/x/text/width"
+	"gorm.io/gorm"
+)
+
+// columnAliases lists the header texts other systems use for each import
+// field, besides our own template headers
+var columnAliases = map[string][]string{
+	"student_number": {"座號", "學號", "編號", "student number", "student no", "seat number"},
+	"name":           {"姓名", "學生姓名", "名字", "name", "student name"},
+	"gender":         {"性別", "男女", "sex", "gender"},
+	"grade":          {"年級", "年級別", "grade"},
+	"class":          {"班級", "班別", "班", "class"},
+	"birth_date":     {"生日", "出生日期", "出生年月日", "birthday", "birth date", "date of birth", "dob"},
+	"身高":             {"身高", "height"},
+	"體重":             {"體重", "weight"},
+	"坐姿體前彎":          {"坐姿體前彎", "體前彎", "柔軟度", "sit and reach"},
+	"立定跳遠":           {"立定跳遠", "跳遠", "瞬發力", "standing long jump"},
+	"仰臥起坐":           {"仰臥起坐", "屈膝仰臥起坐", "仰臥捲腹", "肌耐力", "sit-ups", "sit ups"},
+	"心肺耐力":           {"心肺耐力", "800公尺跑走", "1600公尺跑走", "800公尺", "1600公尺", "跑走", "cardio"},
+	"test_date":      {"測驗日期", "檢測日期", "施測日期", "test date"},
+	"school_code":    {"學校代碼", "學校代號", "校代碼", "代碼", "代號", "教育部學校代碼", "school code", "code"},
+	"school_name":    {"學校名稱", "校名", "school name"},
+	"county_name":    {"縣市", "縣市別", "縣市名稱", "county", "city"},
+	"address":        {"地址", "校址", "學校地址", "address"},
+	"phone":          {"電話", "聯絡電話", "學校電話", "phone", "tel", "telephone"},
+	"latitude":       {"緯度", "lat", "latitude"},
+	"longitude":      {"經度", "lng", "lon", "longitude"},
+}
+
+// headerUnits are unit suffixes dropped from headers ("身高cm" -> "身高")
+var headerUnits = []string{"次/分鐘", "次/分", "公分", "公斤", "cm", "kg", "秒", "次"}
+
+const (
+	// minColumnScore is the lowest match score accepted for a column
+	minColumnScore = 0.7
+	// headerScanRows is the number of leading rows searched for the header row
+	headerScanRows = 10
+)
+
+// importFields returns the fields of an import type
+func importFields(importType models.ImportType) ([]models.ImportField, error) {
+	switch importType {
+	case models.ImportTypeStudents:
+		return models.StudentImportFields, nil
+	case models.ImportTypeRecords:
+		return models.RecordsImportFields, nil
+	case models.ImportTypeSchools:
+		return models.SchoolImportFields, nil
+	}
+	return nil, fmt.Errorf("無效的匯入類型")
+}
+
+// normalizeHeader reduces a header to the text that identifies its field:
+// full-width characters narrowed, lower case, notes in brackets, spaces,
+// required markers and unit suffixes removed
+func normalizeHeader(header string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(width.Narrow.String(header)) {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0 || unicode.IsSpace(r) || r == '*' || r == ':':
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	normalized := b.String()
+	for _, unit := range headerUnits {
+		if strings.HasSuffix(normalized, unit) && len(normalized) > len(unit) {
+			normalized = strings.TrimSuffix(normalized, unit)
+			break
+		}
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || r == '/' {
+			return -1
+		}
+		return r
+	}, normalized)
+}
+
+// headerScore rates how well a header matches a field: 1 for a known alias,
+// less for headers containing an alias or close to one
+func headerScore(header, field string) float64 {
+	h := normalizeHeader(header)
+	if h == "" {
+		return 0
+	}
+	hLen := len([]rune(h))
+
+	best := 0.0
+	for _, alias := range columnAliases[field] {
+		a := normalizeHeader(alias)
+		aLen := len([]rune(a))
+
+		var score float64
+		switch {
+		case h == a:
+			return 1
+		case aLen >= 2 && strings.Contains(h, a):
+			score = 0.6 + 0.3*float64(aLen)/float64(hLen)
+		case hLen >= 2 && strings.Contains(a, h):
+			score = 0.6 + 0.3*float64(hLen)/float64(aLen)
+		default:
+			score = NameSimilarity(h, a)
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// suggestColumns matches the header cells to the import fields. A saved
+// mapping of the school wins over aliases; each field and each column is
+// used at most once, best matches first.
+func suggestColumns(headers []string, fields []models.ImportField, saved map[string]string) ([]models.ColumnSuggestion, map[string]int) {
+	type candidate struct {
+		field  int
+		column int
+		score  float64
+		source string
+	}
+
+	candidates := make([]candidate, 0)
+	for fi, field := range fields {
+		for col, header := range headers {
+			if strings.TrimSpace(header) == "" {
+				continue
+			}
+			if savedHeader, ok := saved[field.Key]; ok && normalizeHeader(savedHeader) == normalizeHeader(header) {
+				candidates = append(candidates, candidate{fi, col, 1, "saved"})
+				continue
+			}
+			score := headerScore(header, field.Key)
+			if score < minColumnScore {
+				continue
+			}
+			source := "fuzzy"
+			if score == 1 {
+				source = "alias"
+			}
+			candidates = append(candidates, candidate{fi, col, score, source})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].source == "saved" && candidates[j].source != "saved"
+	})
+
+	suggestions := make([]models.ColumnSuggestion, len(fields))
+	for i, field := range fields {
+		suggestions[i] = models.ColumnSuggestion{
+			Field:    field.Key,
+			Label:    field.Label,
+			Required: field.Required,
+			Column:   -1,
+		}
+	}
+
+	columnMap := make(map[string]int)
+	usedColumns := make(map[int]bool)
+	for _, c := range candidates {
+		suggestion := &suggestions[c.field]
+		if suggestion.Column >= 0 || usedColumns[c.column] {
+			continue
+		}
+		suggestion.Column = c.column
+		suggestion.Header = strings.TrimSpace(headers[c.column])
+		suggestion.Confidence = c.score
+		suggestion.Source = c.source
+		usedColumns[c.column] = true
+		columnMap[suggestion.Field] = c.column
+	}
+
+	return suggestions, columnMap
+}
+
+// detectHeaderRow returns the zero-based index of the leading row whose cells
+// match the most fields
+func detectHeaderRow(rows [][]string, fields []models.ImportField, saved map[string]string) (int, error) {
+	best, bestMatches := -1, 0
+	for i := 0; i < len(rows) && i < headerScanRows; i++ {
+		_, columnMap := suggestColumns(rows[i], fields, saved)
+		if len(columnMap) > bestMatches {
+			best, bestMatches = i, len(columnMap)
+		}
+	}
+	if best < 0 || bestMatches < 2 {
+		return 0, fmt.Errorf("無法辨識標題列，請確認檔案前 %d 列包含欄位名稱", headerScanRows)
+	}
+	return best, nil
+}
+
+// missingRequired returns the labels of required fields without a column
+func missingRequired(fields []models.ImportField, columnMap map[string]int) []string {
+	missing := make([]string, 0)
+	for _, field := range fields {
+		if _, ok := columnMap[field.Key]; field.Required && !ok {
+			missing = append(missing, field.Label)
+		}
+	}
+	return missing
+}
+
+// remapRows reorders the cells of data rows into template column order, so
+// rows of any layout can be validated like template rows
+func remapRows(rows [][]string, columnMap map[string]int, fields []models.ImportField) [][]string {
+	remapped := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, len(fields))
+		for i, field := range fields {
+			if col, ok := columnMap[field.Key]; ok {
+				cells[i] = GetCellValue(row, col)
+			}
+		}
+		remapped = append(remapped, cells)
+	}
+	return remapped
+}
+
+// recordsColumnMap adds the row error fields of records imports that share
+// a column with another field
+func recordsColumnMap(columnMap map[string]int) map[string]int {
+	columns := copyColumnMap(columnMap)
+	if col, ok := columns["name"]; ok {
+		columns["student"] = col
+	}
+	return columns
+}
+
+// resolveColumns finds the header row and the column of each field of a
+// sheet. A mapping confirmed by the client is checked and used as is;
+// otherwise it is suggested from the school's saved mapping and the aliases.
+// It returns the zero-based header row index and the column map.
+func (s *ImportService) resolveColumns(rows [][]string, importType models.ImportType, schoolID uint, options *models.ColumnMappingOptions) (int, map[string]int, error) {
+	fields, err := importFields(importType)
+	if err != nil {
+		return 0, nil, err
+	}
+	saved := s.loadColumnMapping(schoolID, importType)
+
+	headerIdx := 0
+	if options != nil && options.HeaderRow > 0 {
+		headerIdx = options.HeaderRow - 1
+		if headerIdx >= len(rows) {
+			return 0, nil, fmt.Errorf("標題列第 %d 列超出檔案範圍", options.HeaderRow)
+		}
+	} else {
+		headerIdx, err = detectHeaderRow(rows, fields, saved)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var columnMap map[string]int
+	if options != nil && len(options.ColumnMap) > 0 {
+		known := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			known[field.Key] = true
+		}
+		usedColumns := make(map[int]string)
+		for key, col := range options.ColumnMap {
+			if !known[key] {
+				return 0, nil, fmt.Errorf("欄位對應無效：未知的欄位 %s", key)
+			}
+			if col < 0 {
+				return 0, nil, fmt.Errorf("欄位對應無效：%s 的欄位位置不正確", key)
+			}
+			if other, used := usedColumns[col]; used {
+				return 0, nil, fmt.Errorf("欄位對應無效：%s 與 %s 對應到同一欄", other, key)
+			}
+			usedColumns[col] = key
+		}
+		columnMap = copyColumnMap(options.ColumnMap)
+	} else {
+		_, columnMap = suggestColumns(rows[headerIdx], fields, saved)
+	}
+
+	if missing := missingRequired(fields, columnMap); len(missing) > 0 {
+		return 0, nil, fmt.Errorf("無法對應必填欄位：%s，請確認欄位對應", strings.Join(missing, "、"))
+	}
+	if len(rows) <= headerIdx+1 {
+		return 0, nil, fmt.Errorf("檔案沒有資料列（僅有標題或為空）")
+	}
+
+	if options != nil && options.Save {
+		if err := s.saveColumnMapping(schoolID, importType, rows[headerIdx], columnMap); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return headerIdx, columnMap, nil
+}
+
+// DetectColumns finds the header row of an uploaded file and suggests the
+// column of each import field, for the client to confirm or override before
+// previewing. The first sheet is used unless a sheet name is given. School
+// imports are not tied to a school and use school ID 0.
+func (s *ImportService) DetectColumns(file multipart.File, filename string, schoolID uint, importType models.ImportType, sheetName string) (*models.ColumnDetection, error) {
+	fields, err := importFields(importType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify school exists
+	if importType != models.ImportTypeSchools {
+		var school models.School
+		if err := s.db.First(&school, schoolID).Error; err != nil {
+			return nil, fmt.Errorf("找不到 ID 為 %d 的學校", schoolID)
+		}
+	}
+
+	wb, err := ReadWorkbook(file, filename)
+	if err != nil {
+		return nil, err
+	}
+	sheet := wb.Sheets[0]
+	if sheetName != "" {
+		found := false
+		for _, candidate := range wb.Sheets {
+			if candidate.Name == sheetName {
+				sheet, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("檔案中沒有名為 %s 的工作表", sheetName)
+		}
+	}
+	if len(sheet.Rows) == 0 {
+		return nil, fmt.Errorf("工作表沒有資料")
+	}
+
+	saved := s.loadColumnMapping(schoolID, importType)
+
+	// Fall back to the first row so the client can still pick the columns
+	headerIdx, err := detectHeaderRow(sheet.Rows, fields, saved)
+	if err != nil {
+		headerIdx = 0
+	}
+	suggestions, columnMap := suggestColumns(sheet.Rows[headerIdx], fields, saved)
+
+	detection := &models.ColumnDetection{
+		Type:            importType,
+		SheetName:       sheet.Name,
+		HeaderRow:       headerIdx + 1,
+		Headers:         sheet.Rows[headerIdx],
+		SampleRows:      make([][]string, 0),
+		Suggestions:     suggestions,
+		ColumnMap:       columnMap,
+		MissingRequired: missingRequired(fields, columnMap),
+	}
+	for i := headerIdx + 1; i < len(sheet.Rows) && len(detection.SampleRows) < 3; i++ {
+		detection.SampleRows = append(detection.SampleRows, sheet.Rows[i])
+	}
+	for _, suggestion := range suggestions {
+		if suggestion.Source == "saved" {
+			detection.UsedSavedMap = true
+		}
+	}
+
+	return detection, nil
+}
+
+// loadColumnMapping returns the saved header texts of a school, or nil
+func (s *ImportService) loadColumnMapping(schoolID uint, importType models.ImportType) map[string]string {
+	var mapping models.ImportColumnMapping
+	if err := s.db.Where("school_id = ? AND type = ?", schoolID, importType).First(&mapping).Error; err != nil {
+		return nil
+	}
+	return mapping.Headers
+}
+
+// saveColumnMapping remembers the header text of each mapped column
+func (s *ImportService) saveColumnMapping(schoolID uint, importType models.ImportType, headers []string, columnMap map[string]int) error {
+	texts := make(map[string]string, len(columnMap))
+	for key, col := range columnMap {
+		if header := strings.TrimSpace(GetCellValue(headers, col)); header != "" {
+			texts[key] = header
+		}
+	}
+	_, err := s.SaveColumnMapping(&models.SaveColumnMappingRequest{
+		SchoolID: schoolID,
+		Type:     importType,
+		Headers:  texts,
+	})
+	return err
+}
+
+// SaveColumnMapping creates or replaces the saved column mapping of a school
+// for an import type
+func (s *ImportService) SaveColumnMapping(req *models.SaveColumnMappingRequest) (*models.ImportColumnMapping, error) {
+	fields, err := importFields(req.Type)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field.Key] = true
+	}
+	for key := range req.Headers {
+		if !known[key] {
+			return nil, fmt.Errorf("欄位對應無效：未知的欄位 %s", key)
+		}
+	}
+
+	var mapping models.ImportColumnMapping
+	err = s.db.Where("school_id = ? AND type = ?", req.SchoolID, req.Type).First(&mapping).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("無法儲存欄位對應: %w", err)
+	}
+	mapping.SchoolID = req.SchoolID
+	mapping.Type = req.Type
+	mapping.Headers = req.Headers
+	if err := s.db.Save(&mapping).Error; err != nil {
+		return nil, fmt.Errorf("無法儲存欄位對應: %w", err)
+	}
+	return &mapping, nil
+}
+
+// ListColumnMappings retrieves the saved column mappings of a school
+func (s *ImportService) ListColumnMappings(schoolID uint) ([]models.ImportColumnMapping, error) {
+	var mappings []models.ImportColumnMapping
+	if err := s.db.Where("school_id = ?", schoolID).Order("type ASC").Find(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("failed to list column mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+// DeleteColumnMapping removes a saved column mapping
+func (s *ImportService) DeleteColumnMapping(id uint) error {
+	result := s.db.Delete(&models.ImportColumnMapping{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete column mapping: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("column mapping not found")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ListBatches retrieves the import history, newest first. The ID lists and
+// row edits are left out; they are returned by GetBatch.
+func (s *ImportService) ListBatches(params *models.ImportBatchSearchParams) ([]models.ImportBatch, *models.Pagination, error) {
+	page := params.Page
+	pageSize := params.PageSize
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var batches []models.ImportBatch
+	var total int64
+
+	query := s.db.Model(&models.ImportBatch{})
+
+	// Apply filters
+	if params.SchoolID > 0 {
+		query = query.Where("school_id = ?", params.SchoolID)
+	}
+	if params.Type != "" {
+		query = query.Where("type = ?", params.Type)
+	}
+
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to count import batches: %w", err)
+	}
+
+	// Calculate pagination
+	offset := (page - 1) * pageSize
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	err := query.
+		Omit("created_student_ids", "created_record_ids", "created_school_ids",
+			"updated_student_ids", "replaced_record_ids", "updated_school_ids", "row_edits").
+		Preload("School").
+		Offset(offset).
+		Limit(pageSize).
+		Order("uploaded_at DESC, id DESC").
+		Find(&batches).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list import batches: %w", err)
+	}
+
+	pagination := &models.Pagination{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      int(total),
+		TotalPages: totalPages,
+	}
+
+	return batches, pagination, nil
+}
+
+// GetBatch retrieves an import batch with the IDs it created
+func (s *ImportService) GetBatch(id uint) (*models.ImportBatch, error) {
+	var batch models.ImportBatch
+	if err := s.db.Preload("School").First(&batch, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("import batch not found")
+		}
+		return nil, fmt.Errorf("failed to get import batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// UndoBatch removes exactly the students, sport records or schools an import
+// created. It refuses when any of them was edited or deleted since the import,
+// or when created students or schools have gained other data (records,
+// merges, number changes, students, classes), so undoing never discards work
+// done after the import.
+func (s *ImportService) UndoBatch(id uint, undoneBy uint) (*models.ImportBatch, error) {
+	batch, err := s.GetBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status == models.ImportBatchUndone {
+		return nil, fmt.Errorf("此匯入批次已復原")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(batch.CreatedRecordIDs) > 0 {
+			var records []models.SportRecord
+			if err := tx.Unscoped().Where("id IN ?", batch.CreatedRecordIDs).Find(&records).Error; err != nil {
+				return fmt.Errorf("failed to load records: %w", err)
+			}
+			changed := len(batch.CreatedRecordIDs) - len(records)
+			for _, record := range records {
+				if record.DeletedAt.Valid || !record.UpdatedAt.Equal(record.CreatedAt) {
+					changed++
+				}
+			}
+			if changed > 0 {
+				return fmt.Errorf("此批次有 %d 筆資料在匯入後已被修改或刪除，無法復原", changed)
+			}
+		}
+
+		if len(batch.CreatedStudentIDs) > 0 {
+			var students []models.Student
+			if err := tx.Unscoped().Where("id IN ?", batch.CreatedStudentIDs).Find(&students).Error; err != nil {
+				return fmt.Errorf("failed to load students: %w", err)
+			}
+			changed := len(batch.CreatedStudentIDs) - len(students)
+			for _, student := range students {
+				if student.DeletedAt.Valid || !student.UpdatedAt.Equal(student.CreatedAt) {
+					changed++
+				}
+			}
+			if changed > 0 {
+				return fmt.Errorf("此批次有 %d 筆資料在匯入後已被修改或刪除，無法復原", changed)
+			}
+
+			if err := checkStudentDependents(tx, batch.CreatedStudentIDs); err != nil {
+				return err
+			}
+		}
+
+		if len(batch.CreatedSchoolIDs) > 0 {
+			var schools []models.School
+			if err := tx.Unscoped().Where("id IN ?", batch.CreatedSchoolIDs).Find(&schools).Error; err != nil {
+				return fmt.Errorf("failed to load schools: %w", err)
+			}
+			changed := len(batch.CreatedSchoolIDs) - len(schools)
+			for _, school := range schools {
+				if school.DeletedAt.Valid || !school.UpdatedAt.Equal(school.CreatedAt) {
+					changed++
+				}
+			}
+			if changed > 0 {
+				return fmt.Errorf("此批次有 %d 筆資料在匯入後已被修改或刪除，無法復原", changed)
+			}
+
+			if err := checkSchoolDependents(tx, batch.CreatedSchoolIDs); err != nil {
+				return err
+			}
+		}
+
+		if len(batch.CreatedRecordIDs) > 0 {
+			if err := tx.Where("id IN ?", batch.CreatedRecordIDs).Delete(&models.SportRecord{}).Error; err != nil {
+				return fmt.Errorf("failed to delete records: %w", err)
+			}
+		}
+		if len(batch.CreatedStudentIDs) > 0 {
+			if err := tx.Where("id IN ?", batch.CreatedStudentIDs).Delete(&models.Student{}).Error; err != nil {
+				return fmt.Errorf("failed to delete students: %w", err)
+			}
+		}
+		if len(batch.CreatedSchoolIDs) > 0 {
+			if err := tx.Where("id IN ?", batch.CreatedSchoolIDs).Delete(&models.School{}).Error; err != nil {
+				return fmt.Errorf("failed to delete schools: %w", err)
+			}
+		}
+
+		now := time.Now()
+		batch.Status = models.ImportBatchUndone
+		batch.UndoneBy = &undoneBy
+		batch.UndoneAt = &now
+		if err := tx.Omit("School").Save(batch).Error; err != nil {
+			return fmt.Errorf("failed to update import batch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// checkStudentDependents returns an error when any of the students has data
+// that did not come from the import
+func checkStudentDependents(tx *gorm.DB, studentIDs []uint) error {
+	var count int64
+
+	if err := tx.Model(&models.SportRecord{}).Where("student_id IN ?", studentIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count records: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此批次建立的學生已有 %d 筆運動記錄，無法復原", count)
+	}
+
+	if err := tx.Model(&models.StudentMerge{}).
+		Where("(survivor_id IN ? OR merged_id IN ?) AND reverted_at IS NULL", studentIDs, studentIDs).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count merges: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此批次建立的學生已參與合併，無法復原")
+	}
+
+	if err := tx.Model(&models.StudentNumberHistory{}).Where("student_id IN ?", studentIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count number history: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此批次建立的學生已變更學號，無法復原")
+	}
+
+	return nil
+}
+
+// checkSchoolDependents returns an error when any of the schools has students
+// or classes
+func checkSchoolDependents(tx *gorm.DB, schoolIDs []uint) error {
+	var count int64
+
+	if err := tx.Unscoped().Model(&models.Student{}).Where("school_id IN ?", schoolIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count students: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此批次建立的學校已有 %d 位學生，無法復原", count)
+	}
+
+	if err := tx.Model(&models.Class{}).Where("school_id IN ?", schoolIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count classes: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此批次建立的學校已有 %d 個班級，無法復原", count)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wei979/ICACP/backend/internal/models"
+)
+
+// errImportCancelled is returned by an import run that stopped because its
+// job was cancelled
+var errImportCancelled = errors.New("匯入已取消")
+
+// ImportRunFunc executes an import, reporting progress as it goes. It must
+// stop and return an error when ctx is cancelled.
+type ImportRunFunc func(ctx context.Context, progress func(models.ImportProgress)) (*models.ImportResult, error)
+
+// ImportJobManager runs imports in background goroutines. Job state lives
+// in an ImportJobStorage, so any replica can report progress, stream it and
+// accept a cancel request; the replica running a job polls for the request.
+type ImportJobManager struct {
+	store        ImportJobStorage
+	pollInterval time.Duration
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // jobs running on this replica
+}
+
+// NewImportJobManager creates a new ImportJobManager keeping jobs in the
+// given storage, which is polled for cancel requests and progress at the
+// given interval
+func NewImportJobManager(store ImportJobStorage, pollInterval time.Duration) *ImportJobManager {
+	return &ImportJobManager{
+		store:        store,
+		pollInterval: pollInterval,
+		running:      make(map[string]context.CancelFunc),
+	}
+}
+
+// Start creates a job and runs it in the background
+func (m *ImportJobManager) Start(importType models.ImportType, previewID string, totalRows int, run ImportRunFunc) (models.ImportJob, error) {
+	job := models.ImportJob{
+		ID:        uuid.New().String(),
+		Type:      importType,
+		PreviewID: previewID,
+		Status:    models.ImportJobQueued,
+		TotalRows: totalRows,
+		CreatedAt: time.Now(),
+	}
+	if err := m.store.Set(job); err != nil {
+		return models.ImportJob{}, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.running[job.ID] = cancel
+	m.mu.Unlock()
+
+	go m.run(ctx, job, run)
+
+	return job, nil
+}
+
+// run executes a job and records its outcome
+func (m *ImportJobManager) run(ctx context.Context, job models.ImportJob, run ImportRunFunc) {
+	defer func() {
+		m.mu.Lock()
+		m.running[job.ID]()
+		delete(m.running, job.ID)
+		m.mu.Unlock()
+	}()
+	go m.watchCancel(ctx, job.ID)
+
+	now := time.Now()
+	job.Status = models.ImportJobRunning
+	job.StartedAt = &now
+	m.save(job)
+
+	result, err := run(ctx, func(progress models.ImportProgress) {
+		job.ProcessedRows = progress.ProcessedRows
+		job.InsertedCount = progress.InsertedCount
+		job.SkippedCount = progress.SkippedCount
+		m.save(job)
+	})
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	switch {
+	case err != nil && (errors.Is(err, errImportCancelled) || ctx.Err() != nil):
+		job.Status = models.ImportJobCancelled
+		job.Error = errImportCancelled.Error()
+	case err != nil:
+		job.Status = models.ImportJobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = models.ImportJobCompleted
+		job.Result = result
+		job.ProcessedRows = job.TotalRows
+		job.InsertedCount = result.SuccessCount
+		job.SkippedCount = result.SkipCount
+	}
+	m.save(job)
+}
+
+// watchCancel polls the storage for a cancel request made on any replica
+// and cancels the job's context, until the job ends
+func (m *ImportJobManager) watchCancel(ctx context.Context, id string) {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.store.IsCancelRequested(id) {
+				m.cancelLocal(id)
+				return
+			}
+		}
+	}
+}
+
+// cancelLocal cancels a job when it runs on this replica
+func (m *ImportJobManager) cancelLocal(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cancel, exists := m.running[id]; exists {
+		cancel()
+	}
+}
+
+// save stores the state of a running job. A failed write only delays the
+// progress seen by clients, so it is logged rather than stopping the import.
+func (m *ImportJobManager) save(job models.ImportJob) {
+	if err := m.store.Set(job); err != nil {
+		fmt.Printf("Warning: Failed to save import job %s: %v\n", job.ID, err)
+	}
+}
+
+// Get retrieves a job by ID
+func (m *ImportJobManager) Get(id string) (models.ImportJob, bool) {
+	return m.store.Get(id)
+}
+
+// Cancel requests cancellation of a queued or running job. The import
+// transaction is rolled back, so nothing from a cancelled job is kept.
+func (m *ImportJobManager) Cancel(id string) error {
+	job, exists := m.store.Get(id)
+	if !exists {
+		return fmt.Errorf("匯入工作不存在")
+	}
+	if job.Status.IsFinished() {
+		return fmt.Errorf("匯入工作已結束，無法取消")
+	}
+
+	if err := m.store.RequestCancel(id); err != nil {
+		return fmt.Errorf("failed to cancel import job: %w", err)
+	}
+	// Stop at once when the job runs here; other replicas poll the request
+	m.cancelLocal(id)
+	return nil
+}
+
+// Subscribe returns a channel that receives the job state whenever it
+// changes, starting with the current state, and a function to stop
+// receiving updates. The channel is closed once the job has finished or
+// expired.
+func (m *ImportJobManager) Subscribe(id string) (<-chan models.ImportJob, func(), bool) {
+	job, exists := m.store.Get(id)
+	if !exists {
+		return nil, nil, false
+	}
+
+	ch := make(chan models.ImportJob, 1)
+	ch <- job
+	done := make(chan struct{})
+	go m.follow(id, job, ch, done)
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() { close(done) })
+	}
+	return ch, unsubscribe, true
+}
+
+// follow polls the storage and sends the job state whenever it changes
+func (m *ImportJobManager) follow(id string, last models.ImportJob, ch chan models.ImportJob, done <-chan struct{}) {
+	defer close(ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for !last.Status.IsFinished() {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		job, exists := m.store.Get(id)
+		if !exists {
+			return
+		}
+		if job.Status == last.Status && job.ProcessedRows == last.ProcessedRows &&
+			job.InsertedCount == last.InsertedCount && job.SkippedCount == last.SkippedCount {
+			continue
+		}
+
+		// Subscribers only need the latest state, so replace an unread update
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- job
+		last = job
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/wei979/ICACP/backend/internal/models"
+)
+
+// ImportJobStorage stores the state of background import jobs, so every
+// replica can report a job's progress and accept its cancellation, not only
+// the replica running it
+type ImportJobStorage interface {
+	// Set stores the current state of a job
+	Set(job models.ImportJob) error
+	// Get retrieves a job by ID, returns false if not found or expired
+	Get(id string) (models.ImportJob, bool)
+	// RequestCancel flags a job for cancellation. The replica running the
+	// job polls IsCancelRequested and stops it.
+	RequestCancel(id string) error
+	// IsCancelRequested checks if cancellation of a job was requested
+	IsCancelRequested(id string) bool
+}
+
+// NewImportJobStorage returns a Redis backed storage when Redis is available,
+// so jobs are shared between replicas like previews are, and falls back to
+// the in-memory store otherwise (e.g. in development)
+func NewImportJobStorage(redisClient *redis.Client, retention time.Duration) ImportJobStorage {
+	if redisClient != nil {
+		return NewRedisImportJobStore(redisClient, retention)
+	}
+	return NewImportJobStore(retention)
+}
+
+// ImportJobStore keeps import jobs in memory. Finished jobs are removed
+// after the retention period.
+type ImportJobStore struct {
+	mu        sync.RWMutex
+	jobs      map[string]models.ImportJob
+	cancelled map[string]bool
+	retention time.Duration
+}
+
+// NewImportJobStore creates a new ImportJobStore that keeps finished jobs for
+// the given retention period
+func NewImportJobStore(retention time.Duration) *ImportJobStore {
+	store := &ImportJobStore{
+		jobs:      make(map[string]models.ImportJob),
+		cancelled: make(map[string]bool),
+		retention: retention,
+	}
+	// Start cleanup goroutine
+	go store.cleanupFinished()
+	return store
+}
+
+// Set stores the current state of a job
+func (s *ImportJobStore) Set(job models.ImportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Get retrieves a job by ID
+func (s *ImportJobStore) Get(id string) (models.ImportJob, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[id]
+	return job, exists
+}
+
+// RequestCancel flags a job for cancellation
+func (s *ImportJobStore) RequestCancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancelled[id] = true
+	return nil
+}
+
+// IsCancelRequested checks if cancellation of a job was requested
+func (s *ImportJobStore) IsCancelRequested(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cancelled[id]
+}
+
+// cleanupFinished periodically removes jobs that finished longer ago than
+// the retention period
+func (s *ImportJobStore) cleanupFinished() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		cutoff := time.Now().Add(-s.retention)
+		for id, job := range s.jobs {
+			if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+				delete(s.jobs, id)
+				delete(s.cancelled, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+)
+
+// studentRowFields lists the raw values of a student preview row in template
+// column order, keyed like ImportRow.Data
+var studentRowFields = []string{"student_number", "name", "gender", "grade", "class", "birth_date"}
+
+// recordRowFields lists the raw values of a records preview row in template
+// column order, keyed like ImportRow.Data
+var recordRowFields = []string{"student_number", "name", "height", "weight", "sit_reach", "standing_jump", "sit_ups", "cardio", "test_date"}
+
+// schoolRowFields lists the raw values of a school preview row in template
+// column order, keyed like ImportRow.Data
+var schoolRowFields = []string{"school_code", "school_name", "county_name", "address", "phone", "latitude", "longitude"}
+
+// editablePreviewRow loads a preview that can still be changed and finds one
+// of its rows. It returns a copy of the preview: the stored one may be read
+// by an execution at the same time, and the store refuses to save the copy
+// once the preview has been claimed.
+func (s *ImportService) editablePreviewRow(previewID, sheet string, rowNumber int) (*models.ImportPreview, *models.ImportRow, error) {
+	stored := s.store.Get(previewID)
+	if stored == nil {
+		return nil, nil, fmt.Errorf("預覽資料不存在或已過期，請重新上傳檔案")
+	}
+	if stored.Executed || s.store.IsExecuted(previewID) {
+		return nil, nil, fmt.Errorf("此預覽已被執行，無法修改")
+	}
+
+	preview := copyPreview(stored)
+	for i := range preview.Rows {
+		if preview.Rows[i].RowNumber == rowNumber && preview.Rows[i].Sheet == sheet {
+			return preview, &preview.Rows[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("預覽中沒有第 %d 列", rowNumber)
+}
+
+// PatchPreviewRow changes raw values of a preview row, revalidates that row
+// and refreshes the preview's counts. Each changed value is kept in the
+// preview's edit history, which is saved with the import batch.
+func (s *ImportService) PatchPreviewRow(previewID string, rowNumber int, req *models.PatchPreviewRowRequest, editedBy uint) (*models.ImportPreview, error) {
+	preview, row, err := s.editablePreviewRow(previewID, req.Sheet, rowNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Data) == 0 {
+		return nil, fmt.Errorf("請提供要修改的欄位")
+	}
+
+	fields := studentRowFields
+	switch preview.Type {
+	case models.ImportTypeRecords:
+		fields = recordRowFields
+	case models.ImportTypeSchools:
+		fields = schoolRowFields
+	}
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+	for field := range req.Data {
+		if !known[field] {
+			return nil, fmt.Errorf("無法修改欄位 %s", field)
+		}
+	}
+
+	// Apply the new values over the row's current ones
+	now := time.Now()
+	values := make([]string, len(fields))
+	edits := make([]models.PreviewRowEdit, 0, len(req.Data))
+	for i, field := range fields {
+		oldValue, _ := row.Data[field].(string)
+		values[i] = oldValue
+		newValue, patched := req.Data[field]
+		if !patched {
+			continue
+		}
+		newValue = TrimString(newValue)
+		values[i] = newValue
+		if newValue != oldValue {
+			edits = append(edits, models.PreviewRowEdit{
+				Sheet:     row.Sheet,
+				RowNumber: row.RowNumber,
+				Field:     field,
+				OldValue:  oldValue,
+				NewValue:  newValue,
+				EditedBy:  editedBy,
+				EditedAt:  now,
+			})
+		}
+	}
+	if len(edits) == 0 {
+		return preview, nil
+	}
+
+	switch preview.Type {
+	case models.ImportTypeStudents:
+		ctx, err := s.loadStudentImportContext(preview.SchoolID, preview.Mode)
+		if err != nil {
+			return nil, err
+		}
+		// Numbers of the other rows, for duplicates within the file
+		for _, other := range preview.Rows {
+			number, _ := other.Data["student_number"].(string)
+			if other.RowNumber == row.RowNumber || number == "" {
+				continue
+			}
+			if _, exists := ctx.studentNumbers[number]; !exists {
+				ctx.studentNumbers[number] = other.RowNumber
+			}
+		}
+
+		*row = s.validateStudentRow(row.RowNumber, values, ctx)
+		preview.Edits = append(preview.Edits, edits...)
+		recountImportPreview(preview)
+
+	case models.ImportTypeRecords:
+		grade, class := preview.Grade, preview.Class
+		for _, sheet := range preview.Sheets {
+			if row.Sheet != "" && sheet.Name == row.Sheet {
+				grade, class = sheet.Grade, sheet.Class
+			}
+		}
+		ctx, err := s.loadRecordsImportContext(preview.SchoolID, grade, class)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep a student picked from the suggestions unless the number or
+		// name that identified the row changed
+		selectedID, selected := row.Data["student_id"].(uint)
+		for _, edit := range edits {
+			if edit.Field == "student_number" || edit.Field == "name" {
+				selected = false
+			}
+		}
+
+		revalidated := s.validateRecordRow(row.RowNumber, values, ctx)
+		revalidated.Sheet = row.Sheet
+		*row = revalidated
+		if _, matched := row.Data["student_id"]; selected && !matched {
+			assignStudent(row, selectedID)
+		}
+		preview.Edits = append(preview.Edits, edits...)
+		if err := s.refreshRecordsPreview(preview); err != nil {
+			return nil, err
+		}
+
+	case models.ImportTypeSchools:
+		ctx, err := s.loadSchoolImportContext(preview.Mode)
+		if err != nil {
+			return nil, err
+		}
+		// Codes and names of the other rows, for duplicates within the file
+		for _, other := range preview.Rows {
+			if other.RowNumber == row.RowNumber {
+				continue
+			}
+			if code, ok := other.Data["school_code_normalized"].(string); ok {
+				if _, exists := ctx.fileCodes[code]; !exists {
+					ctx.fileCodes[code] = other.RowNumber
+				}
+			}
+			name, _ := other.Data["school_name"].(string)
+			if county, ok := other.Data["county_normalized"].(string); ok && name != "" {
+				if key := schoolNameKey(name, county); ctx.fileNames[key] == 0 {
+					ctx.fileNames[key] = other.RowNumber
+				}
+			}
+		}
+
+		*row = s.validateSchoolRow(row.RowNumber, values, ctx)
+		preview.Edits = append(preview.Edits, edits...)
+		recountImportPreview(preview)
+
+	default:
+		return nil, fmt.Errorf("預覽類型不正確")
+	}
+
+	if err := s.store.Save(previewID, preview); err != nil {
+		return nil, fmt.Errorf("無法儲存預覽資料: %w", err)
+	}
+
+	return preview, nil
+}
+
+// SelectStudent assigns a student to a records preview row that matched no
+// student, usually one of the row's suggestions. The row is revalidated and
+// the preview's counts and duplicate records are refreshed.
+func (s *ImportService) SelectStudent(previewID string, rowNumber int, req *models.SelectStudentRequest) (*models.ImportPreview, error) {
+	preview, row, err := s.editablePreviewRow(previewID, req.Sheet, rowNumber)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Type != models.ImportTypeRecords {
+		return nil, fmt.Errorf("預覽類型不正確")
+	}
+
+	var student models.Student
+	if err := s.db.First(&student, req.StudentID).Error; err != nil {
+		return nil, fmt.Errorf("學生不存在")
+	}
+	if student.SchoolID != preview.SchoolID {
+		return nil, fmt.Errorf("學生不屬於此預覽的學校")
+	}
+
+	assignStudent(row, student.ID)
+
+	if err := s.refreshRecordsPreview(preview); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(previewID, preview); err != nil {
+		return nil, fmt.Errorf("無法儲存預覽資料: %w", err)
+	}
+
+	return preview, nil
+}
+
+// copyPreview copies a preview deep enough to edit it: the rows, their data
+// and the per-sheet counts and edit history that edits change
+func copyPreview(stored *models.ImportPreview) *models.ImportPreview {
+	preview := *stored
+	preview.Sheets = append([]models.ImportSheetSummary(nil), stored.Sheets...)
+	preview.Edits = append([]models.PreviewRowEdit(nil), stored.Edits...)
+	preview.Rows = make([]models.ImportRow, len(stored.Rows))
+	for i, row := range stored.Rows {
+		data := make(map[string]interface{}, len(row.Data))
+		for key, value := range row.Data {
+			data[key] = value
+		}
+		row.Data = data
+		preview.Rows[i] = row
+	}
+	return &preview
+}
+
+// assignStudent sets the student of a records row and drops its student
+// matching errors; the other validations still apply
+func assignStudent(row *models.ImportRow, studentID uint) {
+	errors := make([]models.RowError, 0, len(row.Errors))
+	for _, rowErr := range row.Errors {
+		if rowErr.Field == "student" {
+			continue
+		}
+		if rowErr.Field == "student_number" &&
+			(rowErr.Code == models.ErrorCodeFormerNumber || rowErr.Code == models.ErrorCodeNumberReused) {
+			continue
+		}
+		errors = append(errors, rowErr)
+	}
+	row.Errors = errors
+	row.Data["student_id"] = studentID
+	row.Suggestions = nil
+	row.Status = rowStatusFromErrors(row.Errors)
+}
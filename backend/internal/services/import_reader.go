@@ -0,0 +1,546 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/extrame/xls"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Spreadsheet formats accepted by the import pipeline
+const (
+	FormatXLSX = "xlsx"
+	FormatXLS  = "xls"
+	FormatODS  = "ods"
+	FormatCSV  = "csv"
+)
+
+// Text encodings detected for CSV files
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom"
+	EncodingBig5    = "big5"
+)
+
+// SupportedImportExtensions lists the file extensions accepted for import
+var SupportedImportExtensions = []string{".xlsx", ".xls", ".ods", ".csv"}
+
+// Sheet is one worksheet of an uploaded file as rows of cell text
+type Sheet struct {
+	Name string
+	Rows [][]string
+}
+
+// Workbook is the format-independent content of an uploaded spreadsheet
+type Workbook struct {
+	Format   string
+	Encoding string // only set for text formats
+	Hash     string // SHA-256 of the file content
+	Data     []byte // original file content
+	Sheets   []Sheet
+}
+
+// maxImportRows, maxImportCells and maxImportText bound what one uploaded
+// workbook may expand to, across all of its sheets. Compressed formats (xlsx,
+// ods) and repeated ranges can describe far more than the 5MB upload suggests.
+const (
+	maxImportRows  = 100000
+	maxImportCells = 2000000
+	maxImportText  = 64 << 20 // bytes of cell text assembled while reading
+)
+
+// importBudget counts the rows, cells and text read from one workbook
+type importBudget struct {
+	rows  int
+	cells int
+	text  int
+}
+
+// addRow accounts for one row of the given width, failing once the workbook
+// is over maxImportRows or maxImportCells
+func (b *importBudget) addRow(cells int) error {
+	b.rows++
+	b.cells += cells
+	if b.rows > maxImportRows || b.cells > maxImportCells {
+		return errImportTooLarge
+	}
+	return nil
+}
+
+// addCells accounts for cells of a row still being read
+func (b *importBudget) addCells(cells int) error {
+	if b.cells+cells > maxImportCells {
+		return errImportTooLarge
+	}
+	return nil
+}
+
+// addText accounts for cell text assembled by the reader
+func (b *importBudget) addText(n int) error {
+	b.text += n
+	if b.text > maxImportText {
+		return errImportTooLarge
+	}
+	return nil
+}
+
+// errImportTooLarge is returned when a workbook is over the import budget
+var errImportTooLarge = fmt.Errorf("檔案過大，最多可匯入 %d 列", maxImportRows)
+
+// SheetReader reads the sheets of one spreadsheet format
+type SheetReader interface {
+	Read(data []byte) (*Workbook, error)
+}
+
+// sheetReaders maps each format to its reader. New formats only need a reader
+// registered here and a detection rule in DetectFormat.
+var sheetReaders = map[string]SheetReader{
+	FormatXLSX: xlsxReader{},
+	FormatXLS:  xlsReader{},
+	FormatODS:  odsReader{},
+	FormatCSV:  csvReader{},
+}
+
+// ReadWorkbook detects the format of an uploaded file and reads its sheets
+func ReadWorkbook(r io.Reader, filename string) (*Workbook, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取檔案: %w", err)
+	}
+
+	format := DetectFormat(data, filename)
+	reader, ok := sheetReaders[format]
+	if !ok {
+		return nil, fmt.Errorf("不支援的檔案格式")
+	}
+
+	wb, err := reader.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("檔案沒有工作表")
+	}
+
+	sum := sha256.Sum256(data)
+	wb.Hash = hex.EncodeToString(sum[:])
+	wb.Data = data
+
+	return wb, nil
+}
+
+// DetectFormat determines the spreadsheet format from the file content,
+// falling back to the file extension
+func DetectFormat(data []byte, filename string) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		// Both xlsx and ods are zip packages; ods declares its mimetype first
+		if bytes.Contains(data[:min(len(data), 128)], []byte("application/vnd.oasis.opendocument.spreadsheet")) {
+			return FormatODS
+		}
+		if strings.EqualFold(filepath.Ext(filename), ".ods") {
+			return FormatODS
+		}
+		return FormatXLSX
+	case bytes.HasPrefix(data, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return FormatXLS
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+}
+
+// xlsxReader reads Office Open XML workbooks
+type xlsxReader struct{}
+
+func (xlsxReader) Read(data []byte) (*Workbook, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 Excel 檔案: %w", err)
+	}
+	defer f.Close()
+
+	wb := &Workbook{Format: FormatXLSX}
+	var budget importBudget
+	for _, name := range f.GetSheetList() {
+		rows, err := xlsxRows(f, name, &budget)
+		if err != nil {
+			return nil, err
+		}
+		wb.Sheets = append(wb.Sheets, Sheet{Name: name, Rows: rows})
+	}
+	return wb, nil
+}
+
+// xlsxRows streams the rows of one sheet, stopping as soon as the workbook is
+// over the import budget
+func xlsxRows(f *excelize.File, name string, budget *importBudget) ([][]string, error) {
+	iter, err := f.Rows(name)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取工作表: %w", err)
+	}
+	defer iter.Close()
+
+	var rows [][]string
+	for iter.Next() {
+		row, err := iter.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("無法讀取工作表: %w", err)
+		}
+		if err := budget.addRow(len(row)); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("無法讀取工作表: %w", err)
+	}
+	return trimTrailingEmptyRows(rows), nil
+}
+
+// xlsReader reads legacy Excel 97-2003 (BIFF8) workbooks
+type xlsReader struct{}
+
+func (xlsReader) Read(data []byte) (*Workbook, error) {
+	book, err := xls.OpenReader(bytes.NewReader(data), "utf-8")
+	if err != nil || book == nil {
+		return nil, fmt.Errorf("無法解析 Excel 97-2003 檔案")
+	}
+
+	wb := &Workbook{Format: FormatXLS}
+	var budget importBudget
+	for i := 0; i < book.NumSheets(); i++ {
+		sheet := book.GetSheet(i)
+		if sheet == nil {
+			continue
+		}
+
+		var rows [][]string
+		for r := 0; r <= int(sheet.MaxRow); r++ {
+			row := xlsRow(sheet, r)
+			width := 0
+			if row != nil {
+				width = row.LastCol() + 1
+			}
+			if err := budget.addRow(width); err != nil {
+				return nil, err
+			}
+			if row == nil {
+				rows = append(rows, []string{})
+				continue
+			}
+			cells := make([]string, 0, row.LastCol()+1)
+			for c := 0; c <= row.LastCol(); c++ {
+				cells = append(cells, row.Col(c))
+			}
+			rows = append(rows, trimTrailingEmpty(cells))
+		}
+		wb.Sheets = append(wb.Sheets, Sheet{Name: sheet.Name, Rows: trimTrailingEmptyRows(rows)})
+	}
+	return wb, nil
+}
+
+// xlsRow returns a row of an xls sheet, or nil when the row has no cells.
+// The xls package dereferences missing rows, so the panic is recovered here.
+func xlsRow(sheet *xls.WorkSheet, i int) (row *xls.Row) {
+	defer func() {
+		if recover() != nil {
+			row = nil
+		}
+	}()
+	return sheet.Row(i)
+}
+
+// csvReader reads comma, semicolon or tab separated text in UTF-8 or Big5
+type csvReader struct{}
+
+func (csvReader) Read(data []byte) (*Workbook, error) {
+	text, encoding, err := decodeText(data)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = detectDelimiter(text)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 CSV 檔案: %w", err)
+	}
+	var budget importBudget
+	for _, row := range rows {
+		if err := budget.addRow(len(row)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Workbook{
+		Format:   FormatCSV,
+		Encoding: encoding,
+		Sheets:   []Sheet{{Name: "CSV", Rows: rows}},
+	}, nil
+}
+
+// decodeText converts file content to UTF-8, detecting UTF-8 (with or without
+// BOM) and Big5
+func decodeText(data []byte) (string, string, error) {
+	if bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) {
+		return string(data[3:]), EncodingUTF8BOM, nil
+	}
+	if utf8.Valid(data) {
+		return string(data), EncodingUTF8, nil
+	}
+
+	decoded, err := traditionalchinese.Big5.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("無法辨識檔案編碼，請使用 UTF-8 或 Big5")
+	}
+	return string(decoded), EncodingBig5, nil
+}
+
+// detectDelimiter picks the most frequent delimiter in the first line
+func detectDelimiter(text string) rune {
+	firstLine := text
+	if idx := strings.IndexAny(text, "\r\n"); idx >= 0 {
+		firstLine = text[:idx]
+	}
+
+	best, bestCount := ',', strings.Count(firstLine, ",")
+	for _, delimiter := range []rune{';', '\t'} {
+		if count := strings.Count(firstLine, string(delimiter)); count > bestCount {
+			best, bestCount = delimiter, count
+		}
+	}
+	return best
+}
+
+// odsReader reads OpenDocument spreadsheets (LibreOffice .ods)
+type odsReader struct{}
+
+// maxODSRepeat caps the runs of empty rows/cells kept from repeated ranges, so
+// a styled but empty range (LibreOffice repeats up to 1048576 rows) is not
+// expanded. Repeated content is counted against the import budget instead.
+const maxODSRepeat = 1000
+
+func (odsReader) Read(data []byte) (*Workbook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 ODS 檔案: %w", err)
+	}
+
+	var content io.ReadCloser
+	for _, file := range zr.File {
+		if file.Name == "content.xml" {
+			content, err = file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("無法解析 ODS 檔案: %w", err)
+			}
+			break
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("無法解析 ODS 檔案：缺少 content.xml")
+	}
+	defer content.Close()
+
+	wb := &Workbook{Format: FormatODS}
+	var budget importBudget
+	decoder := xml.NewDecoder(content)
+
+	var (
+		sheet        *Sheet
+		row          []string
+		pendingCells int // empty cells not yet known to precede content
+		pendingRows  int // empty rows not yet known to precede content
+		rowRepeat    int
+		cell         *odsCell
+		inText       bool
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("無法解析 ODS 檔案: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "table":
+				if t.Name.Space == odsTableNS {
+					sheet = &Sheet{Name: odsAttr(t, "name")}
+					pendingRows = 0
+				}
+			case "table-row":
+				row = []string{}
+				pendingCells = 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+			case "table-cell", "covered-table-cell":
+				cell = &odsCell{
+					repeat:    odsRepeat(t, "number-columns-repeated"),
+					valueType: odsAttr(t, "value-type"),
+					value:     odsAttr(t, "value"),
+					dateValue: odsAttr(t, "date-value"),
+				}
+			case "p":
+				if cell != nil {
+					if cell.text.Len() > 0 {
+						cell.text.WriteString("\n")
+					}
+					inText = true
+				}
+			case "s":
+				if inText {
+					spaces := odsRepeat(t, "c")
+					if err := budget.addText(spaces); err != nil {
+						return nil, err
+					}
+					cell.text.WriteString(strings.Repeat(" ", spaces))
+				}
+			case "tab":
+				if inText {
+					cell.text.WriteString("\t")
+				}
+			}
+		case xml.CharData:
+			if inText {
+				if err := budget.addText(len(t)); err != nil {
+					return nil, err
+				}
+				cell.text.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				inText = false
+			case "table-cell", "covered-table-cell":
+				if cell == nil {
+					continue
+				}
+				value := cell.String()
+				if value == "" {
+					pendingCells = min(pendingCells+cell.repeat, maxODSRepeat)
+				} else {
+					if err := budget.addCells(len(row) + pendingCells + cell.repeat); err != nil {
+						return nil, err
+					}
+					for ; pendingCells > 0; pendingCells-- {
+						row = append(row, "")
+					}
+					for i := 0; i < cell.repeat; i++ {
+						row = append(row, value)
+					}
+				}
+				cell = nil
+			case "table-row":
+				if sheet == nil {
+					continue
+				}
+				if len(row) == 0 {
+					pendingRows = min(pendingRows+rowRepeat, maxODSRepeat)
+					continue
+				}
+				for ; pendingRows > 0; pendingRows-- {
+					if err := budget.addRow(0); err != nil {
+						return nil, err
+					}
+					sheet.Rows = append(sheet.Rows, []string{})
+				}
+				for i := 0; i < rowRepeat; i++ {
+					if err := budget.addRow(len(row)); err != nil {
+						return nil, err
+					}
+					sheet.Rows = append(sheet.Rows, append([]string(nil), row...))
+				}
+			case "table":
+				if sheet != nil && t.Name.Space == odsTableNS {
+					wb.Sheets = append(wb.Sheets, *sheet)
+					sheet = nil
+				}
+			}
+		}
+	}
+
+	return wb, nil
+}
+
+// odsTableNS is the OpenDocument table namespace
+const odsTableNS = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+
+// odsCell collects one table cell while parsing
+type odsCell struct {
+	repeat    int
+	valueType string
+	value     string
+	dateValue string
+	text      strings.Builder
+}
+
+// String returns the cell value: raw numbers for numeric cells (avoiding locale
+// formatting), ISO dates for date cells and the displayed text otherwise
+func (c *odsCell) String() string {
+	switch c.valueType {
+	case "float", "percentage", "currency":
+		if c.value != "" {
+			return c.value
+		}
+	case "date":
+		if len(c.dateValue) >= 10 {
+			return c.dateValue[:10]
+		}
+	}
+	return c.text.String()
+}
+
+// odsAttr returns the value of an attribute by local name
+func odsAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns a repeat count attribute, defaulting to 1 and capped at
+// maxImportCells so sums of counts cannot overflow
+func odsRepeat(el xml.StartElement, name string) int {
+	n, err := strconv.Atoi(odsAttr(el, name))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, maxImportCells+1)
+}
+
+// trimTrailingEmpty removes empty cells at the end of a row
+func trimTrailingEmpty(cells []string) []string {
+	end := len(cells)
+	for end > 0 && strings.TrimSpace(cells[end-1]) == "" {
+		end--
+	}
+	return cells[:end]
+}
+
+// trimTrailingEmptyRows removes empty rows at the end of a sheet
+func trimTrailingEmptyRows(rows [][]string) [][]string {
+	end := len(rows)
+	for end > 0 && len(rows[end-1]) == 0 {
+		end--
+	}
+	return rows[:end]
+}
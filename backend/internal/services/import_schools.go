@@ -0,0 +1,596 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/wei979/ICACP/backend/internal/models"
+	"golang.org/x/text/width"
+	"gorm.io/gorm"
+)
+
+// Bounds of Taiwan and its outlying islands; coordinates outside them are
+// usually swapped or mistyped
+const (
+	taiwanMinLatitude  = 21.8
+	taiwanMaxLatitude  = 26.5
+	taiwanMinLongitude = 118.0
+	taiwanMaxLongitude = 122.1
+)
+
+// schoolImportContext holds the lookups used while validating school rows
+type schoolImportContext struct {
+	mode      models.ImportMode
+	codes     map[string]*models.School // school code -> school, deleted schools included
+	names     map[string]*models.School // "name|county" -> school
+	fileCodes map[string]int            // school code -> row number, for duplicates within the file
+	fileNames map[string]int            // "name|county" -> row number, for duplicates within the file
+}
+
+// normalizeCountyName narrows full-width characters, trims and writes 台 as
+// 臺, the form used by the official county names
+func normalizeCountyName(county string) string {
+	return strings.ReplaceAll(strings.TrimSpace(width.Narrow.String(county)), "台", "臺")
+}
+
+// schoolNameKey identifies a school by its name and county
+func schoolNameKey(name, county string) string {
+	return strings.Join(strings.Fields(width.Narrow.String(name)), "") + "|" + normalizeCountyName(county)
+}
+
+// PreviewSchoolImport parses and validates a school master-data file. Rows
+// are matched to existing schools by code, or by name and county when no
+// code is given. The header row and columns are detected unless the client
+// confirmed a mapping.
+func (s *ImportService) PreviewSchoolImport(file multipart.File, filename string, mode models.ImportMode, columns *models.ColumnMappingOptions) (*models.ImportPreview, error) {
+	if mode == "" {
+		mode = models.ImportModeCreateOnly
+	}
+	if !models.IsValidImportMode(mode) {
+		return nil, fmt.Errorf("無效的匯入模式")
+	}
+
+	// Read the file (xlsx, xls, ods or csv) and use its first sheet
+	wb, err := ReadWorkbook(file, filename)
+	if err != nil {
+		return nil, err
+	}
+	rows := wb.Sheets[0].Rows
+
+	// Validate we have data
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("檔案沒有資料列（僅有標題或為空）")
+	}
+
+	// Find the header row and the column of each field; school imports keep
+	// their saved mapping under school ID 0
+	headerIdx, columnMap, err := s.resolveColumns(rows, models.ImportTypeSchools, 0, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create preview
+	preview := &models.ImportPreview{
+		ID:         uuid.New().String(),
+		Type:       models.ImportTypeSchools,
+		Mode:       mode,
+		FileName:   filename,
+		FileFormat: wb.Format,
+		Encoding:   wb.Encoding,
+		FileHash:   wb.Hash,
+		SheetName:  wb.Sheets[0].Name,
+		ColumnMap:  columnMap,
+		FileData:   wb.Data,
+		Rows:       make([]models.ImportRow, 0),
+	}
+
+	ctx, err := s.loadSchoolImportContext(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse and validate each row, reordered into template column order
+	for i, row := range remapRows(rows[headerIdx+1:], columnMap, models.SchoolImportFields) {
+		rowNum := headerIdx + i + 2 // Excel rows start at 1, skip header
+		importRow := s.validateSchoolRow(rowNum, row, ctx)
+		preview.Rows = append(preview.Rows, importRow)
+	}
+
+	recountImportPreview(preview)
+
+	// Store preview
+	if err := s.store.Set(preview.ID, preview); err != nil {
+		return nil, fmt.Errorf("無法儲存預覽資料: %w", err)
+	}
+
+	return preview, nil
+}
+
+// loadSchoolImportContext loads the existing schools to match school rows.
+// Deleted schools are kept in the code lookup because the code is unique in
+// the table.
+func (s *ImportService) loadSchoolImportContext(mode models.ImportMode) (*schoolImportContext, error) {
+	var schools []models.School
+	if err := s.db.Unscoped().Find(&schools).Error; err != nil {
+		return nil, fmt.Errorf("無法載入學校資料: %w", err)
+	}
+
+	ctx := &schoolImportContext{
+		mode:      mode,
+		codes:     make(map[string]*models.School),
+		names:     make(map[string]*models.School),
+		fileCodes: make(map[string]int),
+		fileNames: make(map[string]int),
+	}
+	for i := range schools {
+		school := &schools[i]
+		if school.SchoolCode != nil {
+			ctx.codes[*school.SchoolCode] = school
+		}
+		if !school.DeletedAt.Valid {
+			ctx.names[schoolNameKey(school.Name, school.CountyName)] = school
+		}
+	}
+	return ctx, nil
+}
+
+// validateSchoolRow validates a single school row
+func (s *ImportService) validateSchoolRow(rowNum int, row []string, ctx *schoolImportContext) models.ImportRow {
+	importRow := models.ImportRow{
+		RowNumber: rowNum,
+		Status:    models.RowStatusValid,
+		Data:      make(map[string]interface{}),
+		Errors:    make([]models.RowError, 0),
+	}
+	addError := func(field, code, message string) {
+		importRow.Errors = append(importRow.Errors, models.RowError{Field: field, Code: code, Message: message, Level: "error"})
+		importRow.Status = models.RowStatusError
+	}
+	addWarning := func(field, code, message string) {
+		importRow.Errors = append(importRow.Errors, models.RowError{Field: field, Code: code, Message: message, Level: "warning"})
+		if importRow.Status == models.RowStatusValid {
+			importRow.Status = models.RowStatusWarning
+		}
+	}
+
+	// Extract values
+	codeRaw := TrimString(GetCellValue(row, 0))
+	name := TrimString(GetCellValue(row, 1))
+	countyRaw := TrimString(GetCellValue(row, 2))
+	address := TrimString(GetCellValue(row, 3))
+	phone := TrimString(GetCellValue(row, 4))
+	latitudeRaw := TrimString(GetCellValue(row, 5))
+	longitudeRaw := TrimString(GetCellValue(row, 6))
+
+	// Store raw data
+	importRow.Data["school_code"] = codeRaw
+	importRow.Data["school_name"] = name
+	importRow.Data["county_name"] = countyRaw
+	importRow.Data["address"] = address
+	importRow.Data["phone"] = phone
+	importRow.Data["latitude"] = latitudeRaw
+	importRow.Data["longitude"] = longitudeRaw
+
+	// Validate school code (optional)
+	code := ""
+	if normalized := normalizeSchoolCode(width.Narrow.String(codeRaw)); normalized != nil {
+		code = *normalized
+		if len(code) > 10 {
+			addError("school_code", models.ErrorCodeMaxLength, "學校代碼不可超過 10 字元")
+			code = ""
+		} else {
+			importRow.Data["school_code_normalized"] = code
+		}
+	}
+
+	// Validate name (required)
+	if IsEmpty(name) {
+		addError("school_name", models.ErrorCodeRequired, "學校名稱為必填欄位")
+	} else if utf8.RuneCountInString(name) > 100 {
+		addError("school_name", models.ErrorCodeMaxLength, "學校名稱不可超過 100 字元")
+	}
+
+	// Validate county against the official county names (required)
+	county := normalizeCountyName(countyRaw)
+	countyValid := false
+	if IsEmpty(countyRaw) {
+		addError("county_name", models.ErrorCodeRequired, "縣市為必填欄位")
+	} else if !models.IsValidCountyName(county) {
+		addError("county_name", models.ErrorCodeInvalidValue, fmt.Sprintf("無效的縣市名稱：%s", countyRaw))
+	} else {
+		countyValid = true
+		importRow.Data["county_normalized"] = county
+	}
+
+	// Validate address (optional)
+	if utf8.RuneCountInString(address) > 255 {
+		addError("address", models.ErrorCodeMaxLength, "地址不可超過 255 字元")
+	} else if countyValid && address != "" {
+		addressCounty := normalizeCountyName(address)
+		for _, other := range models.ValidTaiwanCounties {
+			if other != county && strings.HasPrefix(addressCounty, other) {
+				addWarning("address", models.ErrorCodeInvalidValue, fmt.Sprintf("地址位於%s，與縣市欄位（%s）不符", other, county))
+				break
+			}
+		}
+	}
+
+	// Validate phone (optional)
+	if utf8.RuneCountInString(phone) > 20 {
+		addError("phone", models.ErrorCodeMaxLength, "電話不可超過 20 字元")
+	}
+
+	// Validate coordinates (optional, but both or neither)
+	validateSchoolCoordinates(&importRow, latitudeRaw, longitudeRaw, addError, addWarning)
+
+	// Check for duplicates within file, by code and by name and county
+	nameKey := ""
+	if name != "" && countyValid {
+		nameKey = schoolNameKey(name, county)
+	}
+	if code != "" {
+		if prevRow, exists := ctx.fileCodes[code]; exists {
+			addError("school_code", models.ErrorCodeDuplicate, fmt.Sprintf("學校代碼 %s 重複（與第 %d 列）", code, prevRow))
+		} else {
+			ctx.fileCodes[code] = rowNum
+		}
+	}
+	if nameKey != "" {
+		if prevRow, exists := ctx.fileNames[nameKey]; exists {
+			addError("school_name", models.ErrorCodeDuplicate, fmt.Sprintf("%s%s 重複（與第 %d 列）", county, name, prevRow))
+		} else {
+			ctx.fileNames[nameKey] = rowNum
+		}
+	}
+
+	if nameKey != "" {
+		planSchoolRow(&importRow, code, nameKey, ctx)
+	}
+
+	return importRow
+}
+
+// validateSchoolCoordinates parses the latitude and longitude of a school row
+func validateSchoolCoordinates(importRow *models.ImportRow, latitudeRaw, longitudeRaw string, addError, addWarning func(field, code, message string)) {
+	if latitudeRaw == "" && longitudeRaw == "" {
+		return
+	}
+	if latitudeRaw == "" || longitudeRaw == "" {
+		field := "latitude"
+		if longitudeRaw == "" {
+			field = "longitude"
+		}
+		addError(field, models.ErrorCodeRequired, "緯度與經度須同時填寫")
+		return
+	}
+
+	valid := true
+	latitude, err := ParseFloat(latitudeRaw)
+	if err != nil {
+		addError("latitude", models.ErrorCodeInvalidType, "緯度必須是數字")
+		valid = false
+	} else if latitude < -90 || latitude > 90 {
+		addError("latitude", models.ErrorCodeOutOfRange, "緯度必須介於 -90 至 90")
+		valid = false
+	}
+	longitude, err := ParseFloat(longitudeRaw)
+	if err != nil {
+		addError("longitude", models.ErrorCodeInvalidType, "經度必須是數字")
+		valid = false
+	} else if longitude < -180 || longitude > 180 {
+		addError("longitude", models.ErrorCodeOutOfRange, "經度必須介於 -180 至 180")
+		valid = false
+	}
+	if !valid {
+		return
+	}
+
+	if latitude < taiwanMinLatitude || latitude > taiwanMaxLatitude ||
+		longitude < taiwanMinLongitude || longitude > taiwanMaxLongitude {
+		addWarning("latitude", models.ErrorCodeOutOfRange, "座標不在臺灣範圍內，請確認緯度與經度是否顛倒")
+	}
+	importRow.Data["latitude_parsed"] = latitude
+	importRow.Data["longitude_parsed"] = longitude
+}
+
+// planSchoolRow matches a row to an existing school and decides whether it
+// creates or updates a school according to the import mode
+func planSchoolRow(importRow *models.ImportRow, code, nameKey string, ctx *schoolImportContext) {
+	byCode := ctx.codes[code]
+	byName := ctx.names[nameKey]
+
+	if byCode != nil && byCode.DeletedAt.Valid {
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "school_code",
+			Code:    models.ErrorCodeDuplicate,
+			Message: fmt.Sprintf("學校代碼 %s 已由已刪除的學校（%s）使用", code, byCode.Name),
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		importRow.Action = models.RowActionCreate
+		return
+	}
+	if byCode != nil && byName != nil && byCode.ID != byName.ID {
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "school_code",
+			Code:    models.ErrorCodeDuplicate,
+			Message: fmt.Sprintf("學校代碼 %s 屬於 %s%s，與同名學校（ID %d）不是同一所", code, byCode.CountyName, byCode.Name, byName.ID),
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		return
+	}
+
+	current := byCode
+	if current == nil {
+		current = byName
+	}
+
+	switch {
+	case current != nil && ctx.mode == models.ImportModeCreateOnly:
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "school_name",
+			Code:    models.ErrorCodeDuplicate,
+			Message: fmt.Sprintf("學校已存在（%s%s，ID %d），如需更新資料請使用更新模式", current.CountyName, current.Name, current.ID),
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		importRow.Action = models.RowActionCreate
+
+	case current == nil && ctx.mode == models.ImportModeUpdateOnly:
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "school_name",
+			Code:    models.ErrorCodeNotFound,
+			Message: "沒有相同代碼或同縣市同名的學校，更新模式不會新增學校",
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		importRow.Action = models.RowActionUpdate
+
+	case current != nil:
+		importRow.Data["existing_school_id"] = current.ID
+		importRow.Changes = schoolChanges(current, importRow.Data)
+		importRow.Action = models.RowActionUpdate
+		if len(importRow.Changes) == 0 {
+			importRow.Action = models.RowActionUnchanged
+		}
+
+	default:
+		importRow.Action = models.RowActionCreate
+	}
+}
+
+// schoolChanges compares an existing school with the parsed values of an
+// import row. Empty optional cells keep the current value.
+func schoolChanges(school *models.School, data map[string]interface{}) []models.FieldChange {
+	changes := make([]models.FieldChange, 0)
+
+	if code, ok := data["school_code_normalized"].(string); ok && (school.SchoolCode == nil || *school.SchoolCode != code) {
+		oldValue := ""
+		if school.SchoolCode != nil {
+			oldValue = *school.SchoolCode
+		}
+		changes = append(changes, models.FieldChange{Field: "school_code", OldValue: oldValue, NewValue: code})
+	}
+	if name, ok := data["school_name"].(string); ok && name != "" && name != school.Name {
+		changes = append(changes, models.FieldChange{Field: "school_name", OldValue: school.Name, NewValue: name})
+	}
+	if county, ok := data["county_normalized"].(string); ok && county != school.CountyName {
+		changes = append(changes, models.FieldChange{Field: "county_name", OldValue: school.CountyName, NewValue: county})
+	}
+	if address, ok := data["address"].(string); ok && address != "" && address != school.Address {
+		changes = append(changes, models.FieldChange{Field: "address", OldValue: school.Address, NewValue: address})
+	}
+	if phone, ok := data["phone"].(string); ok && phone != "" && phone != school.Phone {
+		changes = append(changes, models.FieldChange{Field: "phone", OldValue: school.Phone, NewValue: phone})
+	}
+	if latitude, ok := data["latitude_parsed"].(float64); ok {
+		if change, changed := coordinateChange("latitude", school.Latitude, latitude); changed {
+			changes = append(changes, change)
+		}
+	}
+	if longitude, ok := data["longitude_parsed"].(float64); ok {
+		if change, changed := coordinateChange("longitude", school.Longitude, longitude); changed {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// coordinateChange compares a stored coordinate with an imported one
+func coordinateChange(field string, current *float64, value float64) (models.FieldChange, bool) {
+	newValue := strconv.FormatFloat(value, 'f', -1, 64)
+	if current == nil {
+		return models.FieldChange{Field: field, NewValue: newValue}, true
+	}
+	oldValue := strconv.FormatFloat(*current, 'f', -1, 64)
+	// The columns keep 8 decimals
+	if fmt.Sprintf("%.8f", *current) == fmt.Sprintf("%.8f", value) {
+		return models.FieldChange{}, false
+	}
+	return models.FieldChange{Field: field, OldValue: oldValue, NewValue: newValue}, true
+}
+
+// ExecuteSchoolImport creates and updates schools from a validated preview
+func (s *ImportService) ExecuteSchoolImport(req *models.ExecuteImportRequest, uploadedBy uint) (*models.ImportResult, error) {
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeSchools)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.runSchoolImport(context.Background(), preview, req, uploadedBy, nil)
+}
+
+// StartSchoolImportJob claims a preview and imports its schools in a
+// background job
+func (s *ImportService) StartSchoolImportJob(req *models.ExecuteImportRequest, uploadedBy uint) (models.ImportJob, error) {
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeSchools)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	job, err := s.jobs.Start(models.ImportTypeSchools, req.PreviewID, len(preview.Rows),
+		func(ctx context.Context, progress func(models.ImportProgress)) (*models.ImportResult, error) {
+			return s.runSchoolImport(ctx, preview, req, uploadedBy, progress)
+		})
+	if err != nil {
+		s.store.Release(req.PreviewID)
+		return models.ImportJob{}, err
+	}
+	return job, nil
+}
+
+// runSchoolImport creates and updates the schools of a claimed preview.
+// The preview is released again when the import fails or is cancelled.
+func (s *ImportService) runSchoolImport(ctx context.Context, preview *models.ImportPreview, req *models.ExecuteImportRequest, uploadedBy uint, progress func(models.ImportProgress)) (*models.ImportResult, error) {
+	result := &models.ImportResult{
+		PreviewID:    preview.ID,
+		Type:         models.ImportTypeSchools,
+		SuccessCount: 0,
+		SkipCount:    0,
+		Errors:       make([]models.ImportedError, 0),
+		ExecutedAt:   time.Now(),
+	}
+
+	// Execute in transaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		importBatch, err := createImportBatch(tx, preview, req, uploadedBy)
+		if err != nil {
+			return err
+		}
+
+		for i, row := range preview.Rows {
+			if i > 0 && i%importBatchSize == 0 {
+				reportImportProgress(progress, i, result)
+				if ctx.Err() != nil {
+					return errImportCancelled
+				}
+			}
+
+			if skipImportRow(row, req.IncludeWarnings, result) {
+				continue
+			}
+
+			switch row.Action {
+			case models.RowActionUnchanged:
+				result.UnchangedCount++
+				continue
+			case models.RowActionUpdate:
+				changes, err := applySchoolUpdate(tx, row)
+				if err != nil {
+					if err.Error() == "學校已不存在" {
+						result.SkipCount++
+						result.Errors = append(result.Errors, models.ImportedError{
+							RowNumber: row.RowNumber,
+							Field:     "school_name",
+							Message:   "要更新的學校已被刪除",
+						})
+						continue
+					}
+					return fmt.Errorf("更新學校失敗（第 %d 列）: %w", row.RowNumber, err)
+				}
+				if len(changes) == 0 {
+					result.UnchangedCount++
+					continue
+				}
+				importBatch.UpdatedSchoolIDs = append(importBatch.UpdatedSchoolIDs, row.Data["existing_school_id"].(uint))
+				result.SuccessCount++
+				result.UpdatedCount++
+				continue
+			}
+
+			// Create school
+			school := models.School{
+				Name:       row.Data["school_name"].(string),
+				CountyName: row.Data["county_normalized"].(string),
+				Address:    row.Data["address"].(string),
+				Phone:      row.Data["phone"].(string),
+			}
+			if code, ok := row.Data["school_code_normalized"].(string); ok {
+				school.SchoolCode = &code
+			}
+			if latitude, ok := row.Data["latitude_parsed"].(float64); ok {
+				longitude := row.Data["longitude_parsed"].(float64)
+				school.Latitude = &latitude
+				school.Longitude = &longitude
+			}
+			school.District = models.ExtractDistrict(school.CountyName, school.Address)
+			school.Level = models.InferSchoolLevel(school.Name)
+
+			if err := tx.Create(&school).Error; err != nil {
+				return fmt.Errorf("建立學校失敗（第 %d 列）: %w", row.RowNumber, err)
+			}
+			importBatch.CreatedSchoolIDs = append(importBatch.CreatedSchoolIDs, school.ID)
+			result.SuccessCount++
+			result.CreatedCount++
+		}
+		reportImportProgress(progress, len(preview.Rows), result)
+
+		return finishImportBatch(tx, importBatch, result)
+	})
+
+	if err != nil {
+		// Allow the preview to be executed again after a failed transaction
+		s.store.Release(preview.ID)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// applySchoolUpdate writes the changes of an update row to its school
+func applySchoolUpdate(tx *gorm.DB, row models.ImportRow) ([]models.FieldChange, error) {
+	var school models.School
+	if err := tx.First(&school, row.Data["existing_school_id"].(uint)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("學校已不存在")
+		}
+		return nil, fmt.Errorf("failed to get school: %w", err)
+	}
+
+	// Diff against the current values; the school may have changed since the preview
+	changes := schoolChanges(&school, row.Data)
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	locationChanged := false
+	for _, change := range changes {
+		switch change.Field {
+		case "school_code":
+			code := change.NewValue
+			school.SchoolCode = &code
+		case "school_name":
+			school.Name = change.NewValue
+		case "county_name":
+			school.CountyName = change.NewValue
+			locationChanged = true
+		case "address":
+			school.Address = change.NewValue
+			locationChanged = true
+		case "phone":
+			school.Phone = change.NewValue
+		case "latitude":
+			latitude := row.Data["latitude_parsed"].(float64)
+			school.Latitude = &latitude
+		case "longitude":
+			longitude := row.Data["longitude_parsed"].(float64)
+			school.Longitude = &longitude
+		}
+	}
+	if locationChanged {
+		school.District = models.ExtractDistrict(school.CountyName, school.Address)
+	}
+
+	if err := tx.Save(&school).Error; err != nil {
+		return nil, fmt.Errorf("failed to update school: %w", err)
+	}
+	return changes, nil
+}
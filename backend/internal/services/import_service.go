@@ -1,14 +1,19 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"mime/multipart"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
-	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/width"
 	"gorm.io/gorm"
 
 	"github.com/wei979/ICACP/backend/internal/models"
@@ -17,24 +22,29 @@ import (
 // ImportService handles Excel import operations
 type ImportService struct {
 	db    *gorm.DB
-	store *PreviewStore
+	store PreviewStorage
+	jobs  *ImportJobManager
 }
 
-// NewImportService creates a new ImportService
-func NewImportService(db *gorm.DB) *ImportService {
+// NewImportService creates a new ImportService. Previews and background jobs
+// are kept in Redis when a client is given and in memory otherwise.
+func NewImportService(db *gorm.DB, redisClient *redis.Client) *ImportService {
 	return &ImportService{
 		db:    db,
-		store: NewPreviewStore(15 * time.Minute), // 15 minute TTL
+		store: NewPreviewStorage(redisClient, 15*time.Minute), // 15 minute TTL
+		jobs:  NewImportJobManager(NewImportJobStorage(redisClient, time.Hour), 500*time.Millisecond),
 	}
 }
 
-// ValidateFileFormat checks if the file is a valid .xlsx file
+// ValidateFileFormat checks if the file has a supported spreadsheet extension
 func (s *ImportService) ValidateFileFormat(filename string) error {
 	ext := strings.ToLower(filepath.Ext(filename))
-	if ext != ".xlsx" {
-		return fmt.Errorf("僅支援 .xlsx 格式的 Excel 檔案")
+	for _, supported := range SupportedImportExtensions {
+		if ext == supported {
+			return nil
+		}
 	}
-	return nil
+	return fmt.Errorf("僅支援 .xlsx、.xls、.ods 或 .csv 格式的檔案")
 }
 
 // ValidateFileSize checks if file size is within limit (5MB)
@@ -46,63 +56,97 @@ func (s *ImportService) ValidateFileSize(size int64) error {
 	return nil
 }
 
-// PreviewStudentImport parses and validates student Excel file
-func (s *ImportService) PreviewStudentImport(file multipart.File, filename string, schoolID uint) (*models.ImportPreview, error) {
+// PreviewStudentImport parses and validates student Excel file. The header
+// row and columns are detected unless the client confirmed a mapping.
+func (s *ImportService) PreviewStudentImport(file multipart.File, filename string, schoolID uint, mode models.ImportMode, columns *models.ColumnMappingOptions) (*models.ImportPreview, error) {
+	if mode == "" {
+		mode = models.ImportModeCreateOnly
+	}
+	if !models.IsValidImportMode(mode) {
+		return nil, fmt.Errorf("無效的匯入模式")
+	}
+
 	// Verify school exists
 	var school models.School
 	if err := s.db.First(&school, schoolID).Error; err != nil {
 		return nil, fmt.Errorf("找不到 ID 為 %d 的學校", schoolID)
 	}
 
-	// Open Excel file
-	f, err := excelize.OpenReader(file)
+	// Read the file (xlsx, xls, ods or csv) and use its first sheet
+	wb, err := ReadWorkbook(file, filename)
 	if err != nil {
-		return nil, fmt.Errorf("無法解析 Excel 檔案: %w", err)
-	}
-	defer f.Close()
-
-	// Get first sheet
-	sheetName := f.GetSheetName(0)
-	if sheetName == "" {
-		return nil, fmt.Errorf("Excel 檔案沒有工作表")
-	}
-
-	// Read all rows
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("無法讀取工作表: %w", err)
+		return nil, err
 	}
+	rows := wb.Sheets[0].Rows
 
 	// Validate we have data
 	if len(rows) < 2 {
-		return nil, fmt.Errorf("Excel 檔案沒有資料列（僅有標題或為空）")
+		return nil, fmt.Errorf("檔案沒有資料列（僅有標題或為空）")
 	}
 
-	// Validate header row
-	if err := s.validateStudentHeaders(rows[0]); err != nil {
+	// Find the header row and the column of each field
+	headerIdx, columnMap, err := s.resolveColumns(rows, models.ImportTypeStudents, schoolID, columns)
+	if err != nil {
 		return nil, err
 	}
 
 	// Create preview
 	preview := &models.ImportPreview{
-		ID:       uuid.New().String(),
-		Type:     models.ImportTypeStudents,
-		SchoolID: schoolID,
-		FileName: filename,
-		Rows:     make([]models.ImportRow, 0),
+		ID:         uuid.New().String(),
+		Type:       models.ImportTypeStudents,
+		SchoolID:   schoolID,
+		Mode:       mode,
+		FileName:   filename,
+		FileFormat: wb.Format,
+		Encoding:   wb.Encoding,
+		FileHash:   wb.Hash,
+		SheetName:  wb.Sheets[0].Name,
+		ColumnMap:  columnMap,
+		FileData:   wb.Data,
+		Rows:       make([]models.ImportRow, 0),
 	}
 
-	// Track student numbers for duplicate detection
-	studentNumbers := make(map[string]int) // value -> row number
+	// Load the school's students so existing and former numbers can be flagged
+	ctx, err := s.loadStudentImportContext(schoolID, mode)
+	if err != nil {
+		return nil, err
+	}
 
-	// Parse and validate each row
-	for i, row := range rows[1:] {
-		rowNum := i + 2 // Excel rows start at 1, skip header
-		importRow := s.validateStudentRow(rowNum, row, studentNumbers)
+	// Parse and validate each row, reordered into template column order
+	for i, row := range remapRows(rows[headerIdx+1:], columnMap, models.StudentImportFields) {
+		rowNum := headerIdx + i + 2 // Excel rows start at 1, skip header
+		importRow := s.validateStudentRow(rowNum, row, ctx)
 		preview.Rows = append(preview.Rows, importRow)
+	}
+
+	recountImportPreview(preview)
+
+	// Store preview
+	if err := s.store.Set(preview.ID, preview); err != nil {
+		return nil, fmt.Errorf("無法儲存預覽資料: %w", err)
+	}
+
+	return preview, nil
+}
+
+// copyColumnMap copies a template column map so a preview can adjust its own
+func copyColumnMap(columns map[string]int) map[string]int {
+	copied := make(map[string]int, len(columns))
+	for field, col := range columns {
+		copied[field] = col
+	}
+	return copied
+}
+
+// recountImportPreview recounts the rows of a student or school preview by
+// status and by the action executing them will take
+func recountImportPreview(preview *models.ImportPreview) {
+	preview.TotalRows = len(preview.Rows)
+	preview.ValidRows, preview.WarningRows, preview.ErrorRows = 0, 0, 0
+	preview.CreateRows, preview.UpdateRows, preview.SameRows = 0, 0, 0
 
-		// Update counts
-		switch importRow.Status {
+	for _, row := range preview.Rows {
+		switch row.Status {
 		case models.RowStatusValid:
 			preview.ValidRows++
 		case models.RowStatusWarning:
@@ -110,35 +154,107 @@ func (s *ImportService) PreviewStudentImport(file multipart.File, filename strin
 		case models.RowStatusError:
 			preview.ErrorRows++
 		}
+		if row.Status != models.RowStatusError {
+			switch row.Action {
+			case models.RowActionCreate:
+				preview.CreateRows++
+			case models.RowActionUpdate:
+				preview.UpdateRows++
+			case models.RowActionUnchanged:
+				preview.SameRows++
+			}
+		}
 	}
+}
 
-	preview.TotalRows = len(preview.Rows)
+// loadStudentImportContext loads the students of a school and their former
+// numbers to validate student rows
+func (s *ImportService) loadStudentImportContext(schoolID uint, mode models.ImportMode) (*studentImportContext, error) {
+	var existing []models.Student
+	if err := s.db.Where("school_id = ?", schoolID).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("無法載入學生資料: %w", err)
+	}
+	formerNumbers, err := s.loadFormerNumbers(schoolID, existing)
+	if err != nil {
+		return nil, err
+	}
 
-	// Store preview
-	s.store.Set(preview.ID, preview)
+	currentStudents := make(map[string]*models.Student, len(existing))
+	for i := range existing {
+		currentStudents[existing[i].StudentNumber] = &existing[i]
+	}
 
-	return preview, nil
+	return &studentImportContext{
+		mode:            mode,
+		studentNumbers:  make(map[string]int),
+		currentStudents: currentStudents,
+		formerNumbers:   formerNumbers,
+	}, nil
 }
 
-// validateStudentHeaders checks if the header row matches expected format
-func (s *ImportService) validateStudentHeaders(headers []string) error {
-	expected := models.StudentTemplateHeaders
-	if len(headers) < len(expected) {
-		return fmt.Errorf("Excel 標題列格式不正確，請使用系統提供的模板")
+// studentImportContext holds the lookups used while validating student rows
+type studentImportContext struct {
+	mode            models.ImportMode
+	studentNumbers  map[string]int             // student number -> row number, for duplicates within the file
+	currentStudents map[string]*models.Student // current student number -> existing student
+	formerNumbers   formerNumberIndex
+}
+
+// recordsImportContext holds the lookups used while validating record rows
+type recordsImportContext struct {
+	studentMap     map[string]*models.Student // "student_number|name" -> student, normalized
+	currentNumbers map[string]*models.Student // current student number -> student
+	formerNumbers  formerNumberIndex
+	candidates     []models.Student // students of the grade, for suggestions
+}
+
+// formerNumberIndex maps a former student number to the students who used it
+type formerNumberIndex map[string][]*models.Student
+
+// loadFormerNumbers builds the former number index of a school, limited to the
+// given students
+func (s *ImportService) loadFormerNumbers(schoolID uint, students []models.Student) (formerNumberIndex, error) {
+	var history []models.StudentNumberHistory
+	if err := s.db.Where("school_id = ?", schoolID).
+		Order("changed_at DESC").
+		Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("無法載入座號變更記錄: %w", err)
+	}
+
+	byID := make(map[uint]*models.Student, len(students))
+	for i := range students {
+		byID[students[i].ID] = &students[i]
 	}
 
-	// Check first few required columns
-	for i := 0; i < 4; i++ { // Check 座號, 姓名, 性別, 年級
-		if !strings.Contains(headers[i], strings.TrimSuffix(expected[i], "*")) {
-			return fmt.Errorf("Excel 標題列格式不正確，請使用系統提供的模板")
+	index := make(formerNumberIndex)
+	seen := make(map[string]bool)
+	for _, h := range history {
+		student, exists := byID[h.StudentID]
+		if !exists || student.StudentNumber == h.StudentNumber {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d", h.StudentNumber, student.ID)
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+		index[h.StudentNumber] = append(index[h.StudentNumber], student)
 	}
 
-	return nil
+	return index, nil
+}
+
+// holderNames joins the names of the given students for messages
+func holderNames(students []*models.Student) string {
+	names := make([]string, 0, len(students))
+	for _, student := range students {
+		names = append(names, fmt.Sprintf("%s（現為座號 %s）", student.Name, student.StudentNumber))
+	}
+	return strings.Join(names, "、")
 }
 
 // validateStudentRow validates a single student row
-func (s *ImportService) validateStudentRow(rowNum int, row []string, studentNumbers map[string]int) models.ImportRow {
+func (s *ImportService) validateStudentRow(rowNum int, row []string, ctx *studentImportContext) models.ImportRow {
 	importRow := models.ImportRow{
 		RowNumber: rowNum,
 		Status:    models.RowStatusValid,
@@ -173,7 +289,7 @@ func (s *ImportService) validateStudentRow(rowNum int, row []string, studentNumb
 		importRow.Status = models.RowStatusError
 	} else {
 		// Check for duplicates within file
-		if prevRow, exists := studentNumbers[studentNumber]; exists {
+		if prevRow, exists := ctx.studentNumbers[studentNumber]; exists {
 			importRow.Errors = append(importRow.Errors, models.RowError{
 				Field:   "student_number",
 				Code:    models.ErrorCodeDuplicate,
@@ -182,7 +298,7 @@ func (s *ImportService) validateStudentRow(rowNum int, row []string, studentNumb
 			})
 			importRow.Status = models.RowStatusError
 		} else {
-			studentNumbers[studentNumber] = rowNum
+			ctx.studentNumbers[studentNumber] = rowNum
 		}
 	}
 
@@ -282,29 +398,323 @@ func (s *ImportService) validateStudentRow(rowNum int, row []string, studentNumb
 		}
 	}
 
+	if ValidateStudentNumber(studentNumber) == nil {
+		planStudentRow(&importRow, studentNumber, ctx)
+	}
+
 	return importRow
 }
 
-// ExecuteStudentImport creates students from a validated preview
-func (s *ImportService) ExecuteStudentImport(previewID string, includeWarnings bool) (*models.ImportResult, error) {
+// planStudentRow decides whether a row creates or updates a student according
+// to the import mode, and records the field changes of updates
+func planStudentRow(importRow *models.ImportRow, studentNumber string, ctx *studentImportContext) {
+	current, exists := ctx.currentStudents[studentNumber]
+
+	switch {
+	case exists && ctx.mode == models.ImportModeCreateOnly:
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "student_number",
+			Code:    models.ErrorCodeDuplicate,
+			Message: fmt.Sprintf("座號 %s 已存在（%s），如需更新資料請使用更新模式", studentNumber, current.Name),
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		importRow.Action = models.RowActionCreate
+
+	case !exists && ctx.mode == models.ImportModeUpdateOnly:
+		importRow.Errors = append(importRow.Errors, models.RowError{
+			Field:   "student_number",
+			Code:    models.ErrorCodeNotFound,
+			Message: fmt.Sprintf("找不到座號 %s 的學生，更新模式不會新增學生", studentNumber),
+			Level:   "error",
+		})
+		importRow.Status = models.RowStatusError
+		importRow.Action = models.RowActionUpdate
+
+	case exists:
+		importRow.Data["existing_student_id"] = current.ID
+		importRow.Changes = studentChanges(current, importRow.Data)
+		importRow.Action = models.RowActionUpdate
+		if len(importRow.Changes) == 0 {
+			importRow.Action = models.RowActionUnchanged
+		}
+
+	default:
+		importRow.Action = models.RowActionCreate
+
+		// Warn when the number used to belong to an existing student
+		if holders, found := ctx.formerNumbers[studentNumber]; found {
+			importRow.Errors = append(importRow.Errors, models.RowError{
+				Field:   "student_number",
+				Code:    models.ErrorCodeFormerNumber,
+				Message: fmt.Sprintf("座號 %s 曾為 %s 使用，若為同一位學生請改用現座號", studentNumber, holderNames(holders)),
+				Level:   "warning",
+			})
+			if importRow.Status == models.RowStatusValid {
+				importRow.Status = models.RowStatusWarning
+			}
+		}
+	}
+}
+
+// studentChanges compares an existing student with the parsed values of an
+// import row. Empty optional cells (class, birth date) keep the current value.
+func studentChanges(student *models.Student, data map[string]interface{}) []models.FieldChange {
+	changes := make([]models.FieldChange, 0)
+
+	if name, ok := data["name"].(string); ok && name != "" && name != student.Name {
+		changes = append(changes, models.FieldChange{Field: "name", OldValue: student.Name, NewValue: name})
+	}
+	if gender, ok := data["gender_normalized"].(string); ok && gender != student.Gender {
+		changes = append(changes, models.FieldChange{Field: "gender", OldValue: student.Gender, NewValue: gender})
+	}
+	if grade, ok := data["grade_parsed"].(int); ok && grade != student.Grade {
+		changes = append(changes, models.FieldChange{Field: "grade", OldValue: strconv.Itoa(student.Grade), NewValue: strconv.Itoa(grade)})
+	}
+	if class, ok := data["class"].(string); ok {
+		if class = NormalizeClassName(class); class != "" && class != student.Class {
+			changes = append(changes, models.FieldChange{Field: "class", OldValue: student.Class, NewValue: class})
+		}
+	}
+	if birthDate, ok := data["birth_date_parsed"].(time.Time); ok {
+		oldValue := ""
+		if student.BirthDate != nil {
+			oldValue = student.BirthDate.Format("2006-01-02")
+		}
+		if newValue := birthDate.Format("2006-01-02"); newValue != oldValue {
+			changes = append(changes, models.FieldChange{Field: "birth_date", OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	return changes
+}
+
+// applyStudentUpdate applies the changes of an update row to the current
+// state of the student and audits each changed field. It returns the applied
+// changes, which are empty when the student already matches the row.
+func applyStudentUpdate(tx *gorm.DB, row models.ImportRow, preview *models.ImportPreview, academicYear int, changedBy uint) ([]models.FieldChange, error) {
+	studentID, _ := row.Data["existing_student_id"].(uint)
+
+	var student models.Student
+	if err := tx.First(&student, studentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("學生已不存在")
+		}
+		return nil, fmt.Errorf("failed to get student: %w", err)
+	}
+
+	// Diff against the current values; the student may have changed since the preview
+	changes := studentChanges(&student, row.Data)
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	className := student.Class
+	classChanged := false
+	for _, change := range changes {
+		switch change.Field {
+		case "name":
+			student.Name = change.NewValue
+		case "gender":
+			student.Gender = change.NewValue
+		case "grade":
+			student.Grade, _ = strconv.Atoi(change.NewValue)
+			classChanged = true
+		case "class":
+			className = change.NewValue
+			classChanged = true
+		case "birth_date":
+			birthDate := row.Data["birth_date_parsed"].(time.Time)
+			student.BirthDate = &birthDate
+		}
+	}
+
+	if classChanged {
+		class, err := ResolveClass(tx, student.SchoolID, academicYear, student.Grade, className)
+		if err != nil {
+			return nil, err
+		}
+		setStudentClass(&student, class)
+	}
+
+	if err := tx.Save(&student).Error; err != nil {
+		return nil, fmt.Errorf("failed to update student: %w", err)
+	}
+
+	now := time.Now()
+	audits := make([]models.StudentAudit, 0, len(changes))
+	for _, change := range changes {
+		audits = append(audits, models.StudentAudit{
+			StudentID: student.ID,
+			Action:    models.StudentAuditActionImportUpdate,
+			Field:     change.Field,
+			OldValue:  change.OldValue,
+			NewValue:  change.NewValue,
+			ChangedBy: changedBy,
+			ChangedAt: now,
+			Reason:    fmt.Sprintf("批次匯入 - %s", preview.FileName),
+		})
+	}
+	if err := tx.Create(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to record audit: %w", err)
+	}
+
+	return changes, nil
+}
+
+// importBatchSize is the number of rows inserted per statement and the
+// interval at which import progress is reported
+const importBatchSize = 500
+
+// claimPreview loads a preview of the given type and claims it for execution
+func (s *ImportService) claimPreview(previewID string, importType models.ImportType) (*models.ImportPreview, error) {
 	// Get preview
 	preview := s.store.Get(previewID)
 	if preview == nil {
 		return nil, fmt.Errorf("預覽資料不存在或已過期，請重新上傳檔案")
 	}
 
-	// Check if already executed
-	if s.store.IsExecuted(previewID) {
+	// Verify the import type
+	if preview.Type != importType {
+		return nil, fmt.Errorf("預覽類型不正確")
+	}
+
+	// Claim the preview so it cannot be executed twice
+	if !s.store.Claim(previewID) {
 		return nil, fmt.Errorf("此預覽已被執行，請重新上傳檔案")
 	}
 
-	// Verify it's a student import
-	if preview.Type != models.ImportTypeStudents {
-		return nil, fmt.Errorf("預覽類型不正確")
+	return preview, nil
+}
+
+// skipImportRow reports whether a row is left out of the import and records
+// the reason in the result
+func skipImportRow(row models.ImportRow, includeWarnings bool, result *models.ImportResult) bool {
+	// Skip error rows
+	if row.Status == models.RowStatusError {
+		result.SkipCount++
+		if len(row.Errors) > 0 {
+			result.Errors = append(result.Errors, models.ImportedError{
+				Sheet:     row.Sheet,
+				RowNumber: row.RowNumber,
+				Field:     row.Errors[0].Field,
+				Message:   row.Errors[0].Message,
+			})
+		}
+		return true
 	}
 
+	// Skip warning rows if not included
+	if row.Status == models.RowStatusWarning && !includeWarnings {
+		result.SkipCount++
+		if len(row.Errors) > 0 {
+			result.Errors = append(result.Errors, models.ImportedError{
+				Sheet:     row.Sheet,
+				RowNumber: row.RowNumber,
+				Field:     row.Errors[0].Field,
+				Message:   row.Errors[0].Message + " (警告被跳過)",
+			})
+		}
+		return true
+	}
+
+	return false
+}
+
+// reportImportProgress sends a progress update when a callback is given
+func reportImportProgress(progress func(models.ImportProgress), processedRows int, result *models.ImportResult) {
+	if progress == nil {
+		return
+	}
+	progress(models.ImportProgress{
+		ProcessedRows: processedRows,
+		InsertedCount: result.SuccessCount,
+		SkippedCount:  result.SkipCount,
+	})
+}
+
+// createImportBatch records the start of an import in the history log
+func createImportBatch(tx *gorm.DB, preview *models.ImportPreview, req *models.ExecuteImportRequest, uploadedBy uint) (*models.ImportBatch, error) {
+	// School imports are not tied to one school
+	var schoolID *uint
+	if preview.SchoolID != 0 {
+		schoolID = &preview.SchoolID
+	}
+
+	importBatch := &models.ImportBatch{
+		Type:              preview.Type,
+		SchoolID:          schoolID,
+		PreviewID:         preview.ID,
+		FileName:          preview.FileName,
+		FileHash:          preview.FileHash,
+		FileFormat:        preview.FileFormat,
+		Grade:             preview.Grade,
+		Class:             preview.Class,
+		Mode:              preview.Mode,
+		IncludeWarnings:   req.IncludeWarnings,
+		TotalRows:         len(preview.Rows),
+		CreatedStudentIDs: []uint{},
+		CreatedRecordIDs:  []uint{},
+		CreatedSchoolIDs:  []uint{},
+		UpdatedStudentIDs: []uint{},
+		ReplacedRecordIDs: []uint{},
+		UpdatedSchoolIDs:  []uint{},
+		RowEdits:          append([]models.PreviewRowEdit{}, preview.Edits...),
+		Status:            models.ImportBatchCompleted,
+		UploadedBy:        uploadedBy,
+		UploadedAt:        time.Now(),
+	}
+	if err := tx.Create(importBatch).Error; err != nil {
+		return nil, fmt.Errorf("failed to create import batch: %w", err)
+	}
+	return importBatch, nil
+}
+
+// finishImportBatch stores the counts and created IDs of an import
+func finishImportBatch(tx *gorm.DB, importBatch *models.ImportBatch, result *models.ImportResult) error {
+	importBatch.SuccessCount = result.SuccessCount
+	importBatch.SkipCount = result.SkipCount
+	if err := tx.Save(importBatch).Error; err != nil {
+		return fmt.Errorf("failed to save import batch: %w", err)
+	}
+	result.BatchID = importBatch.ID
+	return nil
+}
+
+// ExecuteStudentImport creates students from a validated preview
+func (s *ImportService) ExecuteStudentImport(req *models.ExecuteImportRequest, uploadedBy uint) (*models.ImportResult, error) {
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeStudents)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.runStudentImport(context.Background(), preview, req, uploadedBy, nil)
+}
+
+// StartStudentImportJob claims a preview and creates its students in a
+// background job
+func (s *ImportService) StartStudentImportJob(req *models.ExecuteImportRequest, uploadedBy uint) (models.ImportJob, error) {
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeStudents)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	job, err := s.jobs.Start(models.ImportTypeStudents, req.PreviewID, len(preview.Rows),
+		func(ctx context.Context, progress func(models.ImportProgress)) (*models.ImportResult, error) {
+			return s.runStudentImport(ctx, preview, req, uploadedBy, progress)
+		})
+	if err != nil {
+		s.store.Release(req.PreviewID)
+		return models.ImportJob{}, err
+	}
+	return job, nil
+}
+
+// runStudentImport creates the students of a claimed preview in batches.
+// The preview is released again when the import fails or is cancelled.
+func (s *ImportService) runStudentImport(ctx context.Context, preview *models.ImportPreview, req *models.ExecuteImportRequest, uploadedBy uint, progress func(models.ImportProgress)) (*models.ImportResult, error) {
 	result := &models.ImportResult{
-		PreviewID:    previewID,
+		PreviewID:    preview.ID,
 		Type:         models.ImportTypeStudents,
 		SuccessCount: 0,
 		SkipCount:    0,
@@ -312,32 +722,74 @@ func (s *ImportService) ExecuteStudentImport(previewID string, includeWarnings b
 		ExecutedAt:   time.Now(),
 	}
 
+	academicYear := CurrentAcademicYear()
+
 	// Execute in transaction
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		for _, row := range preview.Rows {
-			// Skip error rows
-			if row.Status == models.RowStatusError {
-				result.SkipCount++
-				if len(row.Errors) > 0 {
-					result.Errors = append(result.Errors, models.ImportedError{
-						RowNumber: row.RowNumber,
-						Field:     row.Errors[0].Field,
-						Message:   row.Errors[0].Message,
-					})
+		importBatch, err := createImportBatch(tx, preview, req, uploadedBy)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]models.Student, 0, importBatchSize)
+		firstRow := 0
+
+		flush := func(lastRow int) error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := tx.CreateInBatches(&batch, importBatchSize).Error; err != nil {
+				return fmt.Errorf("建立學生失敗（第 %d 至 %d 列）: %w", firstRow, lastRow, err)
+			}
+			for _, student := range batch {
+				importBatch.CreatedStudentIDs = append(importBatch.CreatedStudentIDs, student.ID)
+			}
+			result.SuccessCount += len(batch)
+			result.CreatedCount += len(batch)
+			batch = batch[:0]
+			return nil
+		}
+
+		for i, row := range preview.Rows {
+			if i > 0 && i%importBatchSize == 0 {
+				if err := flush(preview.Rows[i-1].RowNumber); err != nil {
+					return err
+				}
+				reportImportProgress(progress, i, result)
+				if ctx.Err() != nil {
+					return errImportCancelled
 				}
+			}
+
+			if skipImportRow(row, req.IncludeWarnings, result) {
 				continue
 			}
 
-			// Skip warning rows if not included
-			if row.Status == models.RowStatusWarning && !includeWarnings {
-				result.SkipCount++
-				if len(row.Errors) > 0 {
-					result.Errors = append(result.Errors, models.ImportedError{
-						RowNumber: row.RowNumber,
-						Field:     row.Errors[0].Field,
-						Message:   row.Errors[0].Message + " (警告被跳過)",
-					})
+			switch row.Action {
+			case models.RowActionUnchanged:
+				result.UnchangedCount++
+				continue
+			case models.RowActionUpdate:
+				changes, err := applyStudentUpdate(tx, row, preview, academicYear, uploadedBy)
+				if err != nil {
+					if err.Error() == "學生已不存在" {
+						result.SkipCount++
+						result.Errors = append(result.Errors, models.ImportedError{
+							RowNumber: row.RowNumber,
+							Field:     "student_number",
+							Message:   "要更新的學生已被刪除",
+						})
+						continue
+					}
+					return fmt.Errorf("更新學生失敗（第 %d 列）: %w", row.RowNumber, err)
 				}
+				if len(changes) == 0 {
+					result.UnchangedCount++
+					continue
+				}
+				importBatch.UpdatedStudentIDs = append(importBatch.UpdatedStudentIDs, row.Data["existing_student_id"].(uint))
+				result.SuccessCount++
+				result.UpdatedCount++
 				continue
 			}
 
@@ -348,34 +800,82 @@ func (s *ImportService) ExecuteStudentImport(previewID string, includeWarnings b
 				Name:          row.Data["name"].(string),
 				Gender:        row.Data["gender_normalized"].(string),
 				Grade:         row.Data["grade_parsed"].(int),
-				Class:         row.Data["class"].(string),
 			}
 
+			class, err := ResolveClass(tx, preview.SchoolID, academicYear, student.Grade, row.Data["class"].(string))
+			if err != nil {
+				return fmt.Errorf("建立班級失敗（第 %d 列）: %w", row.RowNumber, err)
+			}
+			setStudentClass(&student, class)
+
 			// Set birth date if parsed
 			if birthDate, ok := row.Data["birth_date_parsed"].(time.Time); ok {
 				student.BirthDate = &birthDate
 			}
 
-			if err := tx.Create(&student).Error; err != nil {
-				return fmt.Errorf("建立學生失敗（第 %d 列）: %w", row.RowNumber, err)
+			if len(batch) == 0 {
+				firstRow = row.RowNumber
 			}
+			batch = append(batch, student)
+		}
 
-			result.SuccessCount++
+		if len(preview.Rows) > 0 {
+			if err := flush(preview.Rows[len(preview.Rows)-1].RowNumber); err != nil {
+				return err
+			}
 		}
+		reportImportProgress(progress, len(preview.Rows), result)
 
-		return nil
+		return finishImportBatch(tx, importBatch, result)
 	})
 
 	if err != nil {
+		// Allow the preview to be executed again after a failed transaction
+		s.store.Release(preview.ID)
 		return nil, err
 	}
 
-	// Mark preview as executed
-	s.store.MarkExecuted(previewID)
-
 	return result, nil
 }
 
+// StartRecordsImportJob claims a preview and creates its sport records in a
+// background job
+func (s *ImportService) StartRecordsImportJob(req *models.ExecuteImportRequest, uploadedBy uint) (models.ImportJob, error) {
+	if err := normalizeDuplicatePolicy(req); err != nil {
+		return models.ImportJob{}, err
+	}
+
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeRecords)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	job, err := s.jobs.Start(models.ImportTypeRecords, req.PreviewID, len(preview.Rows),
+		func(ctx context.Context, progress func(models.ImportProgress)) (*models.ImportResult, error) {
+			return s.runRecordsImport(ctx, preview, req, uploadedBy, progress)
+		})
+	if err != nil {
+		s.store.Release(req.PreviewID)
+		return models.ImportJob{}, err
+	}
+	return job, nil
+}
+
+// GetJob retrieves a background import job by ID
+func (s *ImportService) GetJob(jobID string) (models.ImportJob, bool) {
+	return s.jobs.Get(jobID)
+}
+
+// CancelJob cancels a running background import job
+func (s *ImportService) CancelJob(jobID string) error {
+	return s.jobs.Cancel(jobID)
+}
+
+// SubscribeJob follows the progress of a background import job
+func (s *ImportService) SubscribeJob(jobID string) (<-chan models.ImportJob, func(), bool) {
+	return s.jobs.Subscribe(jobID)
+}
+
 // GetPreview retrieves a preview by ID
 func (s *ImportService) GetPreview(previewID string) *models.ImportPreview {
 	return s.store.Get(previewID)
@@ -386,114 +886,532 @@ func (s *ImportService) CancelPreview(previewID string) bool {
 	return s.store.Delete(previewID)
 }
 
-// PreviewRecordsImport parses and validates sport records Excel file
-func (s *ImportService) PreviewRecordsImport(file multipart.File, filename string, schoolID uint, grade int, class string) (*models.ImportPreview, error) {
+// PreviewRecordsImport parses and validates sport records Excel file. The
+// header row and columns are detected unless the client confirmed a mapping.
+func (s *ImportService) PreviewRecordsImport(file multipart.File, filename string, schoolID uint, grade int, class string, columns *models.ColumnMappingOptions) (*models.ImportPreview, error) {
 	// Verify school exists
 	var school models.School
 	if err := s.db.First(&school, schoolID).Error; err != nil {
 		return nil, fmt.Errorf("找不到 ID 為 %d 的學校", schoolID)
 	}
 
-	// Open Excel file
-	f, err := excelize.OpenReader(file)
+	// Read the file (xlsx, xls, ods or csv) and use its first sheet
+	wb, err := ReadWorkbook(file, filename)
 	if err != nil {
-		return nil, fmt.Errorf("無法解析 Excel 檔案: %w", err)
+		return nil, err
 	}
-	defer f.Close()
+	rows := wb.Sheets[0].Rows
 
-	// Get first sheet
-	sheetName := f.GetSheetName(0)
-	if sheetName == "" {
-		return nil, fmt.Errorf("Excel 檔案沒有工作表")
+	// Validate we have data
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("檔案沒有資料列（僅有標題或為空）")
 	}
 
-	// Read all rows
-	rows, err := f.GetRows(sheetName)
+	// Find the header row and the column of each field
+	headerIdx, columnMap, err := s.resolveColumns(rows, models.ImportTypeRecords, schoolID, columns)
 	if err != nil {
-		return nil, fmt.Errorf("無法讀取工作表: %w", err)
+		return nil, err
 	}
 
-	// Validate we have data
-	if len(rows) < 2 {
-		return nil, fmt.Errorf("Excel 檔案沒有資料列（僅有標題或為空）")
+	// Load students for the specified school/grade/class for validation
+	class = NormalizeClassName(class)
+	ctx, err := s.loadRecordsImportContext(schoolID, grade, class)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create preview
+	preview := &models.ImportPreview{
+		ID:         uuid.New().String(),
+		Type:       models.ImportTypeRecords,
+		SchoolID:   schoolID,
+		Grade:      grade,
+		Class:      class,
+		FileName:   filename,
+		FileFormat: wb.Format,
+		Encoding:   wb.Encoding,
+		FileHash:   wb.Hash,
+		SheetName:  wb.Sheets[0].Name,
+		ColumnMap:  recordsColumnMap(columnMap),
+		FileData:   wb.Data,
+		Rows:       make([]models.ImportRow, 0),
+	}
+
+	// Parse and validate each row
+	s.validateRecordSheet(preview, "", rows, headerIdx, columnMap, ctx)
+
+	return s.finishRecordsPreview(preview)
+}
+
+// PreviewSchoolRecordsImport parses and validates a whole-school sport records
+// workbook holding one sheet per class. Each sheet name gives the grade and
+// class of its students (see ParseSheetClass); sheets that are not a class,
+// such as the instructions, are listed as skipped with the reason. The header
+// row and columns are resolved per sheet unless the client confirmed a mapping.
+func (s *ImportService) PreviewSchoolRecordsImport(file multipart.File, filename string, schoolID uint, columns *models.ColumnMappingOptions) (*models.ImportPreview, error) {
+	// Verify school exists
+	var school models.School
+	if err := s.db.First(&school, schoolID).Error; err != nil {
+		return nil, fmt.Errorf("找不到 ID 為 %d 的學校", schoolID)
 	}
 
-	// Validate header row
-	if err := s.validateRecordsHeaders(rows[0]); err != nil {
+	wb, err := ReadWorkbook(file, filename)
+	if err != nil {
 		return nil, err
 	}
 
-	// Load students for the specified school/grade/class for validation
+	// Load every student of the school; sheets pick their class from them
 	var students []models.Student
-	query := s.db.Where("school_id = ? AND grade = ?", schoolID, grade)
-	if class != "" {
-		query = query.Where("class = ?", class)
+	if err := s.db.Where("school_id = ?", schoolID).Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("無法載入學生資料: %w", err)
+	}
+	formerNumbers, err := s.loadFormerNumbers(schoolID, students)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.ImportPreview{
+		ID:         uuid.New().String(),
+		Type:       models.ImportTypeRecords,
+		SchoolID:   schoolID,
+		FileName:   filename,
+		FileFormat: wb.Format,
+		Encoding:   wb.Encoding,
+		FileHash:   wb.Hash,
+		FileData:   wb.Data,
+		Rows:       make([]models.ImportRow, 0),
+		Sheets:     make([]models.ImportSheetSummary, 0, len(wb.Sheets)),
+	}
+
+	for _, sheet := range wb.Sheets {
+		summary := models.ImportSheetSummary{Name: sheet.Name}
+
+		grade, class, ok := ParseSheetClass(sheet.Name)
+		if !ok {
+			summary.Skipped = true
+			summary.Reason = "工作表名稱無法對應年級與班級"
+			preview.Sheets = append(preview.Sheets, summary)
+			continue
+		}
+		summary.Grade = grade
+		summary.Class = class
+
+		if len(sheet.Rows) < 2 {
+			summary.Skipped = true
+			summary.Reason = "工作表沒有資料列"
+			preview.Sheets = append(preview.Sheets, summary)
+			continue
+		}
+		headerIdx, columnMap, err := s.resolveColumns(sheet.Rows, models.ImportTypeRecords, schoolID, columns)
+		if err != nil {
+			summary.Skipped = true
+			summary.Reason = err.Error()
+			preview.Sheets = append(preview.Sheets, summary)
+			continue
+		}
+		summary.ColumnMap = recordsColumnMap(columnMap)
+		if preview.ColumnMap == nil {
+			preview.ColumnMap = summary.ColumnMap
+		}
+
+		sheetStudents := classStudents(students, grade, class)
+		if len(sheetStudents) == 0 {
+			summary.Skipped = true
+			summary.Reason = fmt.Sprintf("找不到 %s 的學生", ClassSheetName(grade, class))
+			preview.Sheets = append(preview.Sheets, summary)
+			continue
+		}
+
+		ctx := newRecordsImportContext(sheetStudents, classStudents(students, grade, ""), formerNumbers)
+		s.validateRecordSheet(preview, sheet.Name, sheet.Rows, headerIdx, columnMap, ctx)
+		preview.Sheets = append(preview.Sheets, summary)
+	}
+
+	if len(preview.Rows) == 0 {
+		return nil, fmt.Errorf("活頁簿中沒有可匯入的班級工作表（工作表名稱請使用如「3年1班」的格式）")
+	}
+
+	preview, err = s.finishRecordsPreview(preview)
+	if err != nil {
+		return nil, err
 	}
-	if err := query.Find(&students).Error; err != nil {
+
+	return preview, nil
+}
+
+// loadRecordsImportContext loads the students of a grade to validate the
+// record rows of one of its classes
+func (s *ImportService) loadRecordsImportContext(schoolID uint, grade int, class string) (*recordsImportContext, error) {
+	var students []models.Student
+	if err := s.db.Where("school_id = ? AND grade = ?", schoolID, grade).Find(&students).Error; err != nil {
 		return nil, fmt.Errorf("無法載入學生資料: %w", err)
 	}
 
-	// Build student lookup map (student_number + name -> student)
-	studentMap := make(map[string]*models.Student)
+	formerNumbers, err := s.loadFormerNumbers(schoolID, students)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordsImportContext(classStudents(students, grade, class), students, formerNumbers), nil
+}
+
+// classStudents returns the students of a grade and class; an empty class
+// selects the whole grade
+func classStudents(students []models.Student, grade int, class string) []models.Student {
+	selected := make([]models.Student, 0)
+	for _, student := range students {
+		if student.Grade == grade && (class == "" || student.Class == class) {
+			selected = append(selected, student)
+		}
+	}
+	return selected
+}
+
+// newRecordsImportContext builds the student lookup maps used to validate
+// record rows from the students of the class, and keeps the students of the
+// grade as candidates for rows that match no student
+func newRecordsImportContext(students []models.Student, candidates []models.Student, formerNumbers formerNumberIndex) *recordsImportContext {
+	ctx := &recordsImportContext{
+		studentMap:     make(map[string]*models.Student),
+		currentNumbers: make(map[string]*models.Student),
+		formerNumbers:  make(formerNumberIndex),
+		candidates:     candidates,
+	}
+	inClass := make(map[uint]bool, len(students))
 	for i := range students {
-		key := fmt.Sprintf("%s|%s", students[i].StudentNumber, students[i].Name)
-		studentMap[key] = &students[i]
+		ctx.studentMap[studentMatchKey(students[i].StudentNumber, students[i].Name)] = &students[i]
+		ctx.currentNumbers[normalizeStudentNumber(students[i].StudentNumber)] = &students[i]
+		inClass[students[i].ID] = true
+	}
+	// Keep the former numbers of these students only
+	for number, holders := range formerNumbers {
+		number = normalizeStudentNumber(number)
+		for _, student := range holders {
+			if inClass[student.ID] {
+				ctx.formerNumbers[number] = append(ctx.formerNumbers[number], student)
+			}
+		}
 	}
+	return ctx
+}
 
-	// Create preview
-	preview := &models.ImportPreview{
-		ID:       uuid.New().String(),
-		Type:     models.ImportTypeRecords,
-		SchoolID: schoolID,
-		Grade:    grade,
-		Class:    class,
-		FileName: filename,
-		Rows:     make([]models.ImportRow, 0),
+// normalizeStudentNumber normalizes a student number for matching:
+// full-width digits narrowed, spaces trimmed and leading zeros dropped
+func normalizeStudentNumber(number string) string {
+	number = strings.TrimSpace(width.Narrow.String(number))
+	if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+		return strconv.Itoa(n)
 	}
+	return number
+}
 
-	// Parse and validate each row
-	for i, row := range rows[1:] {
-		rowNum := i + 2
-		importRow := s.validateRecordRow(rowNum, row, studentMap)
+// studentMatchKey is the lookup key of a student number and name
+func studentMatchKey(studentNumber, name string) string {
+	return normalizeStudentNumber(studentNumber) + "|" + FoldName(name)
+}
+
+// maxStudentSuggestions is the number of candidate students offered for a
+// records row that matches no student
+const maxStudentSuggestions = 3
+
+// suggestStudents ranks the students of the grade by how closely their name
+// (and number) match a row, best first
+func (c *recordsImportContext) suggestStudents(studentNumber, name string) []models.StudentSuggestion {
+	number := normalizeStudentNumber(studentNumber)
+	folded := FoldName(name)
+
+	suggestions := make([]models.StudentSuggestion, 0)
+	for _, student := range c.candidates {
+		score := 0.8 * NameSimilarity(folded, FoldName(student.Name))
+		if normalizeStudentNumber(student.StudentNumber) == number {
+			score += 0.2
+		}
+		if score < 0.4 {
+			continue
+		}
+		suggestions = append(suggestions, models.StudentSuggestion{
+			StudentID:     student.ID,
+			StudentNumber: student.StudentNumber,
+			Name:          student.Name,
+			Grade:         student.Grade,
+			Class:         student.Class,
+			Score:         math.Round(score*100) / 100,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	if len(suggestions) > maxStudentSuggestions {
+		suggestions = suggestions[:maxStudentSuggestions]
+	}
+	return suggestions
+}
+
+// validateRecordSheet validates the data rows below the header row of a
+// records sheet and adds them to the preview
+func (s *ImportService) validateRecordSheet(preview *models.ImportPreview, sheetName string, rows [][]string, headerIdx int, columnMap map[string]int, ctx *recordsImportContext) {
+	for i, row := range remapRows(rows[headerIdx+1:], columnMap, models.RecordsImportFields) {
+		rowNum := headerIdx + i + 2
+		importRow := s.validateRecordRow(rowNum, row, ctx)
+		importRow.Sheet = sheetName
 		preview.Rows = append(preview.Rows, importRow)
+	}
+}
+
+// finishRecordsPreview flags duplicate records, counts the rows and stores
+// a records preview
+func (s *ImportService) finishRecordsPreview(preview *models.ImportPreview) (*models.ImportPreview, error) {
+	if err := s.refreshRecordsPreview(preview); err != nil {
+		return nil, err
+	}
+
+	// Store preview
+	if err := s.store.Set(preview.ID, preview); err != nil {
+		return nil, fmt.Errorf("無法儲存預覽資料: %w", err)
+	}
+
+	return preview, nil
+}
+
+// refreshRecordsPreview flags the values that duplicate a stored record or
+// an earlier row and recounts the rows of a records preview
+func (s *ImportService) refreshRecordsPreview(preview *models.ImportPreview) error {
+	existing, err := loadExistingRecords(s.db, preview.Rows)
+	if err != nil {
+		return err
+	}
+	markRecordConflicts(preview, existing)
+	recountRecordsPreview(preview)
+	return nil
+}
+
+// recountRecordsPreview recounts the rows of a records preview by status,
+// in total and per sheet
+func recountRecordsPreview(preview *models.ImportPreview) {
+	preview.TotalRows = len(preview.Rows)
+	preview.ValidRows, preview.WarningRows, preview.ErrorRows, preview.ConflictRows = 0, 0, 0, 0
+
+	sheets := make(map[string]*models.ImportSheetSummary, len(preview.Sheets))
+	for i := range preview.Sheets {
+		sheet := &preview.Sheets[i]
+		sheet.TotalRows, sheet.ValidRows, sheet.WarningRows, sheet.ErrorRows, sheet.ConflictRows = 0, 0, 0, 0, 0
+		sheets[sheet.Name] = sheet
+	}
+
+	for _, row := range preview.Rows {
+		sheet := sheets[row.Sheet]
+		if sheet == nil {
+			sheet = &models.ImportSheetSummary{}
+		}
+		sheet.TotalRows++
 
-		switch importRow.Status {
+		switch row.Status {
 		case models.RowStatusValid:
 			preview.ValidRows++
+			sheet.ValidRows++
 		case models.RowStatusWarning:
 			preview.WarningRows++
+			sheet.WarningRows++
 		case models.RowStatusError:
 			preview.ErrorRows++
+			sheet.ErrorRows++
+		}
+		if len(row.Conflicts) > 0 {
+			preview.ConflictRows++
+			sheet.ConflictRows++
 		}
 	}
+}
 
-	preview.TotalRows = len(preview.Rows)
+// rowStatusFromErrors derives the status of a row from its remaining errors
+func rowStatusFromErrors(errors []models.RowError) models.RowStatus {
+	status := models.RowStatusValid
+	for _, rowErr := range errors {
+		if rowErr.Level == "error" {
+			return models.RowStatusError
+		}
+		status = models.RowStatusWarning
+	}
+	return status
+}
 
-	// Store preview
-	s.store.Set(preview.ID, preview)
+// recordRowValues returns the student, test date and parsed sport values of
+// a validated records row
+func recordRowValues(row models.ImportRow) (uint, time.Time, map[string]float64, bool) {
+	studentID, ok := row.Data["student_id"].(uint)
+	if !ok {
+		return 0, time.Time{}, nil, false
+	}
+	testDate, ok := row.Data["test_date_parsed"].(time.Time)
+	if !ok {
+		return 0, time.Time{}, nil, false
+	}
+	sportValues, ok := row.Data["sport_values"].(map[string]float64)
+	if !ok {
+		return 0, time.Time{}, nil, false
+	}
+	return studentID, testDate, sportValues, true
+}
 
-	return preview, nil
+// sortedSportNames returns the sports of a row in sport type order
+func sortedSportNames(sportValues map[string]float64) []string {
+	names := make([]string, 0, len(sportValues))
+	for sportName := range sportValues {
+		if _, exists := models.SportTypeMapping[sportName]; exists {
+			names = append(names, sportName)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return models.SportTypeMapping[names[i]] < models.SportTypeMapping[names[j]]
+	})
+	return names
+}
+
+// recordKey identifies the records of a student for a sport on a test date
+func recordKey(studentID, sportTypeID uint, testDate time.Time) string {
+	return fmt.Sprintf("%d|%d|%s", studentID, sportTypeID, testDate.Format("2006-01-02"))
 }
 
-// validateRecordsHeaders checks if the header row matches expected format
-func (s *ImportService) validateRecordsHeaders(headers []string) error {
-	expected := models.RecordsTemplateHeaders
-	if len(headers) < len(expected) {
-		return fmt.Errorf("Excel 標題列格式不正確，請使用系統提供的模板")
+// loadExistingRecords returns the earliest stored record for every student,
+// sport and test date that appears in the rows
+func loadExistingRecords(db *gorm.DB, rows []models.ImportRow) (map[string]*models.SportRecord, error) {
+	existing := make(map[string]*models.SportRecord)
+
+	studentIDs := make([]uint, 0)
+	dates := make([]string, 0)
+	seenStudents := make(map[uint]bool)
+	seenDates := make(map[string]bool)
+	for _, row := range rows {
+		studentID, testDate, _, ok := recordRowValues(row)
+		if !ok {
+			continue
+		}
+		if !seenStudents[studentID] {
+			seenStudents[studentID] = true
+			studentIDs = append(studentIDs, studentID)
+		}
+		date := testDate.Format("2006-01-02")
+		if !seenDates[date] {
+			seenDates[date] = true
+			dates = append(dates, date)
+		}
 	}
 
-	// Check first two required columns (座號, 姓名)
-	for i := 0; i < 2; i++ {
-		if !strings.Contains(headers[i], strings.TrimSuffix(expected[i], "*")) {
-			return fmt.Errorf("Excel 標題列格式不正確，請使用系統提供的模板")
+	for start := 0; start < len(studentIDs); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(studentIDs) {
+			end = len(studentIDs)
+		}
+
+		var records []models.SportRecord
+		err := db.Where("student_id IN ? AND test_date IN ?", studentIDs[start:end], dates).
+			Order("id ASC").
+			Find(&records).Error
+		if err != nil {
+			return nil, fmt.Errorf("無法載入既有運動記錄: %w", err)
+		}
+		for i := range records {
+			key := recordKey(records[i].StudentID, records[i].SportTypeID, records[i].TestDate)
+			if _, exists := existing[key]; !exists {
+				existing[key] = &records[i]
+			}
 		}
 	}
 
+	return existing, nil
+}
+
+// importableRow reports whether a row will be imported
+func importableRow(row models.ImportRow, includeWarnings bool) bool {
+	return row.Status == models.RowStatusValid || (row.Status == models.RowStatusWarning && includeWarnings)
+}
+
+// markRecordConflicts lists, on each row, the sport values that duplicate a
+// stored record or a value of an earlier row, and counts them in the preview.
+// Conflicts do not change the row status; the duplicate policy chosen at
+// execution decides what happens to them.
+func markRecordConflicts(preview *models.ImportPreview, existing map[string]*models.SportRecord) {
+	firstRows := make(map[string]*models.ImportRow)
+	preview.ConflictRecords = 0
+
+	for i := range preview.Rows {
+		row := &preview.Rows[i]
+		row.Conflicts = nil
+		if row.Status == models.RowStatusError {
+			continue
+		}
+		studentID, testDate, sportValues, ok := recordRowValues(*row)
+		if !ok {
+			continue
+		}
+
+		for _, sportName := range sortedSportNames(sportValues) {
+			key := recordKey(studentID, models.SportTypeMapping[sportName], testDate)
+			conflict := models.RecordConflict{
+				Sport:    sportName,
+				NewValue: sportValues[sportName],
+			}
+			if record, exists := existing[key]; exists {
+				conflict.ExistingRecordID = record.ID
+				conflict.ExistingValue = record.Value
+			}
+			if firstRow, exists := firstRows[key]; exists {
+				conflict.ConflictSheet = firstRow.Sheet
+				conflict.ConflictRow = firstRow.RowNumber
+			} else {
+				firstRows[key] = row
+			}
+
+			if conflict.ExistingRecordID != 0 || conflict.ConflictRow != 0 {
+				row.Conflicts = append(row.Conflicts, conflict)
+				preview.ConflictRecords++
+			}
+		}
+	}
+}
+
+// duplicateWinners picks, for every student, sport and test date, the row
+// whose value is imported when the file holds it more than once: the first
+// row when skipping duplicates, the last one when replacing
+func duplicateWinners(rows []models.ImportRow, req *models.ExecuteImportRequest) map[string]int {
+	winners := make(map[string]int)
+	for i, row := range rows {
+		if !importableRow(row, req.IncludeWarnings) {
+			continue
+		}
+		studentID, testDate, sportValues, ok := recordRowValues(row)
+		if !ok {
+			continue
+		}
+		for sportName := range sportValues {
+			sportTypeID, exists := models.SportTypeMapping[sportName]
+			if !exists {
+				continue
+			}
+			key := recordKey(studentID, sportTypeID, testDate)
+			if _, exists := winners[key]; !exists || req.DuplicatePolicy == models.DuplicatePolicyReplace {
+				winners[key] = i
+			}
+		}
+	}
+	return winners
+}
+
+// normalizeDuplicatePolicy checks the duplicate policy of a records import,
+// defaulting to skip
+func normalizeDuplicatePolicy(req *models.ExecuteImportRequest) error {
+	if req.DuplicatePolicy == "" {
+		req.DuplicatePolicy = models.DuplicatePolicySkip
+		return nil
+	}
+	if !models.IsValidDuplicatePolicy(req.DuplicatePolicy) {
+		return fmt.Errorf("無效的重複記錄處理方式")
+	}
 	return nil
 }
 
 // validateRecordRow validates a single sport record row
-func (s *ImportService) validateRecordRow(rowNum int, row []string, studentMap map[string]*models.Student) models.ImportRow {
+func (s *ImportService) validateRecordRow(rowNum int, row []string, ctx *recordsImportContext) models.ImportRow {
 	importRow := models.ImportRow{
 		RowNumber: rowNum,
 		Status:    models.RowStatusValid,
@@ -547,14 +1465,43 @@ func (s *ImportService) validateRecordRow(rowNum int, row []string, studentMap m
 
 	// Validate student exists
 	if !IsEmpty(studentNumber) && !IsEmpty(name) {
-		key := fmt.Sprintf("%s|%s", studentNumber, name)
-		if student, exists := studentMap[key]; exists {
+		if student, exists := ctx.studentMap[studentMatchKey(studentNumber, name)]; exists {
+			importRow.Data["student_id"] = student.ID
+		} else if student := ctx.findFormerHolder(studentNumber, name); student != nil {
+			// Matched through a former number: accept but ask for confirmation
 			importRow.Data["student_id"] = student.ID
+			importRow.Errors = append(importRow.Errors, models.RowError{
+				Field:   "student_number",
+				Code:    models.ErrorCodeFormerNumber,
+				Message: fmt.Sprintf("座號 %s 為 %s 的舊座號（現為座號 %s），已比對至該生", studentNumber, student.Name, student.StudentNumber),
+				Level:   "warning",
+			})
+			if current, exists := ctx.currentNumbers[normalizeStudentNumber(studentNumber)]; exists {
+				importRow.Errors = append(importRow.Errors, models.RowError{
+					Field:   "student_number",
+					Code:    models.ErrorCodeNumberReused,
+					Message: fmt.Sprintf("座號 %s 目前為 %s 使用，請確認此列屬於 %s", studentNumber, current.Name, student.Name),
+					Level:   "warning",
+				})
+			}
+			if importRow.Status == models.RowStatusValid {
+				importRow.Status = models.RowStatusWarning
+			}
 		} else {
+			message := fmt.Sprintf("找不到座號 %s 姓名 %s 的學生", studentNumber, name)
+			code := models.ErrorCodeNotFound
+			if holders := ctx.holdersOf(studentNumber); len(holders) > 0 {
+				message += fmt.Sprintf("（此座號為 %s 使用）", holderNames(holders))
+				code = models.ErrorCodeNumberReused
+			}
+			importRow.Suggestions = ctx.suggestStudents(studentNumber, name)
+			if len(importRow.Suggestions) > 0 {
+				message += "，可從建議名單中選擇學生"
+			}
 			importRow.Errors = append(importRow.Errors, models.RowError{
 				Field:   "student",
-				Code:    models.ErrorCodeNotFound,
-				Message: fmt.Sprintf("找不到座號 %s 姓名 %s 的學生", studentNumber, name),
+				Code:    code,
+				Message: message,
 				Level:   "error",
 			})
 			importRow.Status = models.RowStatusError
@@ -652,26 +1599,75 @@ func (s *ImportService) validateRecordRow(rowNum int, row []string, studentMap m
 	return importRow
 }
 
-// ExecuteRecordsImport creates sport records from a validated preview
-func (s *ImportService) ExecuteRecordsImport(previewID string, includeWarnings bool) (*models.ImportResult, error) {
-	// Get preview
-	preview := s.store.Get(previewID)
-	if preview == nil {
-		return nil, fmt.Errorf("預覽資料不存在或已過期，請重新上傳檔案")
+// findFormerHolder returns the student who formerly used the number and has the
+// given name, or nil when there is none
+func (c *recordsImportContext) findFormerHolder(studentNumber, name string) *models.Student {
+	for _, student := range c.formerNumbers[normalizeStudentNumber(studentNumber)] {
+		if FoldName(student.Name) == FoldName(name) {
+			return student
+		}
 	}
+	return nil
+}
 
-	// Check if already executed
-	if s.store.IsExecuted(previewID) {
-		return nil, fmt.Errorf("此預覽已被執行，請重新上傳檔案")
+// holdersOf returns every student currently or formerly using the number
+func (c *recordsImportContext) holdersOf(studentNumber string) []*models.Student {
+	holders := make([]*models.Student, 0)
+	number := normalizeStudentNumber(studentNumber)
+	if current, exists := c.currentNumbers[number]; exists {
+		holders = append(holders, current)
 	}
+	return append(holders, c.formerNumbers[number]...)
+}
 
-	// Verify it's a records import
-	if preview.Type != models.ImportTypeRecords {
-		return nil, fmt.Errorf("預覽類型不正確")
+// importRowSavepoint is the savepoint each row is written under in
+// partial-commit mode; reusing the name replaces the previous row's savepoint
+const importRowSavepoint = "import_row"
+
+// recordReplacement is a stored record an import row overwrites
+type recordReplacement struct {
+	record *models.SportRecord
+	value  float64
+}
+
+// writeRecordsRow writes the new records and replacements of one row
+func writeRecordsRow(tx *gorm.DB, records []models.SportRecord, replacements []recordReplacement, notes string, changedBy uint) error {
+	for _, replacement := range replacements {
+		if err := ReplaceRecordValue(tx, replacement.record, replacement.value, notes, changedBy, "批次匯入取代"); err != nil {
+			return err
+		}
+	}
+	if len(records) > 0 {
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecuteRecordsImport creates sport records from a validated preview
+func (s *ImportService) ExecuteRecordsImport(req *models.ExecuteImportRequest, uploadedBy uint) (*models.ImportResult, error) {
+	if err := normalizeDuplicatePolicy(req); err != nil {
+		return nil, err
 	}
 
+	preview, err := s.claimPreview(req.PreviewID, models.ImportTypeRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.runRecordsImport(context.Background(), preview, req, uploadedBy, nil)
+}
+
+// runRecordsImport creates the sport records of a claimed preview in batches.
+// Values duplicating a stored record or another row are handled by the
+// request's duplicate policy. In partial-commit mode each row is written under
+// its own savepoint instead, and rows the database refuses are rolled back and
+// reported while the others are kept. The preview is released again when the
+// import fails or is cancelled.
+func (s *ImportService) runRecordsImport(ctx context.Context, preview *models.ImportPreview, req *models.ExecuteImportRequest, uploadedBy uint, progress func(models.ImportProgress)) (*models.ImportResult, error) {
 	result := &models.ImportResult{
-		PreviewID:    previewID,
+		PreviewID:    preview.ID,
 		Type:         models.ImportTypeRecords,
 		SuccessCount: 0,
 		SkipCount:    0,
@@ -681,77 +1677,149 @@ func (s *ImportService) ExecuteRecordsImport(previewID string, includeWarnings b
 
 	// Execute in transaction
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		for _, row := range preview.Rows {
-			// Skip error rows
-			if row.Status == models.RowStatusError {
-				result.SkipCount++
-				if len(row.Errors) > 0 {
-					result.Errors = append(result.Errors, models.ImportedError{
-						RowNumber: row.RowNumber,
-						Field:     row.Errors[0].Field,
-						Message:   row.Errors[0].Message,
-					})
-				}
-				continue
-			}
+		importBatch, err := createImportBatch(tx, preview, req, uploadedBy)
+		if err != nil {
+			return err
+		}
+		importBatch.DuplicatePolicy = req.DuplicatePolicy
+		importBatch.PartialCommit = req.PartialCommit
+		result.PartialCommit = req.PartialCommit
 
-			// Skip warning rows if not included
-			if row.Status == models.RowStatusWarning && !includeWarnings {
-				result.SkipCount++
-				if len(row.Errors) > 0 {
-					result.Errors = append(result.Errors, models.ImportedError{
-						RowNumber: row.RowNumber,
-						Field:     row.Errors[0].Field,
-						Message:   row.Errors[0].Message + " (警告被跳過)",
-					})
-				}
-				continue
+		// Look duplicates up again; records may have been added since the preview
+		existing, err := loadExistingRecords(tx, preview.Rows)
+		if err != nil {
+			return err
+		}
+		winners := duplicateWinners(preview.Rows, req)
+		notes := fmt.Sprintf("批次匯入 - %s", preview.FileName)
+
+		batch := make([]models.SportRecord, 0, importBatchSize)
+		firstRow := 0
+
+		flush := func(lastRow int) error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := tx.CreateInBatches(&batch, importBatchSize).Error; err != nil {
+				return fmt.Errorf("建立運動記錄失敗（第 %d 至 %d 列）: %w", firstRow, lastRow, err)
 			}
+			for _, record := range batch {
+				importBatch.CreatedRecordIDs = append(importBatch.CreatedRecordIDs, record.ID)
+			}
+			result.SuccessCount += len(batch)
+			batch = batch[:0]
+			return nil
+		}
 
-			// Get student ID and test date
-			studentID, ok := row.Data["student_id"].(uint)
-			if !ok {
-				continue
+		for i, row := range preview.Rows {
+			if i > 0 && i%importBatchSize == 0 {
+				if err := flush(preview.Rows[i-1].RowNumber); err != nil {
+					return err
+				}
+				reportImportProgress(progress, i, result)
+				if ctx.Err() != nil {
+					return errImportCancelled
+				}
 			}
 
-			testDate, ok := row.Data["test_date_parsed"].(time.Time)
-			if !ok {
+			if skipImportRow(row, req.IncludeWarnings, result) {
 				continue
 			}
 
-			// Get parsed sport values
-			sportValues, ok := row.Data["sport_values"].(map[string]float64)
+			// Get student ID, test date and parsed sport values
+			studentID, testDate, sportValues, ok := recordRowValues(row)
 			if !ok {
 				continue
 			}
 
-			// Create a sport record for each non-empty value
-			for sportName, value := range sportValues {
-				sportTypeID, exists := models.SportTypeMapping[sportName]
-				if !exists {
-					continue
+			// Split the values into new records, replacements and duplicates
+			records := make([]models.SportRecord, 0, len(sportValues))
+			replacements := make([]recordReplacement, 0)
+			duplicates := 0
+			for _, sportName := range sortedSportNames(sportValues) {
+				sportTypeID := models.SportTypeMapping[sportName]
+				value := sportValues[sportName]
+
+				if req.DuplicatePolicy != models.DuplicatePolicyKeepBoth {
+					key := recordKey(studentID, sportTypeID, testDate)
+					if winners[key] != i {
+						// Another row of the file holds the imported value
+						duplicates++
+						continue
+					}
+					if record, exists := existing[key]; exists {
+						if req.DuplicatePolicy == models.DuplicatePolicySkip {
+							duplicates++
+							continue
+						}
+						replacements = append(replacements, recordReplacement{record: record, value: value})
+						continue
+					}
 				}
 
-				record := models.SportRecord{
+				records = append(records, models.SportRecord{
 					StudentID:   studentID,
 					SportTypeID: sportTypeID,
 					Value:       value,
 					TestDate:    testDate,
-					Notes:       fmt.Sprintf("批次匯入 - %s", preview.FileName),
-				}
+					Notes:       notes,
+				})
+			}
 
-				if err := tx.Create(&record).Error; err != nil {
-					return fmt.Errorf("建立運動記錄失敗（第 %d 列）: %w", row.RowNumber, err)
+			if req.PartialCommit {
+				if err := tx.SavePoint(importRowSavepoint).Error; err != nil {
+					return fmt.Errorf("failed to create savepoint: %w", err)
+				}
+				if err := writeRecordsRow(tx, records, replacements, notes, uploadedBy); err != nil {
+					if rollbackErr := tx.RollbackTo(importRowSavepoint).Error; rollbackErr != nil {
+						return fmt.Errorf("failed to roll back row %d: %w", row.RowNumber, rollbackErr)
+					}
+					result.RejectedRows++
+					result.Errors = append(result.Errors, models.ImportedError{
+						Sheet:     row.Sheet,
+						RowNumber: row.RowNumber,
+						Message:   fmt.Sprintf("寫入資料庫失敗，此列未匯入: %v", err),
+					})
+					continue
+				}
+				for _, record := range records {
+					importBatch.CreatedRecordIDs = append(importBatch.CreatedRecordIDs, record.ID)
+				}
+				result.SuccessCount += len(records)
+				result.CommittedRows++
+			} else {
+				for _, replacement := range replacements {
+					if err := ReplaceRecordValue(tx, replacement.record, replacement.value, notes, uploadedBy, "批次匯入取代"); err != nil {
+						return fmt.Errorf("取代運動記錄失敗（第 %d 列）: %w", row.RowNumber, err)
+					}
+				}
+				if len(batch) == 0 {
+					firstRow = row.RowNumber
 				}
+				batch = append(batch, records...)
+			}
 
-				result.SuccessCount++
+			for _, replacement := range replacements {
+				importBatch.ReplacedRecordIDs = append(importBatch.ReplacedRecordIDs, replacement.record.ID)
 			}
+			result.ReplacedCount += len(replacements)
+			result.DuplicateCount += duplicates
 		}
 
-		return nil
+		if len(preview.Rows) > 0 {
+			if err := flush(preview.Rows[len(preview.Rows)-1].RowNumber); err != nil {
+				return err
+			}
+		}
+		reportImportProgress(progress, len(preview.Rows), result)
+
+		importBatch.RejectedRows = result.RejectedRows
+		return finishImportBatch(tx, importBatch, result)
 	})
 
 	if err != nil {
+		// Allow the preview to be executed again after a failed transaction
+		s.store.Release(preview.ID)
 		return nil, err
 	}
 
@@ -760,8 +1828,5 @@ func (s *ImportService) ExecuteRecordsImport(previewID string, includeWarnings b
 	s.db.Model(&models.School{}).Where("id = ?", preview.SchoolID).
 		Update("last_records_uploaded_at", now)
 
-	// Mark preview as executed
-	s.store.MarkExecuted(previewID)
-
 	return result, nil
 }
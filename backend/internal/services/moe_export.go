@@ -0,0 +1,387 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// moeSchoolCodePattern matches the six character school codes of the ministry
+	moeSchoolCodePattern = regexp.MustCompile(`^[0-9A-Z]{6}$`)
+	// moeStudentNumberPattern matches the student numbers the platform accepts
+	moeStudentNumberPattern = regexp.MustCompile(`^[0-9A-Z]{1,10}$`)
+)
+
+// moeUnitFactors convert our units to the units of the upload layout
+var moeUnitFactors = map[[2]string]float64{
+	{"公尺", "公分"}: 100,
+	{"分", "秒"}:   60,
+	{"公克", "公斤"}: 0.001,
+}
+
+// MOEValidationError is returned when the upload file cannot be exported
+// because rows are missing mandatory data
+type MOEValidationError struct {
+	Validation *models.MOEValidation
+}
+
+func (e *MOEValidationError) Error() string {
+	if len(e.Validation.Errors) > 0 {
+		return e.Validation.Errors[0].Message
+	}
+	return fmt.Sprintf("有 %d 筆資料不符合教育部上傳格式", e.Validation.ErrorRows)
+}
+
+// moeBatch is a school's upload file with the result of its checks
+type moeBatch struct {
+	validation *models.MOEValidation
+	format     string
+	rows       [][]interface{}
+}
+
+// moeSportType is a sport type recorded as an item of the upload layout
+type moeSportType struct {
+	item int // index in models.MOEItems
+	name string
+	unit string
+}
+
+// ValidateMOE checks a school's students and records of a term against the
+// MOE upload layout and lists the rows that would be rejected
+func (s *ExportService) ValidateMOE(params *models.MOEExportParams) (*models.MOEValidation, error) {
+	batch, err := s.prepareMOE(params)
+	if err != nil {
+		return nil, err
+	}
+	return batch.validation, nil
+}
+
+// ExportMOE exports a school's results of a term in the MOE upload layout.
+// It returns a *MOEValidationError listing the offending rows when any row
+// misses mandatory data.
+func (s *ExportService) ExportMOE(params *models.MOEExportParams) (*Export, error) {
+	batch, err := s.prepareMOE(params)
+	if err != nil {
+		return nil, err
+	}
+	if !batch.validation.ReadyForDownload {
+		return nil, &MOEValidationError{Validation: batch.validation}
+	}
+
+	headers := append([]string(nil), models.MOEIdentityColumns...)
+	for _, item := range models.MOEItems {
+		headers = append(headers, item.Column)
+	}
+	headers = append(headers, models.MOECardioColumn)
+
+	export := &Export{
+		Filename: fmt.Sprintf("moe-fitness-%s-%s.%s",
+			batch.validation.SchoolCode, batch.validation.Term, batch.format),
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		format:      batch.format,
+		write: func(out tableWriter) error {
+			if err := out.StartSheet("體適能成績", headers); err != nil {
+				return err
+			}
+			for _, row := range batch.rows {
+				if err := out.WriteRow(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	if batch.format == models.ExportFormatCSV {
+		export.ContentType = "text/csv; charset=utf-8"
+	}
+	return export, nil
+}
+
+// prepareMOE builds the upload rows of a school and term and checks them
+func (s *ExportService) prepareMOE(params *models.MOEExportParams) (*moeBatch, error) {
+	format := strings.ToLower(strings.TrimSpace(params.Format))
+	if format == "" {
+		format = models.ExportFormatXLSX
+	}
+	if format != models.ExportFormatXLSX && format != models.ExportFormatCSV {
+		return nil, fmt.Errorf("不支援的匯出格式: %s（可用 xlsx 或 csv）", params.Format)
+	}
+
+	term := strings.TrimSpace(params.Term)
+	if term == "" {
+		term = AcademicTerm(time.Now())
+	}
+	from, until, err := ParseAcademicTerm(term)
+	if err != nil {
+		return nil, err
+	}
+
+	var school models.School
+	if err := s.db.First(&school, params.SchoolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("學校不存在")
+		}
+		return nil, fmt.Errorf("查詢學校失敗: %w", err)
+	}
+
+	validation := &models.MOEValidation{
+		SchoolID: school.ID,
+		Term:     term,
+		Errors:   []models.RowError{},
+		Issues:   []models.MOEIssue{},
+	}
+	if school.SchoolCode == nil || strings.TrimSpace(*school.SchoolCode) == "" {
+		validation.Errors = append(validation.Errors, models.RowError{
+			Field:   "school_code",
+			Code:    models.ErrorCodeRequired,
+			Message: "學校未設定學校代碼",
+			Level:   "error",
+		})
+	} else {
+		validation.SchoolCode = strings.ToUpper(strings.TrimSpace(*school.SchoolCode))
+		if !moeSchoolCodePattern.MatchString(validation.SchoolCode) {
+			validation.Errors = append(validation.Errors, models.RowError{
+				Field:   "school_code",
+				Code:    models.ErrorCodeInvalidFormat,
+				Message: fmt.Sprintf("學校代碼 %s 不是 6 碼的教育部學校代碼", validation.SchoolCode),
+				Level:   "error",
+			})
+		}
+	}
+
+	sportTypes, err := s.moeSportTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var students []models.Student
+	if err := s.db.Where("school_id = ?", school.ID).
+		Order("grade, class, student_number").
+		Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("查詢學生失敗: %w", err)
+	}
+
+	// Latest record of each student per item in the term
+	latest := make(map[uint]map[int]models.SportRecord)
+	if len(sportTypes) > 0 {
+		sportTypeIDs := make([]uint, 0, len(sportTypes))
+		for id := range sportTypes {
+			sportTypeIDs = append(sportTypeIDs, id)
+		}
+		var records []models.SportRecord
+		err := s.db.Joins("JOIN students ON students.id = sport_records.student_id").
+			Where("students.school_id = ? AND students.deleted_at IS NULL", school.ID).
+			Where("sport_records.sport_type_id IN ? AND sport_records.test_date >= ? AND sport_records.test_date < ?",
+				sportTypeIDs, from.Format("2006-01-02"), until.Format("2006-01-02")).
+			Order("sport_records.test_date, sport_records.id").
+			Find(&records).Error
+		if err != nil {
+			return nil, fmt.Errorf("查詢運動紀錄失敗: %w", err)
+		}
+		for _, record := range records {
+			if latest[record.StudentID] == nil {
+				latest[record.StudentID] = make(map[int]models.SportRecord)
+			}
+			latest[record.StudentID][sportTypes[record.SportTypeID].item] = record
+		}
+	}
+
+	batch := &moeBatch{validation: validation, format: format}
+	for _, student := range students {
+		issue := models.MOEIssue{
+			StudentID:     student.ID,
+			StudentNumber: student.StudentNumber,
+			Name:          student.Name,
+			Grade:         student.Grade,
+			Class:         student.Class,
+		}
+
+		records := latest[student.ID]
+		if len(records) == 0 {
+			validation.SkippedStudents++
+			issue.Errors = []models.RowError{{
+				Field:   "records",
+				Code:    models.ErrorCodeNoData,
+				Message: "本學期沒有檢測成績，不會列入上傳檔",
+				Level:   "warning",
+			}}
+			validation.Issues = append(validation.Issues, issue)
+			continue
+		}
+
+		row, rowErrors := moeRow(validation.SchoolCode, student, records, sportTypes)
+		batch.rows = append(batch.rows, row)
+		validation.TotalRows++
+		if len(rowErrors) == 0 {
+			continue
+		}
+
+		issue.Row = validation.TotalRows + 1 // below the header line
+		issue.Errors = rowErrors
+		validation.Issues = append(validation.Issues, issue)
+		if rowStatusFromErrors(rowErrors) == models.RowStatusError {
+			validation.ErrorRows++
+		} else {
+			validation.WarningRows++
+		}
+	}
+
+	if validation.TotalRows == 0 {
+		validation.Errors = append(validation.Errors, models.RowError{
+			Field:   "records",
+			Code:    models.ErrorCodeNoData,
+			Message: fmt.Sprintf("%s 學期沒有任何檢測成績", term),
+			Level:   "error",
+		})
+	}
+	validation.ReadyForDownload = len(validation.Errors) == 0 && validation.ErrorRows == 0
+
+	return batch, nil
+}
+
+// moeSportTypes returns the sport types recorded as upload items, by ID
+func (s *ExportService) moeSportTypes() (map[uint]moeSportType, error) {
+	var names []string
+	for _, item := range models.MOEItems {
+		names = append(names, item.SportTypes...)
+	}
+
+	var sportTypes []models.SportType
+	if err := s.db.Where("name IN ?", names).Find(&sportTypes).Error; err != nil {
+		return nil, fmt.Errorf("查詢運動項目失敗: %w", err)
+	}
+
+	byID := make(map[uint]moeSportType, len(sportTypes))
+	for _, sportType := range sportTypes {
+		for i, item := range models.MOEItems {
+			for _, name := range item.SportTypes {
+				if name == sportType.Name {
+					byID[sportType.ID] = moeSportType{item: i, name: name, unit: sportType.DefaultUnit}
+				}
+			}
+		}
+	}
+	return byID, nil
+}
+
+// moeRow converts a student and their latest item records into an upload
+// row and lists what the platform would reject
+func moeRow(schoolCode string, student models.Student, records map[int]models.SportRecord, sportTypes map[uint]moeSportType) ([]interface{}, []models.RowError) {
+	var rowErrors []models.RowError
+	addError := func(field, code, level, message string) {
+		rowErrors = append(rowErrors, models.RowError{Field: field, Code: code, Message: message, Level: level})
+	}
+
+	studentNumber := strings.ToUpper(strings.TrimSpace(student.StudentNumber))
+	if studentNumber == "" {
+		addError("student_number", models.ErrorCodeRequired, "error", "缺少學號")
+	} else if !moeStudentNumberPattern.MatchString(studentNumber) {
+		addError("student_number", models.ErrorCodeInvalidFormat, "error",
+			fmt.Sprintf("學號 %s 只能包含英文字母與數字，最多 10 碼", student.StudentNumber))
+	}
+
+	name := strings.TrimSpace(student.Name)
+	if name == "" {
+		addError("name", models.ErrorCodeRequired, "error", "缺少姓名")
+	}
+
+	var gender string
+	switch student.Gender {
+	case "male":
+		gender = models.MOEGenderMale
+	case "female":
+		gender = models.MOEGenderFemale
+	default:
+		addError("gender", models.ErrorCodeInvalidValue, "error", "性別必須為男或女")
+	}
+
+	var class string
+	if normalized := NormalizeClassName(student.Class); normalized == "" {
+		addError("class", models.ErrorCodeRequired, "error", "缺少班級")
+	} else if n, err := strconv.Atoi(normalized); err != nil || n < 1 || n > 99 {
+		addError("class", models.ErrorCodeInvalidFormat, "error",
+			fmt.Sprintf("班級 %s 必須是班級號碼", student.Class))
+	} else {
+		class = fmt.Sprintf("%02d", n)
+	}
+
+	var birthDate string
+	if student.BirthDate == nil {
+		addError("birth_date", models.ErrorCodeRequired, "error", "缺少出生日期")
+	} else {
+		birthDate = rocDate(*student.BirthDate)
+	}
+
+	// Optional items are only reported missing when we can record them
+	recorded := make(map[int]bool)
+	for _, sportType := range sportTypes {
+		recorded[sportType.item] = true
+	}
+
+	// Item values, converted to the layout's units
+	values := make([]interface{}, len(models.MOEItems))
+	var testDate time.Time
+	var cardioCode string
+	for i, item := range models.MOEItems {
+		record, ok := records[i]
+		if !ok {
+			if item.Required {
+				addError(item.Column, models.ErrorCodeRequired, "error", fmt.Sprintf("缺少%s成績", item.Column))
+			} else if recorded[i] {
+				addError(item.Column, models.ErrorCodeNoData, "warning", fmt.Sprintf("缺少%s資料", item.Column))
+			}
+			continue
+		}
+		if record.TestDate.After(testDate) {
+			testDate = record.TestDate
+		}
+
+		sportType := sportTypes[record.SportTypeID]
+		value, err := moeValue(record.Value, sportType.unit, item)
+		if err != nil {
+			addError(item.Column, models.ErrorCodeInvalidValue, "error", err.Error())
+			continue
+		}
+		values[i] = value
+		if code, ok := models.MOECardioCodes[sportType.name]; ok {
+			cardioCode = code
+		}
+
+		if valueRange, ok := models.SportValueRanges[item.Column]; ok && (value < valueRange.Min || value > valueRange.Max) {
+			addError(item.Column, models.ErrorCodeOutOfRange, "warning",
+				fmt.Sprintf("%s %s%s 超出合理範圍，請確認", item.Column, strconv.FormatFloat(value, 'f', -1, 64), item.Unit))
+		}
+	}
+
+	row := []interface{}{schoolCode, student.Grade, class, studentNumber, name, gender, birthDate, rocDate(testDate)}
+	row = append(row, values...)
+	row = append(row, cardioCode)
+	return row, rowErrors
+}
+
+// moeValue converts a result to the unit of an upload item and rounds it to
+// the item's decimals
+func moeValue(value float64, unit string, item models.MOEItem) (float64, error) {
+	if unit != item.Unit {
+		factor, ok := moeUnitFactors[[2]string{unit, item.Unit}]
+		if !ok {
+			return 0, fmt.Errorf("%s的單位 %s 無法換算為%s", item.Column, unit, item.Unit)
+		}
+		value *= factor
+	}
+	scale := math.Pow(10, float64(item.Decimals))
+	return math.Round(value*scale) / scale, nil
+}
+
+// rocDate formats a date as the 7 digit ROC date (YYYMMDD) of the platform
+func rocDate(t time.Time) string {
+	return fmt.Sprintf("%03d%02d%02d", t.Year()-1911, int(t.Month()), t.Day())
+}
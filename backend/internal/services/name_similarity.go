@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// NormalizeName removes all whitespace (including full-width spaces) from a name
+func NormalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// nameVariants folds variant and simplified characters common in names to
+// the form used in our records, so "台"/"臺" and "陈"/"陳" compare equal
+var nameVariants = map[rune]rune{
+	'臺': '台', '峯': '峰', '裏': '裡', '爲': '為', '眞': '真', '啓': '啟',
+	'淸': '清', '靑': '青', '恒': '恆', '晋': '晉', '温': '溫', '栢': '柏',
+	'姸': '妍', '廸': '迪', '衆': '眾', '敎': '教', '却': '卻', '户': '戶',
+	'陈': '陳', '张': '張', '刘': '劉', '杨': '楊', '黄': '黃', '赵': '趙',
+	'吴': '吳', '孙': '孫', '马': '馬', '罗': '羅', '郑': '鄭', '谢': '謝',
+	'许': '許', '韩': '韓', '冯': '馮', '邓': '鄧', '萧': '蕭', '蒋': '蔣',
+	'叶': '葉', '苏': '蘇', '吕': '呂', '卢': '盧', '钟': '鍾', '谭': '譚',
+	'陆': '陸', '贾': '賈', '韦': '韋', '邹': '鄒', '龙': '龍', '贺': '賀',
+	'顾': '顧', '龚': '龔', '万': '萬', '钱': '錢', '严': '嚴', '汤': '湯',
+	'庄': '莊', '纪': '紀', '伟': '偉', '华': '華', '国': '國', '丽': '麗',
+	'杰': '傑', '涛': '濤', '军': '軍', '静': '靜', '强': '強', '红': '紅',
+	'鹏': '鵬', '辉': '輝', '飞': '飛', '宁': '寧', '瑶': '瑤', '颖': '穎',
+	'诗': '詩', '晓': '曉', '凯': '凱', '贤': '賢', '岚': '嵐', '云': '雲',
+	'凤': '鳳', '兰': '蘭', '荣': '榮', '东': '東', '庆': '慶', '义': '義',
+	'仪': '儀', '发': '發', '达': '達', '长': '長', '乐': '樂', '书': '書',
+	'宝': '寶', '贵': '貴', '铭': '銘', '鸿': '鴻', '爱': '愛', '远': '遠',
+	'刚': '剛', '聪': '聰', '颜': '顏', '婵': '嬋', '玮': '瑋', '琼': '瓊',
+}
+
+// FoldName normalizes a name for matching: full-width characters narrowed,
+// whitespace removed and variant or simplified characters folded
+func FoldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if folded, ok := nameVariants[r]; ok {
+			return folded
+		}
+		return r
+	}, NormalizeName(width.Narrow.String(name)))
+}
+
+// NameSimilarity returns a similarity score between 0 and 1 for two names,
+// based on the edit distance of their normalized forms
+func NameSimilarity(a, b string) float64 {
+	ra := []rune(NormalizeName(a))
+	rb := []rune(NormalizeName(b))
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLen)
+}
+
+// levenshtein computes the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
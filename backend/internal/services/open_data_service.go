@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Default licence of the open data, overridable with the OPEN_DATA_*
+// environment variables
+const (
+	defaultOpenDataPublisher  = "ICACP"
+	defaultOpenDataLicense    = "政府資料開放授權條款－第1版"
+	defaultOpenDataLicenseURL = "https://data.gov.tw/license"
+)
+
+// OpenDataService publishes school and county statistics as GeoJSON and
+// CSV files that GIS tools such as QGIS load directly
+type OpenDataService struct {
+	db         *gorm.DB
+	statistics *StatisticsService
+	counties   *CountyService
+}
+
+// NewOpenDataService creates a new OpenDataService
+func NewOpenDataService(db *gorm.DB, statistics *StatisticsService, counties *CountyService) *OpenDataService {
+	return &OpenDataService{
+		db:         db,
+		statistics: statistics,
+		counties:   counties,
+	}
+}
+
+// openDataSchool is one school with its aggregates
+type openDataSchool struct {
+	ID                    uint
+	SchoolCode            *string
+	Name                  string
+	CountyName            string
+	District              string
+	Level                 string
+	Latitude              *float64
+	Longitude             *float64
+	StudentCount          int
+	LastRecordsUploadedAt *time.Time
+
+	recordCount    int
+	sports         map[uint]openDataSport
+	championSports []string
+}
+
+// openDataSport holds a school's aggregates for one sport type
+type openDataSport struct {
+	avgValue     float64
+	studentCount int
+	recordCount  int
+}
+
+// openDataSportColumn is a sport type with data, in column order
+type openDataSportColumn struct {
+	id   uint
+	name string
+}
+
+// openDataMetadata returns the licence and publisher of a dataset
+func openDataMetadata(title, description string) *models.OpenDataMetadata {
+	metadata := &models.OpenDataMetadata{
+		Title:       title,
+		Description: description,
+		Publisher:   envOrDefault("OPEN_DATA_PUBLISHER", defaultOpenDataPublisher),
+		License:     envOrDefault("OPEN_DATA_LICENSE", defaultOpenDataLicense),
+		LicenseURL:  OpenDataLicenseURL(),
+		GeneratedAt: time.Now(),
+	}
+	metadata.Attribution = fmt.Sprintf("%s，依%s釋出（%s）", metadata.Publisher, metadata.License, metadata.LicenseURL)
+	return metadata
+}
+
+// OpenDataLicenseURL returns the address of the open data licence
+func OpenDataLicenseURL() string {
+	return envOrDefault("OPEN_DATA_LICENSE_URL", defaultOpenDataLicenseURL)
+}
+
+// envOrDefault returns the environment variable, or fallback when it is unset
+func envOrDefault(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Package describes the open data files, their fields and licence
+func (s *OpenDataService) Package() (*models.OpenDataPackage, error) {
+	sportColumns, err := s.loadSportColumns()
+	if err != nil {
+		return nil, err
+	}
+	schoolFields := s.schoolCSVHeaders(sportColumns)
+
+	metadata := openDataMetadata("各級學校體適能統計開放資料", "學校位置、學生人數、各運動項目平均成績與縣市統計")
+	return &models.OpenDataPackage{
+		OpenDataMetadata: *metadata,
+		Resources: []models.OpenDataResource{
+			{
+				Name: "schools-geojson", Path: "schools.geojson", Format: "geojson", MediaType: "application/geo+json",
+				Description: "有座標的學校點位，屬性含各運動項目平均成績、紀錄筆數與冠軍項目",
+			},
+			{
+				Name: "counties-geojson", Path: "counties.geojson", Format: "geojson", MediaType: "application/geo+json",
+				Description: "各縣市統計，點位為該縣市學校座標的平均位置，可用 county_code 與內政部縣市界圖層合併",
+			},
+			{
+				Name: "schools-csv", Path: "schools.csv", Format: "csv", MediaType: "text/csv",
+				Description: "所有學校的統計，座標欄位為 WGS84 經緯度", Fields: schoolFields,
+			},
+			{
+				Name: "counties-csv", Path: "counties.csv", Format: "csv", MediaType: "text/csv",
+				Description: "各縣市統計", Fields: countyCSVHeaders,
+			},
+		},
+	}, nil
+}
+
+// SchoolsGeoJSON returns the schools with coordinates as points with their
+// per-sport averages, record counts and champion flags
+func (s *OpenDataService) SchoolsGeoJSON(ctx context.Context) (*models.FeatureCollection, error) {
+	schools, sportColumns, err := s.loadSchools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &models.FeatureCollection{
+		Type:     "FeatureCollection",
+		Name:     "schools",
+		Metadata: openDataMetadata("學校體適能統計", "有座標的學校，屬性含各運動項目平均成績、紀錄筆數與冠軍項目"),
+		Features: make([]models.Feature, 0, len(schools)),
+	}
+	for _, school := range schools {
+		if school.Latitude == nil || school.Longitude == nil {
+			continue
+		}
+
+		properties := map[string]interface{}{
+			"id":                       school.ID,
+			"school_code":              school.SchoolCode,
+			"name":                     school.Name,
+			"county_name":              school.CountyName,
+			"district":                 school.District,
+			"level":                    school.Level,
+			"student_count":            school.StudentCount,
+			"record_count":             school.recordCount,
+			"last_records_uploaded_at": school.LastRecordsUploadedAt,
+			"is_champion":              len(school.championSports) > 0,
+			"champion_sports":          strings.Join(school.championSports, ";"),
+		}
+		// Flat properties so GIS tools show one attribute column per sport type
+		for _, column := range sportColumns {
+			sport, ok := school.sports[column.id]
+			if !ok {
+				properties["avg_"+column.name] = nil
+				properties["records_"+column.name] = 0
+				continue
+			}
+			properties["avg_"+column.name] = sport.avgValue
+			properties["records_"+column.name] = sport.recordCount
+		}
+
+		collection.Features = append(collection.Features, models.Feature{
+			Type:       "Feature",
+			ID:         school.ID,
+			Geometry:   models.NewPoint(*school.Latitude, *school.Longitude),
+			Properties: properties,
+		})
+	}
+
+	return collection, nil
+}
+
+// CountiesGeoJSON returns the statistics of every county. The point of a
+// county is the mean position of its schools, or null without located schools.
+func (s *OpenDataService) CountiesGeoJSON() (*models.FeatureCollection, error) {
+	counties, err := s.loadCounties()
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &models.FeatureCollection{
+		Type:     "FeatureCollection",
+		Name:     "counties",
+		Metadata: openDataMetadata("縣市體適能統計", "各縣市學校數、學生數與紀錄筆數；可用 county_code 與內政部縣市界圖層合併"),
+		Features: make([]models.Feature, 0, len(counties)),
+	}
+	for _, county := range counties {
+		var geometry *models.Geometry
+		if county.latitude != nil && county.longitude != nil {
+			geometry = models.NewPoint(*county.latitude, *county.longitude)
+		}
+		collection.Features = append(collection.Features, models.Feature{
+			Type:     "Feature",
+			ID:       county.code,
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"county_code":   county.code,
+				"county_name":   county.CountyName,
+				"school_count":  county.SchoolCount,
+				"student_count": county.StudentCount,
+				"record_count":  county.RecordCount,
+				"has_data":      county.HasData,
+			},
+		})
+	}
+
+	return collection, nil
+}
+
+// WriteSchoolsCSV writes every school with its aggregates as CSV
+func (s *OpenDataService) WriteSchoolsCSV(ctx context.Context, w io.Writer) error {
+	schools, sportColumns, err := s.loadSchools(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := newCSVTableWriter(w)
+	if err := out.StartSheet("schools", s.schoolCSVHeaders(sportColumns)); err != nil {
+		return err
+	}
+	for _, school := range schools {
+		row := []interface{}{
+			school.ID, optionalString(school.SchoolCode), school.Name, school.CountyName, school.District,
+			school.Level, optionalFloat(school.Latitude), optionalFloat(school.Longitude),
+			school.StudentCount, school.recordCount, strings.Join(school.championSports, ";"),
+		}
+		for _, column := range sportColumns {
+			if sport, ok := school.sports[column.id]; ok {
+				row = append(row, sport.avgValue, sport.studentCount)
+			} else {
+				row = append(row, nil, 0)
+			}
+		}
+		if err := out.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+var countyCSVHeaders = []string{"county_code", "county_name", "school_count", "student_count", "record_count", "latitude", "longitude"}
+
+// WriteCountiesCSV writes the statistics of every county as CSV
+func (s *OpenDataService) WriteCountiesCSV(w io.Writer) error {
+	counties, err := s.loadCounties()
+	if err != nil {
+		return err
+	}
+
+	out := newCSVTableWriter(w)
+	if err := out.StartSheet("counties", countyCSVHeaders); err != nil {
+		return err
+	}
+	for _, county := range counties {
+		if err := out.WriteRow([]interface{}{
+			county.code, county.CountyName, county.SchoolCount, county.StudentCount, county.RecordCount,
+			optionalFloat(county.latitude), optionalFloat(county.longitude),
+		}); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// schoolCSVHeaders returns the school CSV columns, with an average and a
+// student count column per sport type
+func (s *OpenDataService) schoolCSVHeaders(sportColumns []openDataSportColumn) []string {
+	headers := []string{"id", "school_code", "name", "county_name", "district", "level", "latitude", "longitude",
+		"student_count", "record_count", "champion_sports"}
+	for _, column := range sportColumns {
+		headers = append(headers, "avg_"+column.name, "students_"+column.name)
+	}
+	return headers
+}
+
+// loadSchools reads the schools with their student counts, per-sport
+// aggregates and champion sports, and the sport types that have records
+func (s *OpenDataService) loadSchools(ctx context.Context) ([]*openDataSchool, []openDataSportColumn, error) {
+	var schools []*openDataSchool
+	err := s.db.Table("schools").
+		Select(`schools.id, schools.school_code, schools.name, schools.county_name, schools.district, schools.level,
+			schools.latitude, schools.longitude, schools.last_records_uploaded_at,
+			(SELECT COUNT(*) FROM students WHERE students.school_id = schools.id AND students.deleted_at IS NULL) AS student_count`).
+		Where("schools.deleted_at IS NULL").
+		Order("schools.county_name, schools.name").
+		Scan(&schools).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("查詢學校失敗: %w", err)
+	}
+
+	byID := make(map[uint]*openDataSchool, len(schools))
+	for _, school := range schools {
+		school.sports = make(map[uint]openDataSport)
+		byID[school.ID] = school
+	}
+
+	var aggregates []struct {
+		SchoolID     uint
+		SportTypeID  uint
+		AvgValue     float64
+		StudentCount int
+		RecordCount  int
+	}
+	err = s.db.Table("sport_records sr").
+		Select(`s.school_id, sr.sport_type_id, AVG(sr.value) AS avg_value,
+			COUNT(DISTINCT sr.student_id) AS student_count, COUNT(*) AS record_count`).
+		Joins("JOIN students s ON s.id = sr.student_id AND s.deleted_at IS NULL").
+		Where("sr.deleted_at IS NULL").
+		Group("s.school_id, sr.sport_type_id").
+		Scan(&aggregates).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("查詢運動紀錄統計失敗: %w", err)
+	}
+
+	for _, aggregate := range aggregates {
+		school, ok := byID[aggregate.SchoolID]
+		if !ok {
+			continue
+		}
+		school.sports[aggregate.SportTypeID] = openDataSport{
+			avgValue:     math.Round(aggregate.AvgValue*100) / 100,
+			studentCount: aggregate.StudentCount,
+			recordCount:  aggregate.RecordCount,
+		}
+		school.recordCount += aggregate.RecordCount
+	}
+
+	sportColumns, err := s.loadSportColumns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	champions, err := s.statistics.GetSchoolChampions(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查詢冠軍學校失敗: %w", err)
+	}
+	for _, champion := range champions {
+		if school, ok := byID[champion.SchoolID]; ok {
+			school.championSports = append(school.championSports, champion.SportTypeName)
+		}
+	}
+
+	return schools, sportColumns, nil
+}
+
+// loadSportColumns returns the sport types that have records, in ID order
+func (s *OpenDataService) loadSportColumns() ([]openDataSportColumn, error) {
+	var sportTypes []models.SportType
+	err := s.db.Where("id IN (?)",
+		s.db.Table("sport_records").Distinct("sport_type_id").Where("deleted_at IS NULL")).
+		Order("id").Find(&sportTypes).Error
+	if err != nil {
+		return nil, fmt.Errorf("查詢運動項目失敗: %w", err)
+	}
+
+	columns := make([]openDataSportColumn, len(sportTypes))
+	for i, sportType := range sportTypes {
+		columns[i] = openDataSportColumn{id: sportType.ID, name: sportType.Name}
+	}
+	return columns, nil
+}
+
+// openDataCounty is one county's statistics with its code and mean position
+type openDataCounty struct {
+	models.CountyStatistics
+	code                string
+	latitude, longitude *float64
+}
+
+// loadCounties returns every county in the official order, including
+// counties without schools
+func (s *OpenDataService) loadCounties() ([]openDataCounty, error) {
+	stats, err := s.counties.GetAllCountyStatistics()
+	if err != nil {
+		return nil, fmt.Errorf("查詢縣市統計失敗: %w", err)
+	}
+	byName := make(map[string]models.CountyStatistics, len(stats.Counties))
+	for _, county := range stats.Counties {
+		byName[county.CountyName] = county
+	}
+
+	var positions []struct {
+		CountyName string
+		Latitude   float64
+		Longitude  float64
+	}
+	err = s.db.Table("schools").
+		Select("county_name, AVG(latitude) AS latitude, AVG(longitude) AS longitude").
+		Where("deleted_at IS NULL AND latitude IS NOT NULL AND longitude IS NOT NULL").
+		Group("county_name").
+		Scan(&positions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查詢縣市位置失敗: %w", err)
+	}
+
+	counties := make([]openDataCounty, 0, len(models.ValidTaiwanCounties))
+	for _, name := range models.ValidTaiwanCounties {
+		county := openDataCounty{CountyStatistics: byName[name], code: models.CountyCodes[name]}
+		county.CountyName = name
+		for _, position := range positions {
+			if position.CountyName == name {
+				latitude, longitude := math.Round(position.Latitude*1e6)/1e6, math.Round(position.Longitude*1e6)/1e6
+				county.latitude, county.longitude = &latitude, &longitude
+			}
+		}
+		counties = append(counties, county)
+	}
+	return counties, nil
+}
+
+// optionalString returns the string a pointer refers to, or nil
+func optionalString(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// optionalFloat returns the number a pointer refers to, or nil
+func optionalFloat(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
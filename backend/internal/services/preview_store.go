@@ -1,12 +1,46 @@
 package services
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/wei979/ICACP/backend/internal/models"
 )
 
+// PreviewStorage stores import previews between the preview and execute steps
+type PreviewStorage interface {
+	// Set stores a preview with automatic expiration
+	Set(id string, preview *models.ImportPreview) error
+	// Get retrieves a preview by ID, returns nil if not found or expired
+	Get(id string) *models.ImportPreview
+	// Save stores changes to an existing preview without extending its
+	// expiration. It fails once the preview has been claimed, so an edit
+	// cannot overwrite a preview that is being executed.
+	Save(id string, preview *models.ImportPreview) error
+	// Delete removes a preview by ID
+	Delete(id string) bool
+	// Claim atomically marks a preview as executed. It returns false if the
+	// preview does not exist, has expired or was already claimed, so a
+	// preview can only be executed once.
+	Claim(id string) bool
+	// Release undoes a claim after a failed execution so it can be retried
+	Release(id string)
+	// IsExecuted checks if a preview has already been claimed for execution
+	IsExecuted(id string) bool
+}
+
+// NewPreviewStorage returns a Redis backed storage when Redis is available,
+// so previews survive restarts and are shared between replicas, and falls
+// back to the in-memory store otherwise (e.g. in development)
+func NewPreviewStorage(redisClient *redis.Client, ttl time.Duration) PreviewStorage {
+	if redisClient != nil {
+		return NewRedisPreviewStore(redisClient, ttl)
+	}
+	return NewPreviewStore(ttl)
+}
+
 // PreviewStore manages in-memory storage of import previews with TTL
 type PreviewStore struct {
 	mu       sync.RWMutex
@@ -26,13 +60,14 @@ func NewPreviewStore(ttl time.Duration) *PreviewStore {
 }
 
 // Set stores a preview with automatic expiration
-func (s *PreviewStore) Set(id string, preview *models.ImportPreview) {
+func (s *PreviewStore) Set(id string, preview *models.ImportPreview) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	preview.CreatedAt = time.Now()
 	preview.ExpiresAt = time.Now().Add(s.ttl)
 	s.previews[id] = preview
+	return nil
 }
 
 // Get retrieves a preview by ID, returns nil if not found or expired
@@ -53,6 +88,26 @@ func (s *PreviewStore) Get(id string) *models.ImportPreview {
 	return preview
 }
 
+// Save stores changes to an existing preview, keeping its expiration
+func (s *PreviewStore) Save(id string, preview *models.ImportPreview) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.previews[id]
+	if !exists || time.Now().After(current.ExpiresAt) {
+		return fmt.Errorf("預覽資料不存在或已過期，請重新上傳檔案")
+	}
+	if current.Executed {
+		return fmt.Errorf("此預覽已被執行，無法修改")
+	}
+
+	preview.CreatedAt = current.CreatedAt
+	preview.ExpiresAt = current.ExpiresAt
+	preview.Executed = current.Executed
+	s.previews[id] = preview
+	return nil
+}
+
 // Delete removes a preview by ID
 func (s *PreviewStore) Delete(id string) bool {
 	s.mu.Lock()
@@ -65,13 +120,13 @@ func (s *PreviewStore) Delete(id string) bool {
 	return false
 }
 
-// MarkExecuted marks a preview as executed to prevent re-execution
-func (s *PreviewStore) MarkExecuted(id string) bool {
+// Claim atomically marks a preview as executed to prevent re-execution
+func (s *PreviewStore) Claim(id string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	preview, exists := s.previews[id]
-	if !exists || time.Now().After(preview.ExpiresAt) {
+	if !exists || time.Now().After(preview.ExpiresAt) || preview.Executed {
 		return false
 	}
 
@@ -79,6 +134,16 @@ func (s *PreviewStore) MarkExecuted(id string) bool {
 	return true
 }
 
+// Release clears the executed flag after a failed execution
+func (s *PreviewStore) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if preview, exists := s.previews[id]; exists {
+		preview.Executed = false
+	}
+}
+
 // IsExecuted checks if a preview has already been executed
 func (s *PreviewStore) IsExecuted(id string) bool {
 	s.mu.RLock()
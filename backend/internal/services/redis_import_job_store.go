@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/wei979/ICACP/backend/internal/models"
+)
+
+// RedisImportJobStore stores import jobs in Redis so their status, progress
+// and cancellation are shared between replicas
+type RedisImportJobStore struct {
+	client    *redis.Client
+	retention time.Duration
+	ctx       context.Context
+}
+
+// NewRedisImportJobStore creates a new RedisImportJobStore. A job expires
+// when it has not been updated for the retention period, which for a
+// finished job is the retention period after it finished.
+func NewRedisImportJobStore(client *redis.Client, retention time.Duration) *RedisImportJobStore {
+	return &RedisImportJobStore{
+		client:    client,
+		retention: retention,
+		ctx:       context.Background(),
+	}
+}
+
+func importJobKey(id string) string {
+	return "import:job:" + id
+}
+
+func importJobCancelKey(id string) string {
+	return "import:job:" + id + ":cancel"
+}
+
+// Set stores the current state of a job, renewing its expiration
+func (s *RedisImportJobStore) Set(job models.ImportJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("json encode error: %w", err)
+	}
+
+	if err := s.client.Set(s.ctx, importJobKey(job.ID), data, s.retention).Err(); err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a job by ID
+func (s *RedisImportJobStore) Get(id string) (models.ImportJob, bool) {
+	data, err := s.client.Get(s.ctx, importJobKey(id)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			fmt.Printf("Warning: Failed to load import job %s: %v\n", id, err)
+		}
+		return models.ImportJob{}, false
+	}
+
+	var job models.ImportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		fmt.Printf("Warning: Failed to decode import job %s: %v\n", id, err)
+		return models.ImportJob{}, false
+	}
+	return job, true
+}
+
+// RequestCancel flags a job for cancellation
+func (s *RedisImportJobStore) RequestCancel(id string) error {
+	if err := s.client.Set(s.ctx, importJobCancelKey(id), time.Now().Unix(), s.retention).Err(); err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+	return nil
+}
+
+// IsCancelRequested checks if cancellation of a job was requested
+func (s *RedisImportJobStore) IsCancelRequested(id string) bool {
+	exists, err := s.client.Exists(s.ctx, importJobCancelKey(id)).Result()
+	return err == nil && exists > 0
+}
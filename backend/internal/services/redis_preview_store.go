@@ -0,0 +1,159 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/wei979/ICACP/backend/internal/models"
+)
+
+func init() {
+	// ImportRow.Data holds typed values (parsed dates, sport values) behind
+	// interface{}; gob needs the concrete types registered to round-trip them
+	gob.Register(time.Time{})
+	gob.Register(map[string]float64{})
+}
+
+// RedisPreviewStore stores import previews in Redis so they survive backend
+// restarts and are shared between replicas
+type RedisPreviewStore struct {
+	client *redis.Client
+	ttl    time.Duration
+	ctx    context.Context
+}
+
+// NewRedisPreviewStore creates a new RedisPreviewStore with the specified TTL
+func NewRedisPreviewStore(client *redis.Client, ttl time.Duration) *RedisPreviewStore {
+	return &RedisPreviewStore{
+		client: client,
+		ttl:    ttl,
+		ctx:    context.Background(),
+	}
+}
+
+func previewKey(id string) string {
+	return "import:preview:" + id
+}
+
+func previewClaimKey(id string) string {
+	return "import:preview:" + id + ":claimed"
+}
+
+// Set stores a preview with automatic expiration
+func (s *RedisPreviewStore) Set(id string, preview *models.ImportPreview) error {
+	preview.CreatedAt = time.Now()
+	preview.ExpiresAt = time.Now().Add(s.ttl)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(preview); err != nil {
+		return fmt.Errorf("gob encode error: %w", err)
+	}
+
+	if err := s.client.Set(s.ctx, previewKey(id), buf.Bytes(), s.ttl).Err(); err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a preview by ID, returns nil if not found or expired
+func (s *RedisPreviewStore) Get(id string) *models.ImportPreview {
+	data, err := s.client.Get(s.ctx, previewKey(id)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			fmt.Printf("Warning: Failed to load import preview %s: %v\n", id, err)
+		}
+		return nil
+	}
+
+	var preview models.ImportPreview
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&preview); err != nil {
+		fmt.Printf("Warning: Failed to decode import preview %s: %v\n", id, err)
+		return nil
+	}
+
+	preview.Executed = s.IsExecuted(id)
+	return &preview
+}
+
+// Save stores changes to an existing preview for the rest of its TTL. The
+// claim key is watched, so a claim made while saving aborts the save.
+func (s *RedisPreviewStore) Save(id string, preview *models.ImportPreview) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(preview); err != nil {
+		return fmt.Errorf("gob encode error: %w", err)
+	}
+
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		claimed, err := tx.Exists(s.ctx, previewClaimKey(id)).Result()
+		if err != nil {
+			return fmt.Errorf("redis exists error: %w", err)
+		}
+		if claimed > 0 {
+			return fmt.Errorf("此預覽已被執行，無法修改")
+		}
+
+		remaining, err := tx.PTTL(s.ctx, previewKey(id)).Result()
+		if err != nil {
+			return fmt.Errorf("redis ttl error: %w", err)
+		}
+		if remaining <= 0 {
+			return fmt.Errorf("預覽資料不存在或已過期，請重新上傳檔案")
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(s.ctx, previewKey(id), buf.Bytes(), remaining)
+			return nil
+		})
+		return err
+	}, previewClaimKey(id))
+	if err == redis.TxFailedErr {
+		return fmt.Errorf("此預覽已被執行，無法修改")
+	}
+	if err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a preview by ID
+func (s *RedisPreviewStore) Delete(id string) bool {
+	deleted, err := s.client.Del(s.ctx, previewKey(id), previewClaimKey(id)).Result()
+	if err != nil {
+		fmt.Printf("Warning: Failed to delete import preview %s: %v\n", id, err)
+		return false
+	}
+	return deleted > 0
+}
+
+// Claim atomically marks a preview as executed using SETNX, so concurrent
+// execute requests (double clicks, several replicas) cannot both succeed
+func (s *RedisPreviewStore) Claim(id string) bool {
+	remaining, err := s.client.PTTL(s.ctx, previewKey(id)).Result()
+	if err != nil || remaining <= 0 {
+		return false
+	}
+
+	claimed, err := s.client.SetNX(s.ctx, previewClaimKey(id), time.Now().Unix(), remaining).Result()
+	if err != nil {
+		fmt.Printf("Warning: Failed to claim import preview %s: %v\n", id, err)
+		return false
+	}
+	return claimed
+}
+
+// Release removes the claim after a failed execution
+func (s *RedisPreviewStore) Release(id string) {
+	if err := s.client.Del(s.ctx, previewClaimKey(id)).Err(); err != nil {
+		fmt.Printf("Warning: Failed to release import preview %s: %v\n", id, err)
+	}
+}
+
+// IsExecuted checks if a preview has already been claimed for execution
+func (s *RedisPreviewStore) IsExecuted(id string) bool {
+	exists, err := s.client.Exists(s.ctx, previewClaimKey(id)).Result()
+	return err == nil && exists > 0
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Report card page layout, in millimetres on A4 portrait
+const (
+	reportMargin      = 15.0
+	reportPageWidth   = 210.0 - 2*reportMargin
+	reportRowHeight   = 7.0
+	reportChartWidth  = 87.0
+	reportChartHeight = 42.0
+	reportChartGap    = 6.0
+	reportMaxCharts   = 6
+)
+
+// newReportPDF creates an A4 document with the CJK font registered
+func newReportPDF() (*fpdf.Fpdf, error) {
+	font, err := reportFont()
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(reportMargin, reportMargin, reportMargin)
+	pdf.SetAutoPageBreak(true, reportMargin)
+	pdf.AddUTF8FontFromBytes(reportFontFamily, "", font)
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("無法載入報表字型: %w", err)
+	}
+	return pdf, nil
+}
+
+// renderReportCard adds the pages of one student's report card
+func renderReportCard(pdf *fpdf.Fpdf, card *reportCard) {
+	student := card.student
+	pdf.AddPage()
+
+	// Title and student information
+	pdf.SetFont(reportFontFamily, "", 18)
+	pdf.CellFormat(reportPageWidth, 10, student.School.Name+" 體適能成績單", "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont(reportFontFamily, "", 11)
+	classLabel := ClassSheetName(student.Grade, student.Class)
+	info := fmt.Sprintf("姓名：%s　　學號：%s　　班級：%s　　性別：%s",
+		student.Name, student.StudentNumber, classLabel, genderLabel(student.Gender))
+	pdf.CellFormat(reportPageWidth, 7, info, "", 1, "L", false, 0, "")
+	pdf.CellFormat(reportPageWidth, 7, "列印日期："+card.generatedAt.Format("2006/01/02"), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	if len(card.items) == 0 {
+		pdf.CellFormat(reportPageWidth, 10, "尚無檢測成績", "", 1, "C", false, 0, "")
+		return
+	}
+
+	renderReportTable(pdf, card)
+	renderReportCharts(pdf, card)
+	renderReportSuggestions(pdf, card)
+}
+
+// renderReportTable prints the results with percentiles, levels, grade
+// ranks and progress
+func renderReportTable(pdf *fpdf.Fpdf, card *reportCard) {
+	headers := []string{"項目", "最新成績", "測驗日期", "百分等級", "表現", "常模平均", "年級排名", "進退步"}
+	widths := []float64{32, 22, 24, 20, 18, 22, 20, 22}
+
+	pdf.SetFont(reportFontFamily, "", 10)
+	pdf.SetFillColor(226, 239, 218)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], reportRowHeight, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	normSources := map[string]bool{}
+	for _, item := range card.items {
+		latest := item.latest()
+		cells := []string{
+			item.sportType.Name,
+			formatReportValue(latest.Value) + " " + item.sportType.DefaultUnit,
+			latest.TestDate.Format("2006/01/02"),
+			"—", "—", "—", "—", "—",
+		}
+		if item.comparison != nil {
+			cells[3] = strconv.Itoa(item.comparison.PercentileRank)
+			cells[4] = performanceLevelLabels[item.comparison.PerformanceLevel]
+			cells[5] = formatReportValue(item.comparison.NationalAvg)
+			normSources[item.comparison.NormSource] = true
+		}
+		if item.grade != nil {
+			cells[6] = fmt.Sprintf("%d/%d", item.grade.GradeRank, item.grade.TotalStudents)
+		}
+		if item.progress != nil {
+			switch {
+			case item.progress.Change == 0:
+				cells[7] = "持平"
+			case item.progress.IsImprovement:
+				cells[7] = "進步 " + formatReportValue(math.Abs(item.progress.Change))
+			default:
+				cells[7] = "退步 " + formatReportValue(math.Abs(item.progress.Change))
+			}
+		}
+
+		for i, cell := range cells {
+			align := "C"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], reportRowHeight, cell, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Overall level and the norms the percentiles were compared with
+	pdf.Ln(2)
+	pdf.SetFont(reportFontFamily, "", 11)
+	if card.summary.TotalSports > 0 {
+		overall := fmt.Sprintf("整體百分等級：%d（%s）", card.summary.OverallPercentile,
+			performanceLevelLabels[getPerformanceLevel(card.summary.OverallPercentile)])
+		pdf.CellFormat(reportPageWidth, 7, overall, "", 1, "L", false, 0, "")
+	}
+
+	var notes []string
+	if normSources["official"] {
+		notes = append(notes, "官方常模")
+	}
+	if normSources["computed"] {
+		notes = append(notes, "本系統全國平均")
+	}
+	pdf.SetFont(reportFontFamily, "", 9)
+	pdf.SetTextColor(90, 90, 90)
+	note := "百分等級對照：無常模資料"
+	if len(notes) > 0 {
+		note = "百分等級對照：" + notes[0]
+		if len(notes) > 1 {
+			note += "、" + notes[1]
+		}
+	}
+	pdf.CellFormat(reportPageWidth, 6, note, "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(3)
+}
+
+// renderReportCharts draws a small trend chart for each item with at least
+// two records, two charts per row
+func renderReportCharts(pdf *fpdf.Fpdf, card *reportCard) {
+	var items []reportCardItem
+	for _, item := range card.items {
+		if len(item.trend) >= 2 && len(items) < reportMaxCharts {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	pdf.SetFont(reportFontFamily, "", 12)
+	pdf.CellFormat(reportPageWidth, 8, "成績趨勢", "", 1, "L", false, 0, "")
+
+	_, pageHeight := pdf.GetPageSize()
+	var rowY float64
+	for i, item := range items {
+		if i%2 == 0 {
+			if pdf.GetY()+reportChartHeight > pageHeight-reportMargin {
+				pdf.AddPage()
+			}
+			rowY = pdf.GetY()
+		}
+		x := reportMargin + float64(i%2)*(reportChartWidth+reportChartGap)
+		drawTrendChart(pdf, item, x, rowY)
+		if i%2 == 1 || i == len(items)-1 {
+			pdf.SetXY(reportMargin, rowY+reportChartHeight+reportChartGap)
+		}
+	}
+}
+
+// drawTrendChart draws one item's results over time inside the given box
+func drawTrendChart(pdf *fpdf.Fpdf, item reportCardItem, x, y float64) {
+	const (
+		titleHeight = 6.0
+		axisLeft    = 14.0
+		axisBottom  = 8.0
+	)
+
+	pdf.SetDrawColor(180, 180, 180)
+	pdf.Rect(x, y, reportChartWidth, reportChartHeight, "D")
+
+	pdf.SetFont(reportFontFamily, "", 9)
+	pdf.SetXY(x, y+1)
+	pdf.CellFormat(reportChartWidth, titleHeight-1, fmt.Sprintf("%s（%s）", item.sportType.Name, item.sportType.DefaultUnit), "", 0, "C", false, 0, "")
+
+	left := x + axisLeft
+	right := x + reportChartWidth - 4
+	top := y + titleHeight + 2
+	bottom := y + reportChartHeight - axisBottom
+
+	minValue, maxValue := item.trend[0].Value, item.trend[0].Value
+	for _, record := range item.trend {
+		minValue = math.Min(minValue, record.Value)
+		maxValue = math.Max(maxValue, record.Value)
+	}
+	if maxValue == minValue {
+		minValue, maxValue = minValue-1, maxValue+1
+	}
+
+	// Axes with the lowest and highest value
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.Line(left, top, left, bottom)
+	pdf.Line(left, bottom, right, bottom)
+	pdf.SetFont(reportFontFamily, "", 7)
+	pdf.SetXY(x+1, top-2)
+	pdf.CellFormat(axisLeft-2, 4, formatReportValue(maxValue), "", 0, "R", false, 0, "")
+	pdf.SetXY(x+1, bottom-2)
+	pdf.CellFormat(axisLeft-2, 4, formatReportValue(minValue), "", 0, "R", false, 0, "")
+
+	pointX := func(i int) float64 {
+		return left + 3 + float64(i)*(right-left-6)/float64(len(item.trend)-1)
+	}
+	pointY := func(value float64) float64 {
+		return bottom - 2 - (value-minValue)/(maxValue-minValue)*(bottom-top-4)
+	}
+
+	pdf.SetDrawColor(46, 117, 182)
+	pdf.SetFillColor(46, 117, 182)
+	pdf.SetLineWidth(0.5)
+	for i := 1; i < len(item.trend); i++ {
+		pdf.Line(pointX(i-1), pointY(item.trend[i-1].Value), pointX(i), pointY(item.trend[i].Value))
+	}
+	pdf.SetLineWidth(0.2)
+	for i, record := range item.trend {
+		pdf.Circle(pointX(i), pointY(record.Value), 0.8, "F")
+	}
+
+	// Dates of the first and latest test
+	first, last := item.trend[0], item.latest()
+	pdf.SetXY(left, bottom+1)
+	pdf.CellFormat(30, 4, first.TestDate.Format("2006/01/02"), "", 0, "L", false, 0, "")
+	pdf.SetXY(right-30, bottom+1)
+	pdf.CellFormat(30, 4, last.TestDate.Format("2006/01/02"), "", 0, "R", false, 0, "")
+
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// renderReportSuggestions prints advice for the items that need practice
+func renderReportSuggestions(pdf *fpdf.Fpdf, card *reportCard) {
+	var suggestions []string
+	for _, item := range card.items {
+		if suggestion := reportSuggestion(item); suggestion != "" {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	if len(suggestions) == 0 {
+		suggestions = append(suggestions, "各項表現良好，請繼續保持規律運動習慣。")
+	}
+
+	pdf.SetFont(reportFontFamily, "", 12)
+	pdf.CellFormat(reportPageWidth, 8, "運動建議", "", 1, "L", false, 0, "")
+	pdf.SetFont(reportFontFamily, "", 10)
+	for _, suggestion := range suggestions {
+		pdf.MultiCell(reportPageWidth, 6, "• "+suggestion, "", "L", false)
+	}
+}
+
+// formatReportValue prints a value without trailing zeros
+func formatReportValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Class report card batch formats
+const (
+	ReportBatchZip    = "zip" // one PDF per student in a zip file
+	ReportBatchMerged = "pdf" // one PDF with a report card per student
+)
+
+// ReportCardService builds printable fitness report cards (體適能成績單)
+// from the comparison, grade ranking and progress data
+type ReportCardService struct {
+	db         *gorm.DB
+	statistics *StatisticsService
+	records    *SportRecordService
+}
+
+// NewReportCardService creates a new ReportCardService
+func NewReportCardService(db *gorm.DB, statistics *StatisticsService, records *SportRecordService) *ReportCardService {
+	return &ReportCardService{
+		db:         db,
+		statistics: statistics,
+		records:    records,
+	}
+}
+
+// reportCard is the data printed on one student's report card
+type reportCard struct {
+	student     models.Student
+	items       []reportCardItem
+	summary     Summary
+	generatedAt time.Time
+}
+
+// reportCardItem is one sport type the student has results for
+type reportCardItem struct {
+	sportType  models.SportType
+	trend      []models.SportRecord // oldest first
+	comparison *Comparison
+	grade      *GradeComparison
+	progress   *ProgressAnalysis
+}
+
+// latest returns the student's most recent record of the item
+func (item reportCardItem) latest() models.SportRecord {
+	return item.trend[len(item.trend)-1]
+}
+
+// ReportBatch is a class's report cards ready to be written
+type ReportBatch struct {
+	Filename    string
+	ContentType string
+	write       func(w io.Writer) error
+}
+
+// Write streams the report cards to w
+func (b *ReportBatch) Write(w io.Writer) error {
+	return b.write(w)
+}
+
+// StudentReportCard renders one student's report card as PDF
+func (s *ReportCardService) StudentReportCard(ctx context.Context, studentID uint) (*bytes.Buffer, string, error) {
+	var student models.Student
+	if err := s.db.Preload("School").First(&student, studentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", fmt.Errorf("學生不存在")
+		}
+		return nil, "", err
+	}
+
+	card, err := s.buildReportCard(ctx, student)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pdf, err := newReportPDF()
+	if err != nil {
+		return nil, "", err
+	}
+	renderReportCard(pdf, card)
+
+	buffer := new(bytes.Buffer)
+	if err := pdf.Output(buffer); err != nil {
+		return nil, "", fmt.Errorf("無法產生成績單: %w", err)
+	}
+
+	return buffer, reportCardFilename(student), nil
+}
+
+// ClassReportCards prepares the report cards of every student in a class,
+// either as a zip of PDFs or merged into one PDF for printing
+func (s *ReportCardService) ClassReportCards(ctx context.Context, schoolID uint, grade int, class, format string) (*ReportBatch, error) {
+	if format == "" {
+		format = ReportBatchZip
+	}
+	if format != ReportBatchZip && format != ReportBatchMerged {
+		return nil, fmt.Errorf("不支援的格式: %s（可用 zip 或 pdf）", format)
+	}
+	// Fail before any output when no font is available
+	if _, err := reportFont(); err != nil {
+		return nil, err
+	}
+
+	class = NormalizeClassName(class)
+	var students []models.Student
+	err := s.db.Preload("School").
+		Where("school_id = ? AND grade = ? AND class = ?", schoolID, grade, class).
+		Order("student_number").
+		Find(&students).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(students) == 0 {
+		return nil, fmt.Errorf("此班級沒有學生")
+	}
+
+	name := fmt.Sprintf("%s-%s-成績單", students[0].School.Name, ClassSheetName(grade, class))
+	if format == ReportBatchMerged {
+		return &ReportBatch{
+			Filename:    name + ".pdf",
+			ContentType: "application/pdf",
+			write: func(w io.Writer) error {
+				pdf, err := newReportPDF()
+				if err != nil {
+					return err
+				}
+				for _, student := range students {
+					card, err := s.buildReportCard(ctx, student)
+					if err != nil {
+						return fmt.Errorf("%s: %w", student.Name, err)
+					}
+					renderReportCard(pdf, card)
+				}
+				return pdf.Output(w)
+			},
+		}, nil
+	}
+
+	return &ReportBatch{
+		Filename:    name + ".zip",
+		ContentType: "application/zip",
+		write: func(w io.Writer) error {
+			archive := zip.NewWriter(w)
+			for _, student := range students {
+				card, err := s.buildReportCard(ctx, student)
+				if err != nil {
+					return fmt.Errorf("%s: %w", student.Name, err)
+				}
+				pdf, err := newReportPDF()
+				if err != nil {
+					return err
+				}
+				renderReportCard(pdf, card)
+
+				entry, err := archive.CreateHeader(&zip.FileHeader{
+					Name:     reportCardFilename(student),
+					Method:   zip.Deflate,
+					Modified: card.generatedAt,
+				})
+				if err != nil {
+					return err
+				}
+				if err := pdf.Output(entry); err != nil {
+					return fmt.Errorf("%s: %w", student.Name, err)
+				}
+			}
+			return archive.Close()
+		},
+	}, nil
+}
+
+// buildReportCard collects the results, percentiles, grade ranks and
+// progress of every sport type the student has records for
+func (s *ReportCardService) buildReportCard(ctx context.Context, student models.Student) (*reportCard, error) {
+	var records []models.SportRecord
+	err := s.db.Preload("SportType").
+		Where("student_id = ?", student.ID).
+		Order("sport_type_id, test_date ASC, id").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	comparison, err := s.statistics.GetStudentComparison(ctx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	comparisons := make(map[uint]*Comparison, len(comparison.Comparisons))
+	for i := range comparison.Comparisons {
+		comparisons[comparison.Comparisons[i].SportTypeID] = &comparison.Comparisons[i]
+	}
+
+	gradeComparison, err := s.statistics.GetGradeComparison(ctx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	grades := make(map[uint]*GradeComparison, len(gradeComparison.Comparisons))
+	for i := range gradeComparison.Comparisons {
+		grades[gradeComparison.Comparisons[i].SportTypeID] = &gradeComparison.Comparisons[i]
+	}
+
+	card := &reportCard{
+		student:     student,
+		summary:     comparison.Summary,
+		generatedAt: time.Now(),
+	}
+	for _, record := range records {
+		last := len(card.items) - 1
+		if last < 0 || card.items[last].sportType.ID != record.SportTypeID {
+			card.items = append(card.items, reportCardItem{
+				sportType:  record.SportType,
+				comparison: comparisons[record.SportTypeID],
+				grade:      grades[record.SportTypeID],
+			})
+			last++
+		}
+		card.items[last].trend = append(card.items[last].trend, record)
+	}
+
+	for i := range card.items {
+		if len(card.items[i].trend) < 2 {
+			continue
+		}
+		progress, err := s.records.CalculateProgress(student.ID, card.items[i].sportType.ID)
+		if err != nil {
+			return nil, err
+		}
+		card.items[i].progress = progress
+	}
+
+	return card, nil
+}
+
+// reportCardFilename names a student's report card file
+func reportCardFilename(student models.Student) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, student.StudentNumber+"-"+student.Name)
+	return name + ".pdf"
+}
+
+// performanceLevelLabels are the printed names of the performance levels
+var performanceLevelLabels = map[string]string{
+	"excellent":     "優秀",
+	"above_average": "中上",
+	"average":       "中等",
+	"below_average": "待加強",
+}
+
+// reportSuggestion returns exercise advice for an item that is average or
+// below, or that got worse since the first test; empty when none is needed
+func reportSuggestion(item reportCardItem) string {
+	needsWork := item.comparison != nil &&
+		(item.comparison.PerformanceLevel == "average" || item.comparison.PerformanceLevel == "below_average")
+	regressed := item.progress != nil && !item.progress.IsImprovement && item.progress.Change != 0
+	if !needsWork && !regressed {
+		return ""
+	}
+
+	name := item.sportType.Name
+	var advice string
+	switch {
+	case strings.Contains(name, "體前彎"):
+		name += "（柔軟度）"
+		advice = "每天伸展大腿後側與下背部，每個動作停留 15 到 30 秒，運動前後都要做。"
+	case strings.Contains(name, "仰臥起坐"):
+		name += "（肌耐力）"
+		advice = "每週 3 次練習仰臥起坐、棒式等核心運動，逐漸增加次數與時間。"
+	case strings.Contains(name, "跳遠") || strings.Contains(name, "跳高"):
+		name += "（瞬發力）"
+		advice = "多做跳繩、蹲跳、開合跳等下肢爆發力練習，注意落地緩衝。"
+	case item.sportType.Category == models.CategoryFitness && item.sportType.ValueType == models.ValueTypeTime:
+		name += "（心肺耐力）"
+		advice = "每週至少 3 次、每次 30 分鐘以上的慢跑、騎車或游泳等有氧運動。"
+	default:
+		advice = "多利用課餘時間練習此項目，維持規律運動習慣。"
+	}
+
+	if regressed && !needsWork {
+		return fmt.Sprintf("%s：成績較第一次測驗退步，%s", name, advice)
+	}
+	return fmt.Sprintf("%s：%s", name, advice)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// embeddedFonts holds the TrueType fonts compiled into the binary for PDF
+// reports; see fonts/README.md
+//
+//go:embed fonts
+var embeddedFonts embed.FS
+
+// reportFontFamily is the family name the CJK font is registered under
+const reportFontFamily = "cjk"
+
+var (
+	reportFontOnce sync.Once
+	reportFontData []byte
+	reportFontErr  error
+)
+
+// reportFont returns the CJK TrueType font used by PDF reports: the file at
+// REPORT_FONT_PATH when set, otherwise the first embedded .ttf file
+func reportFont() ([]byte, error) {
+	reportFontOnce.Do(func() {
+		reportFontData, reportFontErr = loadReportFont()
+	})
+	return reportFontData, reportFontErr
+}
+
+func loadReportFont() ([]byte, error) {
+	if fontPath := os.Getenv("REPORT_FONT_PATH"); fontPath != "" {
+		data, err := os.ReadFile(fontPath)
+		if err != nil {
+			return nil, fmt.Errorf("無法讀取報表字型 %s: %w", fontPath, err)
+		}
+		return data, nil
+	}
+
+	entries, err := fs.ReadDir(embeddedFonts, "fonts")
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取內建字型: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(path.Ext(entry.Name()), ".ttf") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("未安裝中文字型，請將 TrueType 字型放入 fonts 目錄或設定 REPORT_FONT_PATH")
+	}
+	sort.Strings(names)
+
+	return embeddedFonts.ReadFile("fonts/" + names[0])
+}
+
+// CheckReportFont loads and parses the report font, so a missing or broken
+// REPORT_FONT_PATH is reported when the server starts instead of on every
+// report request
+func CheckReportFont() error {
+	_, err := newReportPDF()
+	return err
+}
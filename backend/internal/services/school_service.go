@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/wei979/ICACP/backend/internal/models"
 	"gorm.io/gorm"
@@ -19,8 +20,11 @@ func NewSchoolService(db *gorm.DB) *SchoolService {
 	}
 }
 
-// List retrieves a paginated list of schools
-func (s *SchoolService) List(page, pageSize int) ([]models.School, *models.Pagination, error) {
+// List retrieves a paginated list of schools matching the search parameters
+func (s *SchoolService) List(params *models.SchoolSearchParams) ([]models.School, *models.Pagination, error) {
+	page := params.Page
+	pageSize := params.PageSize
+
 	if page < 1 {
 		page = 1
 	}
@@ -31,8 +35,39 @@ func (s *SchoolService) List(page, pageSize int) ([]models.School, *models.Pagin
 	var schools []models.School
 	var total int64
 
+	query := s.db.Model(&models.School{})
+
+	// Apply filters
+	if params.Name != "" {
+		query = query.Where("schools.name LIKE ?", "%"+params.Name+"%")
+	}
+	if params.CountyName != "" {
+		query = query.Where("schools.county_name = ?", params.CountyName)
+	}
+	if params.District != "" {
+		query = query.Where("schools.district = ?", params.District)
+	}
+	if params.SchoolCode != "" {
+		query = query.Where("schools.school_code = ?", params.SchoolCode)
+	}
+	if params.Level != "" {
+		query = query.Where("schools.level = ?", params.Level)
+	}
+	if params.IsIndigenousKeySchool != nil {
+		query = query.Where("schools.is_indigenous_key_school = ?", *params.IsIndigenousKeySchool)
+	}
+	if params.UrbanizationLevel != "" {
+		query = query.Where("schools.urbanization_level = ?", params.UrbanizationLevel)
+	}
+	if params.MinEnrollment > 0 {
+		query = query.Where("schools.enrollment_size >= ?", params.MinEnrollment)
+	}
+	if params.MaxEnrollment > 0 {
+		query = query.Where("schools.enrollment_size <= ?", params.MaxEnrollment)
+	}
+
 	// Count total records
-	if err := s.db.Model(&models.School{}).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, nil, fmt.Errorf("failed to count schools: %w", err)
 	}
 
@@ -40,16 +75,13 @@ func (s *SchoolService) List(page, pageSize int) ([]models.School, *models.Pagin
 	offset := (page - 1) * pageSize
 	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
 
-	// Fetch schools with student count using Raw SQL for proper mapping
-	err := s.db.Raw(`
-		SELECT
-			schools.*,
-			(SELECT COUNT(*) FROM students WHERE students.school_id = schools.id AND students.deleted_at IS NULL) as student_count
-		FROM schools
-		WHERE schools.deleted_at IS NULL
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`, pageSize, offset).Scan(&schools).Error
+	// Fetch schools with student count
+	err := query.
+		Select("schools.*, (SELECT COUNT(*) FROM students WHERE students.school_id = schools.id AND students.deleted_at IS NULL) as student_count").
+		Offset(offset).
+		Limit(pageSize).
+		Order("schools.created_at DESC").
+		Find(&schools).Error
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to list schools: %w", err)
@@ -97,14 +129,33 @@ func (s *SchoolService) Create(req *models.CreateSchoolRequest) (*models.School,
 	if !models.IsValidCountyName(req.CountyName) {
 		return nil, fmt.Errorf("無效的縣市名稱")
 	}
+	if err := validateSchoolAttributes(req.Level, req.UrbanizationLevel); err != nil {
+		return nil, err
+	}
+
+	district, err := resolveDistrict(req.CountyName, req.District, req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	schoolCode := normalizeSchoolCode(req.SchoolCode)
+	if err := s.checkSchoolCode(schoolCode, 0); err != nil {
+		return nil, err
+	}
 
 	school := &models.School{
-		Name:       req.Name,
-		CountyName: req.CountyName,
-		Address:    req.Address,
-		Phone:      req.Phone,
-		Latitude:   req.Latitude,
-		Longitude:  req.Longitude,
+		SchoolCode:            schoolCode,
+		Name:                  req.Name,
+		CountyName:            req.CountyName,
+		District:              district,
+		Address:               req.Address,
+		Phone:                 req.Phone,
+		Level:                 req.Level,
+		IsIndigenousKeySchool: req.IsIndigenousKeySchool,
+		UrbanizationLevel:     req.UrbanizationLevel,
+		EnrollmentSize:        req.EnrollmentSize,
+		Latitude:              req.Latitude,
+		Longitude:             req.Longitude,
 	}
 
 	if err := s.db.Create(school).Error; err != nil {
@@ -121,6 +172,11 @@ func (s *SchoolService) Update(id uint, req *models.UpdateSchoolRequest) (*model
 		return nil, fmt.Errorf("無效的縣市名稱")
 	}
 
+	district, err := resolveDistrict(req.CountyName, req.District, req.Address)
+	if err != nil {
+		return nil, err
+	}
+
 	var school models.School
 	if err := s.db.First(&school, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -129,8 +185,33 @@ func (s *SchoolService) Update(id uint, req *models.UpdateSchoolRequest) (*model
 		return nil, fmt.Errorf("failed to get school: %w", err)
 	}
 
+	// Attributes left out of the request keep their current values
+	if req.SchoolCode != nil {
+		schoolCode := normalizeSchoolCode(*req.SchoolCode)
+		if err := s.checkSchoolCode(schoolCode, school.ID); err != nil {
+			return nil, err
+		}
+		school.SchoolCode = schoolCode
+	}
+	if req.Level != nil {
+		school.Level = *req.Level
+	}
+	if req.IsIndigenousKeySchool != nil {
+		school.IsIndigenousKeySchool = *req.IsIndigenousKeySchool
+	}
+	if req.UrbanizationLevel != nil {
+		school.UrbanizationLevel = *req.UrbanizationLevel
+	}
+	if req.EnrollmentSize != nil {
+		school.EnrollmentSize = req.EnrollmentSize
+	}
+	if err := validateSchoolAttributes(school.Level, school.UrbanizationLevel); err != nil {
+		return nil, err
+	}
+
 	school.Name = req.Name
 	school.CountyName = req.CountyName
+	school.District = district
 	school.Address = req.Address
 	school.Phone = req.Phone
 	school.Latitude = req.Latitude
@@ -143,6 +224,58 @@ func (s *SchoolService) Update(id uint, req *models.UpdateSchoolRequest) (*model
 	return &school, nil
 }
 
+// validateSchoolAttributes validates the school level and urbanisation class
+func validateSchoolAttributes(level, urbanizationLevel string) error {
+	if !models.IsValidSchoolLevel(level) {
+		return fmt.Errorf("無效的學校層級")
+	}
+	if !models.IsValidUrbanizationLevel(urbanizationLevel) {
+		return fmt.Errorf("無效的都市化程度")
+	}
+	return nil
+}
+
+// resolveDistrict validates a given district against the county's official
+// list, or extracts it from the address when none is given
+func resolveDistrict(countyName, district, address string) (string, error) {
+	district = strings.ReplaceAll(strings.TrimSpace(district), "台", "臺")
+	if district == "" {
+		return models.ExtractDistrict(countyName, address), nil
+	}
+	if !models.IsValidDistrict(countyName, district) {
+		return "", fmt.Errorf("無效的鄉鎮市區")
+	}
+	return district, nil
+}
+
+// normalizeSchoolCode trims a school code, returning nil when it is empty
+func normalizeSchoolCode(code string) *string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil
+	}
+	return &code
+}
+
+// checkSchoolCode returns an error if the school code is used by another school.
+// Deleted schools are included because the code is unique in the table.
+func (s *SchoolService) checkSchoolCode(code *string, excludeID uint) error {
+	if code == nil {
+		return nil
+	}
+	var count int64
+	err := s.db.Unscoped().Model(&models.School{}).
+		Where("school_code = ? AND id != ?", *code, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check school code: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("此學校代碼已存在")
+	}
+	return nil
+}
+
 // Delete soft deletes a school and its students
 func (s *SchoolService) Delete(id uint) error {
 	// Check if school exists
@@ -202,42 +335,9 @@ func (s *SchoolService) ListForMap() ([]models.SchoolMapData, int, error) {
 
 // ListByCounty retrieves schools by county name
 func (s *SchoolService) ListByCounty(countyName string, page, pageSize int) ([]models.School, *models.Pagination, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-
-	var schools []models.School
-	var total int64
-
-	// Count total records for the county
-	if err := s.db.Model(&models.School{}).Where("county_name = ?", countyName).Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("failed to count schools: %w", err)
-	}
-
-	// Calculate pagination
-	offset := (page - 1) * pageSize
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-
-	// Fetch schools
-	err := s.db.Where("county_name = ?", countyName).
-		Offset(offset).
-		Limit(pageSize).
-		Order("created_at DESC").
-		Find(&schools).Error
-
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list schools: %w", err)
-	}
-
-	pagination := &models.Pagination{
+	return s.List(&models.SchoolSearchParams{
 		Page:       page,
 		PageSize:   pageSize,
-		Total:      int(total),
-		TotalPages: totalPages,
-	}
-
-	return schools, pagination, nil
+		CountyName: countyName,
+	})
 }
@@ -0,0 +1,564 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// summaryTopCount is how many students the top performer and most improved
+// sheets list per sport type
+const summaryTopCount = 10
+
+// SchoolSummaryService builds the annual fitness summary workbook a school
+// submits to its county
+type SchoolSummaryService struct {
+	db         *gorm.DB
+	statistics *StatisticsService
+}
+
+// NewSchoolSummaryService creates a new SchoolSummaryService
+func NewSchoolSummaryService(db *gorm.DB, statistics *StatisticsService) *SchoolSummaryService {
+	return &SchoolSummaryService{
+		db:         db,
+		statistics: statistics,
+	}
+}
+
+// schoolSummary holds the data of one school and period the sheets are
+// built from
+type schoolSummary struct {
+	school     models.School
+	term       string
+	from, to   time.Time
+	students   []models.Student
+	sportTypes []models.SportType // sport types with records in the period
+	// latest record of each student per sport type in the period
+	latest map[uint]map[uint]models.SportRecord
+	// last record before the latest one, in or before the period
+	previous map[uint]map[uint]models.SportRecord
+	// number of records of each student in the period
+	recordCounts map[uint]int
+}
+
+// SchoolAnnualSummary builds the summary workbook of a school for a term
+// ("113-1") or a whole academic year ("113")
+func (s *SchoolSummaryService) SchoolAnnualSummary(ctx context.Context, schoolID uint, term string) (*bytes.Buffer, string, error) {
+	if term == "" {
+		term = fmt.Sprint(CurrentAcademicYear())
+	}
+	from, to, err := ParseAcademicTerm(term)
+	if err != nil {
+		return nil, "", err
+	}
+
+	summary, err := s.loadSchoolSummary(schoolID, term, from, to)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	builders := []func(*excelize.File, *schoolSummary) error{
+		writeParticipationSheet,
+		writeSportStatisticsSheet,
+		func(f *excelize.File, summary *schoolSummary) error {
+			return s.writeComparisonSheet(ctx, f, summary)
+		},
+		writeTopPerformersSheet,
+		writeMostImprovedSheet,
+		writeCompletenessSheet,
+	}
+	for _, build := range builders {
+		if err := build(f, summary); err != nil {
+			return nil, "", err
+		}
+	}
+	f.DeleteSheet("Sheet1")
+	f.SetActiveSheet(0)
+
+	buffer := new(bytes.Buffer)
+	if err := f.Write(buffer); err != nil {
+		return nil, "", err
+	}
+
+	filename := fmt.Sprintf("%s-%s-體適能年度統計.xlsx", summary.school.Name, term)
+	return buffer, filename, nil
+}
+
+// loadSchoolSummary reads the school's students and their records of the period
+func (s *SchoolSummaryService) loadSchoolSummary(schoolID uint, term string, from, to time.Time) (*schoolSummary, error) {
+	var school models.School
+	if err := s.db.First(&school, schoolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("學校不存在")
+		}
+		return nil, err
+	}
+
+	summary := &schoolSummary{
+		school:       school,
+		term:         term,
+		from:         from,
+		to:           to,
+		latest:       make(map[uint]map[uint]models.SportRecord),
+		previous:     make(map[uint]map[uint]models.SportRecord),
+		recordCounts: make(map[uint]int),
+	}
+
+	if err := s.db.Where("school_id = ?", schoolID).
+		Order("grade, class, student_number").
+		Find(&summary.students).Error; err != nil {
+		return nil, err
+	}
+
+	// Records up to the end of the period, oldest first, so the last two
+	// seen are the latest and the one before it
+	var records []models.SportRecord
+	err := s.db.Joins("JOIN students ON students.id = sport_records.student_id").
+		Where("students.school_id = ? AND students.deleted_at IS NULL AND sport_records.test_date < ?",
+			schoolID, to.Format("2006-01-02")).
+		Order("sport_records.test_date, sport_records.id").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sportTypeIDs := make(map[uint]bool)
+	for _, record := range records {
+		if summary.latest[record.StudentID] == nil {
+			summary.latest[record.StudentID] = make(map[uint]models.SportRecord)
+			summary.previous[record.StudentID] = make(map[uint]models.SportRecord)
+		}
+		if last, ok := summary.latest[record.StudentID][record.SportTypeID]; ok {
+			summary.previous[record.StudentID][record.SportTypeID] = last
+		}
+		summary.latest[record.StudentID][record.SportTypeID] = record
+
+		if !record.TestDate.Before(from) {
+			summary.recordCounts[record.StudentID]++
+			sportTypeIDs[record.SportTypeID] = true
+		}
+	}
+
+	// Keep only latest records that fall in the period
+	for studentID, bySport := range summary.latest {
+		for sportTypeID, record := range bySport {
+			if record.TestDate.Before(from) {
+				delete(bySport, sportTypeID)
+			}
+		}
+		if len(bySport) == 0 {
+			delete(summary.latest, studentID)
+		}
+	}
+
+	if len(sportTypeIDs) > 0 {
+		ids := make([]uint, 0, len(sportTypeIDs))
+		for id := range sportTypeIDs {
+			ids = append(ids, id)
+		}
+		if err := s.db.Where("id IN ?", ids).Order("id").Find(&summary.sportTypes).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return summary, nil
+}
+
+// summaryValues returns the latest values of a sport type in the period for
+// the students that match the filter
+func (summary *schoolSummary) summaryValues(sportTypeID uint, match func(models.Student) bool) []float64 {
+	var values []float64
+	for _, student := range summary.students {
+		if !match(student) {
+			continue
+		}
+		if record, ok := summary.latest[student.ID][sportTypeID]; ok {
+			values = append(values, record.Value)
+		}
+	}
+	return values
+}
+
+// summaryGrades returns the grades of the school's students in order
+func (summary *schoolSummary) summaryGrades() []int {
+	var grades []int
+	for _, student := range summary.students {
+		if len(grades) == 0 || grades[len(grades)-1] != student.Grade {
+			grades = append(grades, student.Grade)
+		}
+	}
+	return grades
+}
+
+// summaryClass is one class of the school with its students
+type summaryClass struct {
+	grade    int
+	class    string
+	students []models.Student
+}
+
+// summaryClasses groups the school's students by grade and class
+func (summary *schoolSummary) summaryClasses() []summaryClass {
+	var classes []summaryClass
+	for _, student := range summary.students {
+		last := len(classes) - 1
+		if last < 0 || classes[last].grade != student.Grade || classes[last].class != student.Class {
+			classes = append(classes, summaryClass{grade: student.Grade, class: student.Class})
+			last++
+		}
+		classes[last].students = append(classes[last].students, student)
+	}
+	return classes
+}
+
+// summarySheet writes rows to one sheet of the summary workbook
+type summarySheet struct {
+	f           *excelize.File
+	name        string
+	row         int
+	titleStyle  int
+	headerStyle int
+}
+
+// newSummarySheet adds a sheet with a title line naming the school and period
+func newSummarySheet(f *excelize.File, name string, summary *schoolSummary) (*summarySheet, error) {
+	if _, err := f.NewSheet(name); err != nil {
+		return nil, err
+	}
+	titleStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return nil, err
+	}
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E2EFDA"}, Pattern: 1},
+		Border: []excelize.Border{
+			{Type: "left", Color: "#000000", Style: 1},
+			{Type: "top", Color: "#000000", Style: 1},
+			{Type: "bottom", Color: "#000000", Style: 1},
+			{Type: "right", Color: "#000000", Style: 1},
+		},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &summarySheet{f: f, name: name, titleStyle: titleStyle, headerStyle: headerStyle}
+	sheet.title(fmt.Sprintf("%s %s %s（%s 至 %s）", summary.school.Name, summary.term, name,
+		summary.from.Format("2006/01/02"), summary.to.AddDate(0, 0, -1).Format("2006/01/02")))
+	sheet.row++
+	return sheet, nil
+}
+
+// title writes a bold line
+func (s *summarySheet) title(text string) {
+	s.row++
+	cell, _ := excelize.CoordinatesToCellName(1, s.row)
+	s.f.SetCellValue(s.name, cell, text)
+	s.f.SetCellStyle(s.name, cell, cell, s.titleStyle)
+}
+
+// header writes a header row and widens its columns
+func (s *summarySheet) header(headers ...string) {
+	s.row++
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, s.row)
+		s.f.SetCellValue(s.name, cell, header)
+		s.f.SetCellStyle(s.name, cell, cell, s.headerStyle)
+
+		column, _ := excelize.ColumnNumberToName(i + 1)
+		if width, _ := s.f.GetColWidth(s.name, column); width < 12 {
+			s.f.SetColWidth(s.name, column, column, 12)
+		}
+	}
+}
+
+// values writes a data row
+func (s *summarySheet) values(values ...interface{}) {
+	s.row++
+	cell, _ := excelize.CoordinatesToCellName(1, s.row)
+	s.f.SetSheetRow(s.name, cell, &values)
+}
+
+// round2 rounds to two decimals for display
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// percent returns part/total as a percentage with one decimal
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*1000) / 10
+}
+
+// writeParticipationSheet lists per class how many students were tested
+func writeParticipationSheet(f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "參與人數", summary)
+	if err != nil {
+		return err
+	}
+	sheet.header("年級", "班級", "學生數", "受測人數", "參與率(%)", "成績筆數")
+
+	totalStudents, totalTested, totalRecords := 0, 0, 0
+	for _, class := range summary.summaryClasses() {
+		tested, records := 0, 0
+		for _, student := range class.students {
+			if summary.recordCounts[student.ID] > 0 {
+				tested++
+			}
+			records += summary.recordCounts[student.ID]
+		}
+		sheet.values(class.grade, class.class, len(class.students), tested, percent(tested, len(class.students)), records)
+		totalStudents += len(class.students)
+		totalTested += tested
+		totalRecords += records
+	}
+	sheet.values("合計", "", totalStudents, totalTested, percent(totalTested, totalStudents), totalRecords)
+
+	return nil
+}
+
+// writeSportStatisticsSheet lists mean, median and percentiles per sport
+// type, grade and gender, from each student's latest result in the period
+func writeSportStatisticsSheet(f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "成績統計", summary)
+	if err != nil {
+		return err
+	}
+	sheet.header("項目", "單位", "年級", "性別", "人數", "平均", "中位數", "P25", "P75", "P90", "最低", "最高")
+
+	for _, sportType := range summary.sportTypes {
+		for _, grade := range summary.summaryGrades() {
+			for _, gender := range []string{"male", "female"} {
+				values := summary.summaryValues(sportType.ID, func(student models.Student) bool {
+					return student.Grade == grade && student.Gender == gender
+				})
+				if len(values) == 0 {
+					continue
+				}
+				sorted := append([]float64(nil), values...)
+				sort.Float64s(sorted)
+				sheet.values(sportType.Name, sportType.DefaultUnit, grade, genderLabel(gender), len(values),
+					round2(calculateMean(values)), round2(calculatePercentile(values, 0.5)),
+					round2(calculatePercentile(values, 0.25)), round2(calculatePercentile(values, 0.75)),
+					round2(calculatePercentile(values, 0.9)), sorted[0], sorted[len(sorted)-1])
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeComparisonSheet compares the school's means with its county's
+// averages of the same period per sport type, and with the national averages
+// per grade and gender
+func (s *SchoolSummaryService) writeComparisonSheet(ctx context.Context, f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "縣市與全國比較", summary)
+	if err != nil {
+		return err
+	}
+
+	countyAverages, err := s.statistics.GetCountySportAveragesBetween(ctx, summary.school.CountyName, summary.from, summary.to)
+	if err != nil {
+		return err
+	}
+	countyBySport := make(map[uint]CountySportAverage, len(countyAverages))
+	for _, average := range countyAverages {
+		countyBySport[average.SportTypeID] = average
+	}
+
+	sheet.title("與" + summary.school.CountyName + "平均比較")
+	sheet.header("項目", "單位", "本校人數", "本校平均", "縣市人數", "縣市平均", "差異")
+	for _, sportType := range summary.sportTypes {
+		values := summary.summaryValues(sportType.ID, func(models.Student) bool { return true })
+		if len(values) == 0 {
+			continue
+		}
+		mean := round2(calculateMean(values))
+		county, ok := countyBySport[sportType.ID]
+		if !ok {
+			sheet.values(sportType.Name, sportType.DefaultUnit, len(values), mean, nil, nil, nil)
+			continue
+		}
+		sheet.values(sportType.Name, sportType.DefaultUnit, len(values), mean,
+			county.StudentCount, county.AvgValue, round2(mean-county.AvgValue))
+	}
+
+	sheet.row++
+	sheet.title("與全國平均比較")
+	sheet.header("項目", "單位", "年級", "性別", "本校人數", "本校平均", "全國平均", "差異", "全國樣本數")
+	for _, sportType := range summary.sportTypes {
+		for _, grade := range summary.summaryGrades() {
+			for _, gender := range []string{"male", "female"} {
+				values := summary.summaryValues(sportType.ID, func(student models.Student) bool {
+					return student.Grade == grade && student.Gender == gender
+				})
+				if len(values) == 0 {
+					continue
+				}
+				mean := round2(calculateMean(values))
+
+				national, err := s.statistics.GetNationalAverages(ctx, sportType.ID, grade, gender)
+				if err != nil {
+					return err
+				}
+				if len(national) == 0 {
+					sheet.values(sportType.Name, sportType.DefaultUnit, grade, genderLabel(gender), len(values), mean, nil, nil, nil)
+					continue
+				}
+				sheet.values(sportType.Name, sportType.DefaultUnit, grade, genderLabel(gender), len(values), mean,
+					national[0].AvgValue, round2(mean-national[0].AvgValue), national[0].SampleCount)
+			}
+		}
+	}
+
+	return nil
+}
+
+// summaryStudentResult is one student's result used for rankings
+type summaryStudentResult struct {
+	student models.Student
+	record  models.SportRecord
+	score   float64 // higher is better
+}
+
+// writeTopPerformersSheet lists the best students per sport type
+func writeTopPerformersSheet(f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "優秀學生", summary)
+	if err != nil {
+		return err
+	}
+	sheet.header("項目", "名次", "年級", "班級", "學號", "姓名", "性別", "成績", "單位", "測驗日期")
+
+	for _, sportType := range summary.sportTypes {
+		var results []summaryStudentResult
+		for _, student := range summary.students {
+			record, ok := summary.latest[student.ID][sportType.ID]
+			if !ok {
+				continue
+			}
+			score := record.Value
+			if sportType.ValueType == models.ValueTypeTime {
+				score = -score
+			}
+			results = append(results, summaryStudentResult{student: student, record: record, score: score})
+		}
+
+		for i, result := range topSummaryResults(results) {
+			sheet.values(sportType.Name, i+1, result.student.Grade, result.student.Class, result.student.StudentNumber,
+				result.student.Name, genderLabel(result.student.Gender), result.record.Value, sportType.DefaultUnit,
+				result.record.TestDate.Format("2006-01-02"))
+		}
+	}
+
+	return nil
+}
+
+// writeMostImprovedSheet lists per sport type the students whose latest
+// result improved most over their previous test
+func writeMostImprovedSheet(f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "進步最多", summary)
+	if err != nil {
+		return err
+	}
+	sheet.header("項目", "名次", "年級", "班級", "學號", "姓名", "前次成績", "前次日期", "本次成績", "本次日期", "進步幅度", "進步(%)")
+
+	for _, sportType := range summary.sportTypes {
+		var results []summaryStudentResult
+		previous := make(map[uint]models.SportRecord)
+		for _, student := range summary.students {
+			record, ok := summary.latest[student.ID][sportType.ID]
+			if !ok {
+				continue
+			}
+			before, ok := summary.previous[student.ID][sportType.ID]
+			if !ok || before.Value == 0 {
+				continue
+			}
+			change := record.Value - before.Value
+			if sportType.ValueType == models.ValueTypeTime {
+				change = -change
+			}
+			if change <= 0 {
+				continue
+			}
+			previous[student.ID] = before
+			results = append(results, summaryStudentResult{student: student, record: record, score: change / before.Value})
+		}
+
+		for i, result := range topSummaryResults(results) {
+			before := previous[result.student.ID]
+			sheet.values(sportType.Name, i+1, result.student.Grade, result.student.Class, result.student.StudentNumber,
+				result.student.Name, before.Value, before.TestDate.Format("2006-01-02"),
+				result.record.Value, result.record.TestDate.Format("2006-01-02"),
+				round2(math.Abs(result.record.Value-before.Value)), round2(result.score*100))
+		}
+	}
+
+	return nil
+}
+
+// topSummaryResults returns the best results, highest score first
+func topSummaryResults(results []summaryStudentResult) []summaryStudentResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	if len(results) > summaryTopCount {
+		results = results[:summaryTopCount]
+	}
+	return results
+}
+
+// writeCompletenessSheet shows per class which data is missing: birth dates,
+// students without any result and how many were tested in each sport type
+func writeCompletenessSheet(f *excelize.File, summary *schoolSummary) error {
+	sheet, err := newSummarySheet(f, "資料完整度", summary)
+	if err != nil {
+		return err
+	}
+	headers := []string{"年級", "班級", "學生數", "缺生日", "無任何成績"}
+	for _, sportType := range summary.sportTypes {
+		headers = append(headers, sportType.Name+"受測(%)")
+	}
+	sheet.header(headers...)
+
+	for _, class := range summary.summaryClasses() {
+		missingBirthDate, untested := 0, 0
+		tested := make([]int, len(summary.sportTypes))
+		for _, student := range class.students {
+			if student.BirthDate == nil {
+				missingBirthDate++
+			}
+			if summary.recordCounts[student.ID] == 0 {
+				untested++
+			}
+			for i, sportType := range summary.sportTypes {
+				if _, ok := summary.latest[student.ID][sportType.ID]; ok {
+					tested[i]++
+				}
+			}
+		}
+
+		row := []interface{}{class.grade, class.class, len(class.students), missingBirthDate, untested}
+		for _, count := range tested {
+			row = append(row, percent(count, len(class.students)))
+		}
+		sheet.values(row...)
+	}
+
+	return nil
+}
@@ -172,8 +172,12 @@ func (s *SportRecordService) GetByID(id uint) (*models.SportRecord, error) {
 	return &record, nil
 }
 
-// Create creates a new sport record
-func (s *SportRecordService) Create(req *models.CreateSportRecordRequest) (*models.SportRecord, error) {
+// Create creates a new sport record. When the student already has a record
+// of the sport on the test date, req.DuplicatePolicy decides the outcome:
+// skip returns the existing record, replace overwrites its value (audited)
+// and keep_both, the default, adds the new record anyway. The existing record
+// is reported as DuplicateOf in every case.
+func (s *SportRecordService) Create(req *models.CreateSportRecordRequest, changedBy uint) (*models.CreateSportRecordResult, error) {
 	// Validate student exists
 	var student models.Student
 	if err := s.db.First(&student, req.StudentID).Error; err != nil {
@@ -208,6 +212,37 @@ func (s *SportRecordService) Create(req *models.CreateSportRecordRequest) (*mode
 		return nil, fmt.Errorf("數值必須大於零")
 	}
 
+	if req.DuplicatePolicy == "" {
+		req.DuplicatePolicy = models.DuplicatePolicyKeepBoth
+	}
+	if !models.IsValidDuplicatePolicy(req.DuplicatePolicy) {
+		return nil, fmt.Errorf("無效的重複記錄處理方式")
+	}
+
+	// Check for a record of the same sport on the same day
+	existing, err := FindDuplicateRecord(s.db, req.StudentID, req.SportTypeID, testDate)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.CreateSportRecordResult{Outcome: models.RecordOutcomeCreated}
+	if existing != nil {
+		result.DuplicateOf = &existing.ID
+		switch req.DuplicatePolicy {
+		case models.DuplicatePolicyReplace:
+			if err := ReplaceRecordValue(s.db, existing, req.Value, req.Notes, changedBy, "重複記錄取代"); err != nil {
+				return nil, err
+			}
+			s.db.Preload("SportType").First(existing, existing.ID)
+			result.Record, result.Outcome = existing, models.RecordOutcomeReplaced
+			return result, nil
+		case models.DuplicatePolicySkip:
+			s.db.Preload("SportType").First(existing, existing.ID)
+			result.Record, result.Outcome = existing, models.RecordOutcomeSkipped
+			return result, nil
+		}
+		result.Outcome = models.RecordOutcomeCreatedDuplicate
+	}
+
 	record := &models.SportRecord{
 		StudentID:   req.StudentID,
 		SportTypeID: req.SportTypeID,
@@ -223,7 +258,55 @@ func (s *SportRecordService) Create(req *models.CreateSportRecordRequest) (*mode
 	// Reload with relations
 	s.db.Preload("SportType").First(record, record.ID)
 
-	return record, nil
+	result.Record = record
+	return result, nil
+}
+
+// FindDuplicateRecord returns the earliest record of a student for a sport
+// on a test date, or nil when there is none
+func FindDuplicateRecord(db *gorm.DB, studentID, sportTypeID uint, testDate time.Time) (*models.SportRecord, error) {
+	var records []models.SportRecord
+	err := db.Where("student_id = ? AND sport_type_id = ? AND test_date = ?",
+		studentID, sportTypeID, testDate.Format("2006-01-02")).
+		Order("id ASC").
+		Limit(1).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check duplicate record: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// ReplaceRecordValue overwrites the value of an existing record with the
+// value of a duplicate, recording the change in the audit trail
+func ReplaceRecordValue(db *gorm.DB, record *models.SportRecord, value float64, notes string, changedBy uint, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if record.Value != value {
+			oldValue := record.Value
+			audit := &models.SportRecordAudit{
+				SportRecordID: record.ID,
+				OldValue:      &oldValue,
+				NewValue:      &value,
+				ChangedBy:     changedBy,
+				Reason:        reason,
+			}
+			if err := tx.Create(audit).Error; err != nil {
+				return fmt.Errorf("failed to create audit trail: %w", err)
+			}
+		}
+
+		record.Value = value
+		if notes != "" {
+			record.Notes = notes
+		}
+		if err := tx.Omit("Student", "SportType").Save(record).Error; err != nil {
+			return fmt.Errorf("failed to update sport record: %w", err)
+		}
+		return nil
+	})
 }
 
 // Update updates an existing sport record and creates audit trail
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wei979/ICACP/backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// normColumnAliases 常模檔案各欄位可接受的標題
+var normColumnAliases = map[string][]string{
+	"sport_type":   {"項目", "檢測項目", "運動項目", "sport", "sport_type", "item"},
+	"age":          {"年齡", "足歲", "age"},
+	"gender":       {"性別", "gender", "sex"},
+	"sample_count": {"樣本數", "人數", "sample_count", "n"},
+	"avg_value":    {"平均", "平均數", "平均值", "mean", "avg", "average"},
+	"percentile25": {"p25", "百分等級25", "第25百分位", "percentile25"},
+	"percentile50": {"p50", "百分等級50", "第50百分位", "中位數", "median", "percentile50"},
+	"percentile75": {"p75", "百分等級75", "第75百分位", "percentile75"},
+	"percentile90": {"p90", "百分等級90", "第90百分位", "percentile90"},
+}
+
+// normRequiredColumns 常模檔案必須包含的欄位
+var normRequiredColumns = []string{"sport_type", "age", "gender", "percentile25", "percentile50", "percentile75", "percentile90"}
+
+// NormImportError 常模檔案中單一列的錯誤
+type NormImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NormImportErrors 常模檔案驗證失敗時回傳的所有錯誤
+type NormImportErrors struct {
+	Errors []NormImportError
+}
+
+func (e *NormImportErrors) Error() string {
+	return fmt.Sprintf("常模檔案有 %d 個錯誤", len(e.Errors))
+}
+
+// NormSourceSetting 比較基準來源設定與目前啟用的常模版本
+type NormSourceSetting struct {
+	Source      string            `json:"source"`
+	ActiveTable *models.NormTable `json:"active_table"`
+}
+
+// ImportNormTable 從 CSV 匯入官方常模，建立新的常模版本。
+// 檔案每列為一個項目/年齡/性別的百分位數值，任一列有誤則整份不匯入。
+func (s *StatisticsService) ImportNormTable(ctx context.Context, file io.Reader, filename string, req *models.ImportNormRequest, importedBy uint) (*models.NormTable, error) {
+	wb, err := ReadWorkbook(file, filename)
+	if err != nil {
+		return nil, err
+	}
+	rows := wb.Sheets[0].Rows
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("常模檔案沒有資料")
+	}
+
+	// 依標題對應欄位
+	columns := make(map[string]int)
+	for i, header := range rows[0] {
+		h := normalizeHeader(header)
+		for field, aliases := range normColumnAliases {
+			if _, found := columns[field]; found {
+				continue
+			}
+			for _, alias := range aliases {
+				if h == normalizeHeader(alias) {
+					columns[field] = i
+					break
+				}
+			}
+		}
+	}
+	var missing []string
+	for _, field := range normRequiredColumns {
+		if _, found := columns[field]; !found {
+			missing = append(missing, normColumnAliases[field][0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("常模檔案缺少欄位: %s", strings.Join(missing, "、"))
+	}
+
+	var sportTypes []models.SportType
+	if err := s.db.Find(&sportTypes).Error; err != nil {
+		return nil, fmt.Errorf("查詢運動類型失敗: %w", err)
+	}
+	sportTypesByName := make(map[string]models.SportType, len(sportTypes))
+	for _, sportType := range sportTypes {
+		sportTypesByName[normalizeHeader(sportType.Name)] = sportType
+	}
+
+	var entries []models.NormEntry
+	var rowErrors []NormImportError
+	seen := make(map[string]int)
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+		if isBlankRow(row) {
+			continue
+		}
+		entry, errs := parseNormRow(rowNumber, row, columns, sportTypesByName)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+
+		key := fmt.Sprintf("%d:%d:%s", entry.SportTypeID, entry.Age, entry.Gender)
+		if first, dup := seen[key]; dup {
+			rowErrors = append(rowErrors, NormImportError{
+				Row:     rowNumber,
+				Field:   "sport_type",
+				Message: fmt.Sprintf("與第 %d 列的項目、年齡、性別重複", first),
+			})
+			continue
+		}
+		seen[key] = rowNumber
+		entries = append(entries, entry)
+	}
+	if len(rowErrors) > 0 {
+		return nil, &NormImportErrors{Errors: rowErrors}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("常模檔案沒有資料")
+	}
+
+	table := models.NormTable{
+		Name:       req.Name,
+		Source:     req.Source,
+		Year:       req.Year,
+		FileName:   filename,
+		EntryCount: len(entries),
+		ImportedBy: importedBy,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.NormTable{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		table.Version = latest + 1
+
+		if err := tx.Create(&table).Error; err != nil {
+			return err
+		}
+		for i := range entries {
+			entries[i].NormTableID = table.ID
+		}
+		if err := tx.CreateInBatches(entries, 500).Error; err != nil {
+			return err
+		}
+
+		if req.Activate {
+			return activateNormTable(tx, &table)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("儲存常模失敗: %w", err)
+	}
+
+	return &table, nil
+}
+
+// parseNormRow 解析並驗證常模檔案的一列。百分位數代表勝過多少比例的學生，
+// 因此計時項目（數值越小越好）的數值由 P25 到 P90 遞減，其他項目遞增。
+func parseNormRow(rowNumber int, row []string, columns map[string]int, sportTypes map[string]models.SportType) (models.NormEntry, []NormImportError) {
+	var entry models.NormEntry
+	var errs []NormImportError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, NormImportError{Row: rowNumber, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	cell := func(field string) string {
+		index, found := columns[field]
+		if !found {
+			return ""
+		}
+		return TrimString(GetCellValue(row, index))
+	}
+
+	sportName := cell("sport_type")
+	descending := false
+	if sportType, found := sportTypes[normalizeHeader(sportName)]; found {
+		entry.SportTypeID = sportType.ID
+		descending = sportType.ValueType == models.ValueTypeTime
+	} else {
+		fail("sport_type", "未知的運動項目: %s", sportName)
+	}
+
+	if age, err := ParseInt(cell("age")); err != nil {
+		fail("age", "年齡: %v", err)
+	} else if age < 3 || age > 30 {
+		fail("age", "年齡必須介於 3 到 30 歲")
+	} else {
+		entry.Age = age
+	}
+
+	if gender, err := NormalizeGender(cell("gender")); err != nil {
+		fail("gender", "%v", err)
+	} else {
+		entry.Gender = gender
+	}
+
+	percentiles := []struct {
+		field string
+		value *float64
+	}{
+		{"percentile25", &entry.Percentile25},
+		{"percentile50", &entry.Percentile50},
+		{"percentile75", &entry.Percentile75},
+		{"percentile90", &entry.Percentile90},
+	}
+	ordered := true
+	for i, p := range percentiles {
+		value, err := ParseFloat(cell(p.field))
+		if err != nil {
+			fail(p.field, "%s: %v", strings.ToUpper(normColumnAliases[p.field][0]), err)
+			ordered = false
+			continue
+		}
+		*p.value = value
+		if i == 0 || !ordered {
+			continue
+		}
+		if previous := *percentiles[i-1].value; descending && value > previous {
+			fail(p.field, "計時項目的百分位數值必須由 P25 到 P90 遞減")
+			ordered = false
+		} else if !descending && value < previous {
+			fail(p.field, "百分位數值必須由 P25 到 P90 遞增")
+			ordered = false
+		}
+	}
+
+	// 未提供平均值時以中位數代替
+	entry.AvgValue = entry.Percentile50
+	if raw := cell("avg_value"); raw != "" {
+		if avg, err := ParseFloat(raw); err != nil {
+			fail("avg_value", "平均: %v", err)
+		} else {
+			entry.AvgValue = avg
+		}
+	}
+	if raw := cell("sample_count"); raw != "" {
+		if count, err := ParseInt(raw); err != nil || count < 0 {
+			fail("sample_count", "樣本數必須是非負整數")
+		} else {
+			entry.SampleCount = count
+		}
+	}
+
+	return entry, errs
+}
+
+// isBlankRow 判斷一列是否沒有任何內容
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if !IsEmpty(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// activateNormTable 將常模設為啟用版本，其餘版本停用
+func activateNormTable(tx *gorm.DB, table *models.NormTable) error {
+	if err := tx.Model(&models.NormTable{}).
+		Where("active = ? AND id <> ?", true, table.ID).
+		Update("active", false).Error; err != nil {
+		return err
+	}
+	table.Active = true
+	return tx.Model(table).Update("active", true).Error
+}
+
+// ListNormTables 列出所有常模版本（新版本在前）
+func (s *StatisticsService) ListNormTables(ctx context.Context) ([]models.NormTable, error) {
+	var tables []models.NormTable
+	if err := s.db.Order("version DESC").Find(&tables).Error; err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// GetNormTable 取得常模版本及其數值
+func (s *StatisticsService) GetNormTable(ctx context.Context, id uint) (*models.NormTable, error) {
+	var table models.NormTable
+	err := s.db.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sport_type_id, gender, age")
+	}).Preload("Entries.SportType").First(&table, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("常模版本不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// ActivateNormTable 將指定常模版本設為比較時使用的官方常模
+func (s *StatisticsService) ActivateNormTable(ctx context.Context, id uint) (*models.NormTable, error) {
+	var table models.NormTable
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&table, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("常模版本不存在")
+			}
+			return err
+		}
+		return activateNormTable(tx, &table)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// GetNormSource 取得學生比較使用的基準來源，未設定時使用系統計算的平均
+func (s *StatisticsService) GetNormSource(ctx context.Context) (*NormSourceSetting, error) {
+	setting := &NormSourceSetting{Source: models.NormSourceComputed}
+
+	var stored models.AppSetting
+	err := s.db.Where("`key` = ?", models.SettingComparisonNormSource).Limit(1).Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+	if stored.Value == models.NormSourceOfficial {
+		setting.Source = models.NormSourceOfficial
+	}
+
+	table, err := s.activeNormTable()
+	if err != nil {
+		return nil, err
+	}
+	setting.ActiveTable = table
+
+	return setting, nil
+}
+
+// SetNormSource 設定學生比較使用的基準來源
+func (s *StatisticsService) SetNormSource(ctx context.Context, source string) (*NormSourceSetting, error) {
+	if source != models.NormSourceOfficial && source != models.NormSourceComputed {
+		return nil, fmt.Errorf("無效的基準來源: %s", source)
+	}
+
+	setting := models.AppSetting{
+		Key:       models.SettingComparisonNormSource,
+		Value:     source,
+		UpdatedAt: time.Now(),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNormSource(ctx)
+}
+
+// activeNormTable 取得目前啟用的常模版本，沒有時回傳 nil
+func (s *StatisticsService) activeNormTable() (*models.NormTable, error) {
+	var tables []models.NormTable
+	if err := s.db.Where("active = ?", true).Order("version DESC").Limit(1).Find(&tables).Error; err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	return &tables[0], nil
+}
+
+// officialNorms 載入啟用常模中指定年齡與性別的數值，依運動項目索引
+func (s *StatisticsService) officialNorms(table *models.NormTable, age int, gender string) (map[uint]models.NormEntry, error) {
+	var entries []models.NormEntry
+	err := s.db.Where("norm_table_id = ? AND age = ? AND gender = ?", table.ID, age, gender).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	norms := make(map[uint]models.NormEntry, len(entries))
+	for _, entry := range entries {
+		norms[entry.SportTypeID] = entry
+	}
+	return norms, nil
+}
+
+// studentAge 計算學生於測驗日的實足年齡；沒有生日時依年級推估（一年級約 6 歲）
+func studentAge(student models.Student, testDate time.Time) int {
+	if student.BirthDate == nil {
+		return student.Grade + 5
+	}
+
+	birth := *student.BirthDate
+	age := testDate.Year() - birth.Year()
+	if testDate.Month() < birth.Month() ||
+		(testDate.Month() == birth.Month() && testDate.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
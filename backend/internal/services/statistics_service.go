@@ -61,6 +61,9 @@ type Comparison struct {
 	PercentileRank    int             `json:"percentile_rank"`
 	PerformanceLevel  string          `json:"performance_level"`
 	NationalStats     NationalStats   `json:"national_stats"`
+	NormSource        string          `json:"norm_source"`            // official 或 computed
+	NormVersion       int             `json:"norm_version,omitempty"` // 使用官方常模時的版本
+	NormAge           int             `json:"norm_age,omitempty"`     // 使用官方常模時對照的年齡
 }
 
 type NationalStats struct {
@@ -438,7 +441,18 @@ func (s *StatisticsService) GetStudentComparison(ctx context.Context, studentID
 		recordsBySportType[record.SportTypeID] = append(recordsBySportType[record.SportTypeID], record)
 	}
 
-	// 4. 取得對應的全國平均值並計算比較
+	// 4. 依設定決定是否使用官方常模
+	normSetting, err := s.GetNormSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var normTable *models.NormTable
+	if normSetting.Source == models.NormSourceOfficial {
+		normTable = normSetting.ActiveTable
+	}
+	normsByAge := make(map[int]map[uint]models.NormEntry)
+
+	// 5. 取得對應的常模或全國平均值並計算比較
 	var comparisons []Comparison
 	percentileSum := 0
 
@@ -450,16 +464,49 @@ func (s *StatisticsService) GetStudentComparison(ctx context.Context, studentID
 		// 使用第一筆（最新）記錄進行比較計算
 		latestRecord := sportRecords[0]
 
+		// 官方常模沒有此項目/年齡時改用系統計算的全國平均
 		var natAvg models.NationalAverage
-		err := s.db.Preload("SportType").
-			Where("sport_type_id = ? AND grade = ? AND gender = ?",
-				sportTypeID, student.Grade, student.Gender).
-			First(&natAvg).Error
+		normSource := models.NormSourceComputed
+		normVersion, normAge := 0, 0
+		found := false
+		if normTable != nil {
+			age := studentAge(student, latestRecord.TestDate)
+			norms, loaded := normsByAge[age]
+			if !loaded {
+				norms, err = s.officialNorms(normTable, age, student.Gender)
+				if err != nil {
+					return nil, err
+				}
+				normsByAge[age] = norms
+			}
+			if entry, ok := norms[sportTypeID]; ok {
+				natAvg = models.NationalAverage{
+					SportTypeID:  sportTypeID,
+					Gender:       entry.Gender,
+					AvgValue:     entry.AvgValue,
+					SampleCount:  entry.SampleCount,
+					Percentile25: entry.Percentile25,
+					Percentile50: entry.Percentile50,
+					Percentile75: entry.Percentile75,
+					Percentile90: entry.Percentile90,
+				}
+				normSource = models.NormSourceOfficial
+				normVersion, normAge = normTable.Version, age
+				found = true
+			}
+		}
 
-		if err != nil {
-			log.Printf("無全國平均數據: sport_type_id=%d, grade=%d, gender=%s",
-				sportTypeID, student.Grade, student.Gender)
-			continue
+		if !found {
+			err := s.db.Preload("SportType").
+				Where("sport_type_id = ? AND grade = ? AND gender = ?",
+					sportTypeID, student.Grade, student.Gender).
+				First(&natAvg).Error
+
+			if err != nil {
+				log.Printf("無全國平均數據: sport_type_id=%d, grade=%d, gender=%s",
+					sportTypeID, student.Grade, student.Gender)
+				continue
+			}
 		}
 
 		// 計算差異和百分位（使用最新記錄）
@@ -469,7 +516,10 @@ func (s *StatisticsService) GetStudentComparison(ctx context.Context, studentID
 			diffPercent = (diff / natAvg.AvgValue) * 100
 		}
 
-		percentileRank := calculatePercentileRank(latestRecord.Value, natAvg)
+		// 官方常模的計時項目數值越小越好，百分位數由 P25 到 P90 遞減
+		descending := normSource == models.NormSourceOfficial &&
+			latestRecord.SportType.ValueType == models.ValueTypeTime
+		percentileRank := calculatePercentileRank(latestRecord.Value, natAvg, descending)
 		performanceLevel := getPerformanceLevel(percentileRank)
 		percentileSum += percentileRank
 
@@ -502,10 +552,13 @@ func (s *StatisticsService) GetStudentComparison(ctx context.Context, studentID
 				Percentile75: natAvg.Percentile75,
 				Percentile90: natAvg.Percentile90,
 			},
+			NormSource:  normSource,
+			NormVersion: normVersion,
+			NormAge:     normAge,
 		})
 	}
 
-	// 5. 計算總結
+	// 6. 計算總結
 	summary := calculateSummary(comparisons, percentileSum)
 
 	return &ComparisonResult{
@@ -762,7 +815,7 @@ func (s *StatisticsService) GetCountyComparison(ctx context.Context, studentID u
 	}, nil
 }
 
-// CountySportAverage 縣市各運動項目平均成績
+// CountySportAverage 縣市（或鄉鎮市區）各運動項目平均成績
 type CountySportAverage struct {
 	SportTypeID   uint    `json:"sport_type_id"`
 	SportTypeName string  `json:"sport_type_name"`
@@ -776,6 +829,25 @@ type CountySportAverage struct {
 
 // GetCountySportAverages 取得縣市各運動項目平均成績（用於縣市比較）
 func (s *StatisticsService) GetCountySportAverages(ctx context.Context, countyName string) ([]CountySportAverage, error) {
+	return s.getRegionSportAverages("", nil, "sch.county_name = ?", countyName)
+}
+
+// GetCountySportAveragesBetween 取得縣市在期間 [from, to) 內各運動項目平均成績，
+// 每位學生取期間內最新一筆（用於學年度統計）
+func (s *StatisticsService) GetCountySportAveragesBetween(ctx context.Context, countyName string, from, to time.Time) ([]CountySportAverage, error) {
+	return s.getRegionSportAverages("AND test_date >= ? AND test_date < ?",
+		[]interface{}{from.Format("2006-01-02"), to.Format("2006-01-02")},
+		"sch.county_name = ?", countyName)
+}
+
+// GetDistrictSportAverages 取得鄉鎮市區各運動項目平均成績（用於鄉鎮市區比較）
+func (s *StatisticsService) GetDistrictSportAverages(ctx context.Context, countyName, district string) ([]CountySportAverage, error) {
+	return s.getRegionSportAverages("", nil, "sch.county_name = ? AND sch.district = ?", countyName, district)
+}
+
+// getRegionSportAverages 依學校所在區域條件取得各運動項目平均成績；
+// periodCond 可限制採計的測驗日期，空字串表示不限
+func (s *StatisticsService) getRegionSportAverages(periodCond string, periodArgs []interface{}, regionCond string, regionArgs ...interface{}) ([]CountySportAverage, error) {
 	var sportTypes []models.SportType
 	if err := s.db.Find(&sportTypes).Error; err != nil {
 		return nil, err
@@ -799,16 +871,16 @@ func (s *StatisticsService) GetCountySportAverages(ctx context.Context, countyNa
 			INNER JOIN (
 				SELECT student_id, MAX(test_date) as latest
 				FROM sport_records
-				WHERE sport_type_id = ? AND deleted_at IS NULL
+				WHERE sport_type_id = ? AND deleted_at IS NULL `+periodCond+`
 				GROUP BY student_id
 			) latest ON sr.student_id = latest.student_id AND sr.test_date = latest.latest
 			INNER JOIN students st ON sr.student_id = st.id
 			INNER JOIN schools sch ON st.school_id = sch.id
 			WHERE sr.sport_type_id = ?
-			  AND sch.county_name = ?
+			  AND `+regionCond+`
 			  AND sr.deleted_at IS NULL
 			  AND st.deleted_at IS NULL
-		`, sportType.ID, sportType.ID, countyName).Scan(&r)
+		`, append(append(append([]interface{}{sportType.ID}, periodArgs...), sportType.ID), regionArgs...)...).Scan(&r)
 
 		if r.StudentCount == 0 {
 			continue
@@ -829,6 +901,148 @@ func (s *StatisticsService) GetCountySportAverages(ctx context.Context, countyNa
 	return result, nil
 }
 
+// SchoolGroupDimensions 可用於分組統計的學校屬性
+var SchoolGroupDimensions = []string{"county", "district", "level", "indigenous", "urbanization", "enrollment"}
+
+// schoolGroupExpr 回傳分組屬性對應的 SQL 欄位運算式
+func schoolGroupExpr(groupBy string) (string, bool) {
+	switch groupBy {
+	case "county":
+		return "sch.county_name", true
+	case "district":
+		return "CASE WHEN sch.district IS NULL OR sch.district = '' THEN '' ELSE CONCAT(sch.county_name, sch.district) END", true
+	case "level":
+		return "COALESCE(sch.level, '')", true
+	case "indigenous":
+		return "CASE WHEN sch.is_indigenous_key_school THEN '原住民重點學校' ELSE '一般學校' END", true
+	case "urbanization":
+		return "COALESCE(sch.urbanization_level, '')", true
+	case "enrollment":
+		expr := "CASE WHEN sch.enrollment_size IS NULL THEN ''"
+		for _, band := range models.EnrollmentBands {
+			if band.Max > 0 {
+				expr += fmt.Sprintf(" WHEN sch.enrollment_size <= %d THEN '%s'", band.Max, band.Label)
+			} else {
+				expr += fmt.Sprintf(" ELSE '%s'", band.Label)
+			}
+		}
+		return expr + " END", true
+	}
+	return "", false
+}
+
+// GroupSportAverage 學校分組各運動項目平均成績
+type GroupSportAverage struct {
+	SportTypeID   uint    `json:"sport_type_id"`
+	SportTypeName string  `json:"sport_type_name"`
+	Category      string  `json:"category"`
+	Unit          string  `json:"unit"`
+	ValueType     string  `json:"value_type"`
+	AvgValue      float64 `json:"avg_value"`
+	SchoolCount   int     `json:"school_count"`
+	StudentCount  int     `json:"student_count"`
+}
+
+// SchoolGroupAverages 單一學校分組的平均成績
+type SchoolGroupAverages struct {
+	Group    string              `json:"group"`
+	Averages []GroupSportAverage `json:"averages"`
+}
+
+// GetGroupSportAverages 依學校屬性（縣市、鄉鎮市區、層級、原住民重點學校、都市化程度、學校規模）
+// 分組取得各運動項目平均成績，grade 與 gender 為選填篩選條件
+func (s *StatisticsService) GetGroupSportAverages(ctx context.Context, groupBy string, grade int, gender string) ([]SchoolGroupAverages, error) {
+	groupExpr, ok := schoolGroupExpr(groupBy)
+	if !ok {
+		return nil, fmt.Errorf("無效的分組方式")
+	}
+
+	var sportTypes []models.SportType
+	if err := s.db.Find(&sportTypes).Error; err != nil {
+		return nil, err
+	}
+
+	filters := ""
+	args := []interface{}{}
+	if grade > 0 {
+		filters += " AND st.grade = ?"
+		args = append(args, grade)
+	}
+	if gender != "" {
+		filters += " AND st.gender = ?"
+		args = append(args, gender)
+	}
+
+	groups := make(map[string]*SchoolGroupAverages)
+	var order []string
+
+	for _, sportType := range sportTypes {
+		type row struct {
+			GroupKey     string
+			AvgValue     float64
+			SchoolCount  int
+			StudentCount int
+		}
+		var rows []row
+		queryArgs := append([]interface{}{sportType.ID, sportType.ID}, args...)
+		err := s.db.Raw(`
+			SELECT
+				`+groupExpr+` as group_key,
+				AVG(sr.value) as avg_value,
+				COUNT(DISTINCT sch.id) as school_count,
+				COUNT(DISTINCT sr.student_id) as student_count
+			FROM sport_records sr
+			INNER JOIN (
+				SELECT student_id, MAX(test_date) as latest
+				FROM sport_records
+				WHERE sport_type_id = ? AND deleted_at IS NULL
+				GROUP BY student_id
+			) latest ON sr.student_id = latest.student_id AND sr.test_date = latest.latest
+			INNER JOIN students st ON sr.student_id = st.id
+			INNER JOIN schools sch ON st.school_id = sch.id
+			WHERE sr.sport_type_id = ?
+			  AND sr.deleted_at IS NULL
+			  AND st.deleted_at IS NULL
+			  AND sch.deleted_at IS NULL`+filters+`
+			GROUP BY group_key
+		`, queryArgs...).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range rows {
+			key := r.GroupKey
+			if key == "" {
+				key = "未分類"
+			}
+			group, exists := groups[key]
+			if !exists {
+				group = &SchoolGroupAverages{Group: key, Averages: []GroupSportAverage{}}
+				groups[key] = group
+				order = append(order, key)
+			}
+			group.Averages = append(group.Averages, GroupSportAverage{
+				SportTypeID:   sportType.ID,
+				SportTypeName: sportType.Name,
+				Category:      sportType.Category,
+				Unit:          sportType.DefaultUnit,
+				ValueType:     sportType.ValueType,
+				AvgValue:      math.Round(r.AvgValue*100) / 100,
+				SchoolCount:   r.SchoolCount,
+				StudentCount:  r.StudentCount,
+			})
+		}
+	}
+
+	sort.Strings(order)
+	result := make([]SchoolGroupAverages, 0, len(order))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+
+	return result, nil
+}
+
 // GetNationalAverages 取得全國平均值列表 (帶快取)
 func (s *StatisticsService) GetNationalAverages(ctx context.Context, sportTypeID uint, grade int, gender string) ([]models.NationalAverage, error) {
 	cacheKey := fmt.Sprintf("national_averages:%d:%d:%s", sportTypeID, grade, gender)
@@ -905,7 +1119,12 @@ func calculatePercentile(values []float64, percentile float64) float64 {
 	return sorted[lower]*(1-weight) + sorted[upper]*weight
 }
 
-func calculatePercentileRank(value float64, avg models.NationalAverage) int {
+// calculatePercentileRank 依百分位數估算數值的百分等級；descending 表示
+// 數值越小越好、百分位數由 P25 到 P90 遞減
+func calculatePercentileRank(value float64, avg models.NationalAverage, descending bool) int {
+	if descending {
+		return calculateDescendingPercentileRank(value, avg)
+	}
 	if value >= avg.Percentile90 {
 		if avg.Percentile90 == 0 {
 			return 90
@@ -938,6 +1157,38 @@ func calculatePercentileRank(value float64, avg models.NationalAverage) int {
 	}
 }
 
+// calculateDescendingPercentileRank 是 calculatePercentileRank 在數值越小
+// 越好時的鏡像計算
+func calculateDescendingPercentileRank(value float64, avg models.NationalAverage) int {
+	if value <= avg.Percentile90 {
+		if avg.Percentile90 == 0 {
+			return 90
+		}
+		extra := (avg.Percentile90 - value) / (avg.Percentile90 * 0.2) * 10
+		return int(math.Min(90+extra, 99))
+	} else if value <= avg.Percentile75 {
+		span := avg.Percentile75 - avg.Percentile90
+		if span == 0 {
+			return 75
+		}
+		return 75 + int((avg.Percentile75-value)/span*15)
+	} else if value <= avg.Percentile50 {
+		span := avg.Percentile50 - avg.Percentile75
+		if span == 0 {
+			return 50
+		}
+		return 50 + int((avg.Percentile50-value)/span*25)
+	} else if value <= avg.Percentile25 {
+		span := avg.Percentile25 - avg.Percentile50
+		if span == 0 {
+			return 25
+		}
+		return 25 + int((avg.Percentile25-value)/span*25)
+	} else {
+		return int((avg.Percentile25 / value) * 25)
+	}
+}
+
 func getPerformanceLevel(percentile int) string {
 	if percentile >= 75 {
 		return "excellent"
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/wei979/ICACP/backend/internal/models"
@@ -50,6 +51,9 @@ func (s *StudentService) List(params *models.StudentSearchParams) ([]models.Stud
 	if params.Gender != "" {
 		query = query.Where("gender = ?", params.Gender)
 	}
+	if params.ClassID > 0 {
+		query = query.Where("class_id = ?", params.ClassID)
+	}
 
 	// Count total records
 	if err := query.Count(&total).Error; err != nil {
@@ -137,7 +141,6 @@ func (s *StudentService) Create(req *models.CreateStudentRequest) (*models.Stude
 		StudentNumber: req.StudentNumber,
 		Name:          req.Name,
 		Grade:         req.Grade,
-		Class:         req.Class,
 		Gender:        req.Gender,
 	}
 
@@ -153,8 +156,22 @@ func (s *StudentService) Create(req *models.CreateStudentRequest) (*models.Stude
 		student.BirthDate = &birthDate
 	}
 
-	if err := s.db.Create(student).Error; err != nil {
-		return nil, fmt.Errorf("failed to create student: %w", err)
+	// The class is created together with the student, so a failed insert
+	// leaves no empty class behind
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		class, err := resolveStudentClass(tx, student, req.ClassID, req.Class)
+		if err != nil {
+			return err
+		}
+		setStudentClass(student, class)
+
+		if err := tx.Create(student).Error; err != nil {
+			return fmt.Errorf("failed to create student: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Reload with school info
@@ -163,8 +180,9 @@ func (s *StudentService) Create(req *models.CreateStudentRequest) (*models.Stude
 	return student, nil
 }
 
-// Update updates an existing student
-func (s *StudentService) Update(id uint, req *models.UpdateStudentRequest) (*models.Student, error) {
+// Update updates an existing student. A changed student number is kept in the
+// student's number history so imports can still match the former number.
+func (s *StudentService) Update(id uint, req *models.UpdateStudentRequest, changedBy uint) (*models.Student, error) {
 	var student models.Student
 	if err := s.db.First(&student, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -187,28 +205,80 @@ func (s *StudentService) Update(id uint, req *models.UpdateStudentRequest) (*mod
 		}
 	}
 
-	student.StudentNumber = req.StudentNumber
-	student.Name = req.Name
-	student.Grade = req.Grade
-	student.Class = req.Class
-	student.Gender = req.Gender
-
 	// Parse birth date if provided
+	var birthDate *time.Time
 	if req.BirthDate != "" {
-		birthDate, err := time.Parse("2006-01-02", req.BirthDate)
+		parsed, err := time.Parse("2006-01-02", req.BirthDate)
 		if err != nil {
 			return nil, fmt.Errorf("日期格式錯誤，請使用 YYYY-MM-DD 格式")
 		}
-		if birthDate.After(time.Now()) {
+		if parsed.After(time.Now()) {
 			return nil, fmt.Errorf("出生日期不能是未來日期")
 		}
-		student.BirthDate = &birthDate
-	} else {
-		student.BirthDate = nil
+		birthDate = &parsed
 	}
 
-	if err := s.db.Save(&student).Error; err != nil {
-		return nil, fmt.Errorf("failed to update student: %w", err)
+	oldNumber := student.StudentNumber
+	current := models.Student{
+		ID:       student.ID,
+		SchoolID: student.SchoolID,
+		Grade:    req.Grade,
+		Class:    student.Class,
+		ClassID:  student.ClassID,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		class, err := resolveStudentClass(tx, &current, req.ClassID, req.Class)
+		if err != nil {
+			return err
+		}
+
+		student.StudentNumber = req.StudentNumber
+		student.Name = req.Name
+		student.Grade = req.Grade
+		student.Gender = req.Gender
+		student.BirthDate = birthDate
+		setStudentClass(&student, class)
+
+		if err := tx.Save(&student).Error; err != nil {
+			return fmt.Errorf("failed to update student: %w", err)
+		}
+
+		if oldNumber == student.StudentNumber {
+			return nil
+		}
+
+		now := time.Now()
+		history := &models.StudentNumberHistory{
+			StudentID:     student.ID,
+			SchoolID:      student.SchoolID,
+			StudentNumber: oldNumber,
+			ReplacedBy:    student.StudentNumber,
+			Source:        models.NumberHistorySourceUpdate,
+			ChangedBy:     changedBy,
+			ChangedAt:     now,
+		}
+		if err := tx.Create(history).Error; err != nil {
+			return fmt.Errorf("failed to record number history: %w", err)
+		}
+
+		audit := &models.StudentAudit{
+			StudentID: student.ID,
+			Action:    models.StudentAuditActionNumberChange,
+			Field:     "student_number",
+			OldValue:  oldNumber,
+			NewValue:  student.StudentNumber,
+			ChangedBy: changedBy,
+			ChangedAt: now,
+		}
+		if err := tx.Create(audit).Error; err != nil {
+			return fmt.Errorf("failed to create audit trail: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Reload with school info
@@ -217,6 +287,54 @@ func (s *StudentService) Update(id uint, req *models.UpdateStudentRequest) (*mod
 	return &student, nil
 }
 
+// resolveStudentClass returns the class a student should belong to. An explicit
+// class ID wins; otherwise the class name is resolved for the current academic
+// year, keeping the student's existing class when the name and grade are unchanged.
+// A missing class is created through db, which should be the transaction that
+// saves the student.
+func resolveStudentClass(db *gorm.DB, student *models.Student, classID *uint, className string) (*models.Class, error) {
+	if classID != nil {
+		var class models.Class
+		if err := db.First(&class, *classID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("班級不存在")
+			}
+			return nil, fmt.Errorf("failed to get class: %w", err)
+		}
+		if class.SchoolID != student.SchoolID {
+			return nil, fmt.Errorf("班級不屬於該學校")
+		}
+		if class.Grade != student.Grade {
+			return nil, fmt.Errorf("學生年級與班級年級不符")
+		}
+		return &class, nil
+	}
+
+	if student.ClassID != nil && NormalizeClassName(className) == student.Class {
+		var class models.Class
+		err := db.First(&class, *student.ClassID).Error
+		if err == nil && class.Grade == student.Grade {
+			return &class, nil
+		}
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("failed to get class: %w", err)
+		}
+	}
+
+	return ResolveClass(db, student.SchoolID, CurrentAcademicYear(), student.Grade, className)
+}
+
+// setStudentClass links a student to a class, keeping the class name in sync
+func setStudentClass(student *models.Student, class *models.Class) {
+	if class == nil {
+		student.Class = ""
+		student.ClassID = nil
+		return
+	}
+	student.Class = class.Name
+	student.ClassID = &class.ID
+}
+
 // Delete soft deletes a student
 func (s *StudentService) Delete(id uint) error {
 	var student models.Student
@@ -248,3 +366,452 @@ func (s *StudentService) ListBySchool(schoolID uint, page, pageSize int) ([]mode
 func (s *StudentService) Search(params *models.StudentSearchParams) ([]models.Student, *models.Pagination, error) {
 	return s.List(params)
 }
+
+// duplicateNameThreshold is the minimum name similarity for two students
+// with the same birth date and gender to be reported as duplicates
+const duplicateNameThreshold = 0.6
+
+// FindDuplicates detects likely duplicate students within a school.
+// Students are reported when they share gender and birth date and have similar
+// names, or when one of them has no birth date and the names are identical.
+func (s *StudentService) FindDuplicates(schoolID uint) ([]models.DuplicateStudentPair, error) {
+	var students []models.Student
+	if err := s.db.Where("school_id = ?", schoolID).Order("id ASC").Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("failed to list students: %w", err)
+	}
+
+	// Bucket students so only plausible pairs are compared
+	byBirthDate := make(map[string][]int)
+	byName := make(map[string][]int)
+	for i, student := range students {
+		if student.BirthDate != nil {
+			key := student.Gender + "|" + student.BirthDate.Format("2006-01-02")
+			byBirthDate[key] = append(byBirthDate[key], i)
+		}
+		key := student.Gender + "|" + NormalizeName(student.Name)
+		byName[key] = append(byName[key], i)
+	}
+
+	type pairKey struct{ a, b uint }
+	found := make(map[pairKey]*models.DuplicateStudentPair)
+	order := make([]pairKey, 0)
+
+	addPair := func(a, b models.Student, reason string) {
+		key := pairKey{a.ID, b.ID}
+		if pair, exists := found[key]; exists {
+			pair.Reasons = append(pair.Reasons, reason)
+			return
+		}
+		found[key] = &models.DuplicateStudentPair{
+			StudentA:       a,
+			StudentB:       b,
+			NameSimilarity: NameSimilarity(a.Name, b.Name),
+			Reasons:        []string{reason},
+		}
+		order = append(order, key)
+	}
+
+	for _, bucket := range byBirthDate {
+		for i := 0; i < len(bucket); i++ {
+			for j := i + 1; j < len(bucket); j++ {
+				a, b := students[bucket[i]], students[bucket[j]]
+				if NameSimilarity(a.Name, b.Name) >= duplicateNameThreshold {
+					addPair(a, b, "性別、生日相同且姓名相近")
+				}
+			}
+		}
+	}
+
+	for _, bucket := range byName {
+		for i := 0; i < len(bucket); i++ {
+			for j := i + 1; j < len(bucket); j++ {
+				a, b := students[bucket[i]], students[bucket[j]]
+				if a.BirthDate != nil && b.BirthDate != nil {
+					continue // Already compared by birth date
+				}
+				addPair(a, b, "性別、姓名相同（缺少生日資料）")
+			}
+		}
+	}
+
+	if len(order) == 0 {
+		return []models.DuplicateStudentPair{}, nil
+	}
+
+	// Attach record counts so the caller can choose which student survives
+	counts, err := s.countRecordsByStudent(students)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].a != order[j].a {
+			return order[i].a < order[j].a
+		}
+		return order[i].b < order[j].b
+	})
+
+	pairs := make([]models.DuplicateStudentPair, 0, len(order))
+	for _, key := range order {
+		pair := found[key]
+		pair.RecordCountA = counts[key.a]
+		pair.RecordCountB = counts[key.b]
+		pairs = append(pairs, *pair)
+	}
+
+	return pairs, nil
+}
+
+// countRecordsByStudent returns the number of sport records per student
+func (s *StudentService) countRecordsByStudent(students []models.Student) (map[uint]int, error) {
+	ids := make([]uint, len(students))
+	for i, student := range students {
+		ids[i] = student.ID
+	}
+
+	var rows []struct {
+		StudentID uint
+		Count     int
+	}
+	err := s.db.Model(&models.SportRecord{}).
+		Select("student_id, COUNT(*) as count").
+		Where("student_id IN ?", ids).
+		Group("student_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		counts[r.StudentID] = r.Count
+	}
+	return counts, nil
+}
+
+// Merge merges a duplicate student into the surviving student.
+// Sport records and student audits are moved to the survivor, the duplicate is
+// soft deleted and the merge is recorded so it can be reverted later.
+func (s *StudentService) Merge(survivorID uint, req *models.MergeStudentRequest, mergedBy uint) (*models.StudentMerge, error) {
+	if survivorID == req.DuplicateID {
+		return nil, fmt.Errorf("無法將學生與自己合併")
+	}
+
+	var survivor, duplicate models.Student
+	if err := s.db.First(&survivor, survivorID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("student not found")
+		}
+		return nil, fmt.Errorf("failed to get student: %w", err)
+	}
+	if err := s.db.First(&duplicate, req.DuplicateID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("重複學生不存在")
+		}
+		return nil, fmt.Errorf("failed to get student: %w", err)
+	}
+
+	if survivor.SchoolID != duplicate.SchoolID {
+		return nil, fmt.Errorf("只能合併同一學校的學生")
+	}
+
+	now := time.Now()
+	merge := &models.StudentMerge{
+		SchoolID:   survivor.SchoolID,
+		SurvivorID: survivor.ID,
+		MergedID:   duplicate.ID,
+		Reason:     req.Reason,
+		MergedBy:   mergedBy,
+		MergedAt:   now,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Collect what will be moved before moving it
+		if err := tx.Model(&models.SportRecord{}).
+			Where("student_id = ?", duplicate.ID).
+			Pluck("id", &merge.MovedRecordIDs).Error; err != nil {
+			return fmt.Errorf("failed to list sport records: %w", err)
+		}
+		if err := tx.Model(&models.StudentAudit{}).
+			Where("student_id = ?", duplicate.ID).
+			Pluck("id", &merge.MovedAuditIDs).Error; err != nil {
+			return fmt.Errorf("failed to list student audits: %w", err)
+		}
+		if err := tx.Model(&models.StudentNumberHistory{}).
+			Where("student_id = ?", duplicate.ID).
+			Pluck("id", &merge.MovedNumberHistoryIDs).Error; err != nil {
+			return fmt.Errorf("failed to list number history: %w", err)
+		}
+
+		if merge.MovedRecordIDs == nil {
+			merge.MovedRecordIDs = []uint{}
+		}
+		if merge.MovedAuditIDs == nil {
+			merge.MovedAuditIDs = []uint{}
+		}
+		if merge.MovedNumberHistoryIDs == nil {
+			merge.MovedNumberHistoryIDs = []uint{}
+		}
+
+		if len(merge.MovedRecordIDs) > 0 {
+			if err := tx.Model(&models.SportRecord{}).
+				Where("id IN ?", merge.MovedRecordIDs).
+				Update("student_id", survivor.ID).Error; err != nil {
+				return fmt.Errorf("failed to move sport records: %w", err)
+			}
+		}
+		if len(merge.MovedAuditIDs) > 0 {
+			if err := tx.Model(&models.StudentAudit{}).
+				Where("id IN ?", merge.MovedAuditIDs).
+				Update("student_id", survivor.ID).Error; err != nil {
+				return fmt.Errorf("failed to move student audits: %w", err)
+			}
+		}
+		if len(merge.MovedNumberHistoryIDs) > 0 {
+			if err := tx.Model(&models.StudentNumberHistory{}).
+				Where("id IN ?", merge.MovedNumberHistoryIDs).
+				Update("student_id", survivor.ID).Error; err != nil {
+				return fmt.Errorf("failed to move number history: %w", err)
+			}
+		}
+
+		if err := tx.Delete(&duplicate).Error; err != nil {
+			return fmt.Errorf("failed to delete duplicate student: %w", err)
+		}
+
+		if err := tx.Create(merge).Error; err != nil {
+			return fmt.Errorf("failed to record merge: %w", err)
+		}
+
+		// The duplicate's number becomes a former number of the survivor
+		if duplicate.StudentNumber != survivor.StudentNumber {
+			history := &models.StudentNumberHistory{
+				StudentID:     survivor.ID,
+				SchoolID:      survivor.SchoolID,
+				StudentNumber: duplicate.StudentNumber,
+				ReplacedBy:    survivor.StudentNumber,
+				Source:        models.NumberHistorySourceMerge,
+				MergeID:       &merge.ID,
+				ChangedBy:     mergedBy,
+				ChangedAt:     now,
+			}
+			if err := tx.Create(history).Error; err != nil {
+				return fmt.Errorf("failed to record number history: %w", err)
+			}
+		}
+
+		audits := []models.StudentAudit{
+			{
+				StudentID: survivor.ID,
+				Action:    models.StudentAuditActionMerge,
+				OldValue:  fmt.Sprintf("%d", duplicate.ID),
+				NewValue:  fmt.Sprintf("%d", survivor.ID),
+				ChangedBy: mergedBy,
+				ChangedAt: now,
+				Reason:    mergeAuditReason(merge, duplicate),
+			},
+			{
+				StudentID: duplicate.ID,
+				Action:    models.StudentAuditActionMergedInto,
+				OldValue:  fmt.Sprintf("%d", duplicate.ID),
+				NewValue:  fmt.Sprintf("%d", survivor.ID),
+				ChangedBy: mergedBy,
+				ChangedAt: now,
+				Reason:    mergeAuditReason(merge, duplicate),
+			},
+		}
+		if err := tx.Create(&audits).Error; err != nil {
+			return fmt.Errorf("failed to create audit trail: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// mergeAuditReason builds the audit reason text for a merge
+func mergeAuditReason(merge *models.StudentMerge, duplicate models.Student) string {
+	reason := fmt.Sprintf("合併 #%d：學生 %s（座號 %s）併入 #%d，移轉 %d 筆運動記錄",
+		merge.ID, duplicate.Name, duplicate.StudentNumber, merge.SurvivorID, len(merge.MovedRecordIDs))
+	if merge.Reason != "" {
+		reason += "；" + merge.Reason
+	}
+	return reason
+}
+
+// RevertMerge reverts a previous merge: the merged student is restored and the
+// sport records and audits that were moved are returned to it
+func (s *StudentService) RevertMerge(mergeID uint, revertedBy uint) (*models.StudentMerge, error) {
+	var merge models.StudentMerge
+	if err := s.db.First(&merge, mergeID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("合併記錄不存在")
+		}
+		return nil, fmt.Errorf("failed to get merge: %w", err)
+	}
+
+	if merge.RevertedAt != nil {
+		return nil, fmt.Errorf("此合併已還原")
+	}
+
+	var merged models.Student
+	if err := s.db.Unscoped().First(&merged, merge.MergedID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get merged student: %w", err)
+	}
+
+	// The student number may have been reassigned since the merge
+	var conflict int64
+	if err := s.db.Model(&models.Student{}).
+		Where("school_id = ? AND student_number = ? AND id != ?", merged.SchoolID, merged.StudentNumber, merged.ID).
+		Count(&conflict).Error; err != nil {
+		return nil, fmt.Errorf("failed to check student number: %w", err)
+	}
+	if conflict > 0 {
+		return nil, fmt.Errorf("無法還原合併：座號 %s 已被其他學生使用", merged.StudentNumber)
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Student{}).
+			Where("id = ?", merged.ID).
+			Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore student: %w", err)
+		}
+
+		// Only move back records still owned by the survivor
+		if len(merge.MovedRecordIDs) > 0 {
+			if err := tx.Model(&models.SportRecord{}).
+				Where("id IN ? AND student_id = ?", merge.MovedRecordIDs, merge.SurvivorID).
+				Update("student_id", merged.ID).Error; err != nil {
+				return fmt.Errorf("failed to move sport records: %w", err)
+			}
+		}
+		if len(merge.MovedAuditIDs) > 0 {
+			if err := tx.Model(&models.StudentAudit{}).
+				Where("id IN ? AND student_id = ?", merge.MovedAuditIDs, merge.SurvivorID).
+				Update("student_id", merged.ID).Error; err != nil {
+				return fmt.Errorf("failed to move student audits: %w", err)
+			}
+		}
+		if len(merge.MovedNumberHistoryIDs) > 0 {
+			if err := tx.Model(&models.StudentNumberHistory{}).
+				Where("id IN ? AND student_id = ?", merge.MovedNumberHistoryIDs, merge.SurvivorID).
+				Update("student_id", merged.ID).Error; err != nil {
+				return fmt.Errorf("failed to move number history: %w", err)
+			}
+		}
+		if err := tx.Where("merge_id = ?", merge.ID).
+			Delete(&models.StudentNumberHistory{}).Error; err != nil {
+			return fmt.Errorf("failed to remove number history: %w", err)
+		}
+
+		merge.RevertedBy = &revertedBy
+		merge.RevertedAt = &now
+		if err := tx.Save(&merge).Error; err != nil {
+			return fmt.Errorf("failed to update merge: %w", err)
+		}
+
+		reason := fmt.Sprintf("還原合併 #%d：學生 %s（座號 %s）自 #%d 分離", merge.ID, merged.Name, merged.StudentNumber, merge.SurvivorID)
+		audits := []models.StudentAudit{
+			{StudentID: merge.SurvivorID, Action: models.StudentAuditActionUnmerge, OldValue: fmt.Sprintf("%d", merge.SurvivorID), NewValue: fmt.Sprintf("%d", merged.ID), ChangedBy: revertedBy, ChangedAt: now, Reason: reason},
+			{StudentID: merged.ID, Action: models.StudentAuditActionUnmerge, OldValue: fmt.Sprintf("%d", merge.SurvivorID), NewValue: fmt.Sprintf("%d", merged.ID), ChangedBy: revertedBy, ChangedAt: now, Reason: reason},
+		}
+		if err := tx.Create(&audits).Error; err != nil {
+			return fmt.Errorf("failed to create audit trail: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &merge, nil
+}
+
+// ListMerges retrieves merges in which the student was the survivor or the merged student
+func (s *StudentService) ListMerges(studentID uint) ([]models.StudentMerge, error) {
+	var merges []models.StudentMerge
+	err := s.db.Where("survivor_id = ? OR merged_id = ?", studentID, studentID).
+		Order("merged_at DESC").
+		Find(&merges).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merges: %w", err)
+	}
+	return merges, nil
+}
+
+// GetHistory retrieves the audit history for a student
+func (s *StudentService) GetHistory(studentID uint) ([]models.StudentAudit, error) {
+	var audits []models.StudentAudit
+	err := s.db.Where("student_id = ?", studentID).
+		Order("changed_at DESC, id DESC").
+		Find(&audits).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get student history: %w", err)
+	}
+	return audits, nil
+}
+
+// GetNumberHistory retrieves the former student numbers of a student
+func (s *StudentService) GetNumberHistory(studentID uint) ([]models.StudentNumberHistory, error) {
+	var history []models.StudentNumberHistory
+	err := s.db.Where("student_id = ?", studentID).
+		Order("changed_at DESC, id DESC").
+		Find(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get number history: %w", err)
+	}
+	return history, nil
+}
+
+// LookupNumber finds the student currently using a student number in a school,
+// along with the students who used it in the past
+func (s *StudentService) LookupNumber(schoolID uint, studentNumber string) (*models.StudentNumberLookup, error) {
+	lookup := &models.StudentNumberLookup{
+		StudentNumber: studentNumber,
+		Former:        []models.FormerNumberHolder{},
+	}
+
+	var current models.Student
+	err := s.db.Where("school_id = ? AND student_number = ?", schoolID, studentNumber).
+		First(&current).Error
+	if err == nil {
+		lookup.Current = &current
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get student: %w", err)
+	}
+
+	var history []models.StudentNumberHistory
+	if err := s.db.Where("school_id = ? AND student_number = ?", schoolID, studentNumber).
+		Order("changed_at DESC, id DESC").
+		Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to get number history: %w", err)
+	}
+
+	seen := make(map[uint]bool)
+	for _, h := range history {
+		if seen[h.StudentID] {
+			continue
+		}
+		seen[h.StudentID] = true
+
+		var student models.Student
+		if err := s.db.First(&student, h.StudentID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get student: %w", err)
+		}
+		lookup.Former = append(lookup.Former, models.FormerNumberHolder{
+			Student:   student,
+			ChangedAt: h.ChangedAt,
+		})
+	}
+
+	return lookup, nil
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 	"github.com/wei979/ICACP/backend/internal/models"
@@ -142,6 +143,114 @@ func (s *TemplateService) GenerateStudentTemplate() (*bytes.Buffer, error) {
 	return buffer, nil
 }
 
+// GenerateSchoolTemplate creates a school master-data Excel template
+func (s *TemplateService) GenerateSchoolTemplate() (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheetName := "學校名單"
+	f.SetSheetName("Sheet1", sheetName)
+
+	// Set column widths
+	f.SetColWidth(sheetName, "A", "A", 12) // 學校代碼
+	f.SetColWidth(sheetName, "B", "B", 30) // 學校名稱
+	f.SetColWidth(sheetName, "C", "C", 10) // 縣市
+	f.SetColWidth(sheetName, "D", "D", 40) // 地址
+	f.SetColWidth(sheetName, "E", "E", 15) // 電話
+	f.SetColWidth(sheetName, "F", "G", 14) // 緯度、經度
+
+	// Keep codes as text so leading zeros survive
+	textStyle, _ := f.NewStyle(&excelize.Style{
+		NumFmt: 49, // Text format (@ in Excel)
+	})
+	f.SetColStyle(sheetName, "A", textStyle)
+
+	// Create header style
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+			Size: 12,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{"#E2EFDA"},
+			Pattern: 1,
+		},
+		Border: []excelize.Border{
+			{Type: "left", Color: "#000000", Style: 1},
+			{Type: "top", Color: "#000000", Style: 1},
+			{Type: "bottom", Color: "#000000", Style: 1},
+			{Type: "right", Color: "#000000", Style: 1},
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "center",
+			Vertical:   "center",
+		},
+	})
+
+	// Write headers
+	for i, header := range models.SchoolTemplateHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	}
+
+	// Add example data (row 2)
+	exampleData := []interface{}{"123456", "臺北市立示範國民小學", "臺北市", "臺北市大安區示範路1號", "02-12345678", 25.0330, 121.5436}
+	for i, value := range exampleData {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 2)
+		f.SetCellValue(sheetName, cell, value)
+	}
+
+	// Add county dropdown validation (C2:C1000)
+	countyDV := excelize.NewDataValidation(true)
+	countyDV.Sqref = "C2:C1000"
+	countyDV.SetDropList(models.ValidTaiwanCounties)
+	countyDV.SetError(excelize.DataValidationErrorStyleStop, "錯誤", "請從清單中選擇縣市")
+	f.AddDataValidation(sheetName, countyDV)
+
+	// Add instructions sheet
+	instructionSheet := "使用說明"
+	f.NewSheet(instructionSheet)
+	f.SetColWidth(instructionSheet, "A", "A", 80)
+
+	instructions := []string{
+		"學校資料批次匯入模板 - 使用說明",
+		"",
+		"欄位說明：",
+		"• 學校代碼 (選填)：教育部學校代碼，最多 10 字元",
+		"• 學校名稱* (必填)：學校全名，最多 100 字元",
+		"• 縣市* (必填)：22 個縣市之一，如「臺北市」（「台」會自動轉為「臺」）",
+		"• 地址 (選填)：學校地址，鄉鎮市區會自動從地址判斷",
+		"• 電話 (選填)：最多 20 字元",
+		"• 緯度、經度 (選填)：十進位座標，如 25.0330、121.5436，須同時填寫",
+		"",
+		"注意事項：",
+		"1. 有學校代碼時以代碼比對既有學校，否則以縣市加學校名稱比對",
+		"2. 新增模式下既有學校會列為錯誤；更新模式只更新既有學校；新增或更新模式兩者皆可",
+		"3. 更新時空白的選填欄位會保留原本的資料",
+		"4. 同一份檔案中學校代碼、同縣市的學校名稱不可重複",
+		"5. 範例資料可覆蓋或刪除",
+	}
+
+	for i, text := range instructions {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		f.SetCellValue(instructionSheet, cell, text)
+	}
+
+	// Set active sheet to main sheet
+	idx, _ := f.GetSheetIndex(sheetName)
+	f.SetActiveSheet(idx)
+
+	// Write to buffer
+	buffer := new(bytes.Buffer)
+	if err := f.Write(buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer, nil
+}
+
 // GenerateRecordsTemplate creates a sport records Excel template
 func (s *TemplateService) GenerateRecordsTemplate() (*bytes.Buffer, error) {
 	f := excelize.NewFile()
@@ -237,7 +346,7 @@ func (s *TemplateService) GenerateRecordsTemplate() (*bytes.Buffer, error) {
 		"3. 座號 + 姓名 必須對應系統中已存在的學生",
 		"4. 運動項目可以只填寫部分（如只測了身高體重）",
 		"5. 空白的運動項目會自動跳過",
-		"6. 系統會新增記錄，不會覆蓋既有資料",
+		"6. 同一學生同日同項目已有記錄時預設略過，可於匯入時選擇取代或保留兩筆",
 		"7. 超出合理範圍的數值會顯示警告，但仍可匯入",
 		"",
 		"合理值範圍參考：",
@@ -278,9 +387,9 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 	var students []models.Student
 	query := s.db.Where("school_id = ? AND grade = ?", schoolID, grade)
 	if class != "" {
-		query = query.Where("class = ?", class)
+		query = query.Where("class = ?", NormalizeClassName(class))
 	}
-	query = query.Order("class ASC, student_number ASC")
+	query = query.Order("LENGTH(class) ASC, class ASC, LENGTH(student_number) ASC, student_number ASC")
 
 	if err := query.Find(&students).Error; err != nil {
 		return nil, fmt.Errorf("查詢學生資料失敗: %w", err)
@@ -295,17 +404,106 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 
 	sheetName := "運動記錄"
 	f.SetSheetName("Sheet1", sheetName)
+	writeRecordsSheet(f, sheetName, students)
+
+	// Add instructions sheet
+	writeRecordsInstructions(f, []string{
+		"運動記錄批次匯入模板 - 使用說明",
+		"",
+		fmt.Sprintf("此模板已載入 %d 位學生資料", len(students)),
+	})
+
+	// Set active sheet to main sheet
+	idx, _ := f.GetSheetIndex(sheetName)
+	f.SetActiveSheet(idx)
+
+	// Write to buffer
+	buffer := new(bytes.Buffer)
+	if err := f.Write(buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer, nil
+}
+
+// GenerateSchoolRecordsTemplate creates a whole-school sport records workbook
+// with one sheet per class, named so that PreviewSchoolRecordsImport can read
+// the grade and class back from it
+func (s *TemplateService) GenerateSchoolRecordsTemplate(schoolID uint) (*bytes.Buffer, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection not available")
+	}
+
+	var students []models.Student
+	err := s.db.Where("school_id = ?", schoolID).
+		Order("grade ASC, LENGTH(class) ASC, class ASC, LENGTH(student_number) ASC, student_number ASC").
+		Find(&students).Error
+	if err != nil {
+		return nil, fmt.Errorf("查詢學生資料失敗: %w", err)
+	}
+
+	if len(students) == 0 {
+		return nil, fmt.Errorf("找不到符合條件的學生（學校ID: %d）", schoolID)
+	}
 
+	// Group students by class, keeping the query order
+	sheetNames := make([]string, 0)
+	classes := make(map[string][]models.Student)
+	for _, student := range students {
+		name := ClassSheetName(student.Grade, student.Class)
+		if _, exists := classes[name]; !exists {
+			sheetNames = append(sheetNames, name)
+		}
+		classes[name] = append(classes[name], student)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for i, sheetName := range sheetNames {
+		if i == 0 {
+			f.SetSheetName("Sheet1", sheetName)
+		} else {
+			f.NewSheet(sheetName)
+		}
+		writeRecordsSheet(f, sheetName, classes[sheetName])
+	}
+
+	// Add instructions sheet
+	writeRecordsInstructions(f, []string{
+		"全校運動記錄批次匯入模板 - 使用說明",
+		"",
+		fmt.Sprintf("此模板已載入 %d 個班級、共 %d 位學生資料", len(sheetNames), len(students)),
+		"每個班級一個工作表，工作表名稱即為年級與班級（如「3年1班」），請勿修改",
+		"整份活頁簿可一次上傳匯入，「使用說明」工作表會自動略過",
+	})
+
+	// Set active sheet to the first class
+	idx, _ := f.GetSheetIndex(sheetNames[0])
+	f.SetActiveSheet(idx)
+
+	// Write to buffer
+	buffer := new(bytes.Buffer)
+	if err := f.Write(buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer, nil
+}
+
+// writeRecordsSheet writes the records template headers and the number and
+// name of each student to a sheet
+func writeRecordsSheet(f *excelize.File, sheetName string, students []models.Student) {
 	// Set column widths
-	f.SetColWidth(sheetName, "A", "A", 10)  // 座號
-	f.SetColWidth(sheetName, "B", "B", 15)  // 姓名
-	f.SetColWidth(sheetName, "C", "C", 12)  // 身高
-	f.SetColWidth(sheetName, "D", "D", 12)  // 體重
-	f.SetColWidth(sheetName, "E", "E", 15)  // 坐姿體前彎
-	f.SetColWidth(sheetName, "F", "F", 12)  // 立定跳遠
-	f.SetColWidth(sheetName, "G", "G", 18)  // 仰臥起坐
-	f.SetColWidth(sheetName, "H", "H", 12)  // 心肺耐力
-	f.SetColWidth(sheetName, "I", "I", 15)  // 測驗日期
+	f.SetColWidth(sheetName, "A", "A", 10) // 座號
+	f.SetColWidth(sheetName, "B", "B", 15) // 姓名
+	f.SetColWidth(sheetName, "C", "C", 12) // 身高
+	f.SetColWidth(sheetName, "D", "D", 12) // 體重
+	f.SetColWidth(sheetName, "E", "E", 15) // 坐姿體前彎
+	f.SetColWidth(sheetName, "F", "F", 12) // 立定跳遠
+	f.SetColWidth(sheetName, "G", "G", 18) // 仰臥起坐
+	f.SetColWidth(sheetName, "H", "H", 12) // 心肺耐力
+	f.SetColWidth(sheetName, "I", "I", 15) // 測驗日期
 
 	// Set date column format to text to prevent Excel auto-conversion
 	textStyle, _ := f.NewStyle(&excelize.Style{
@@ -359,16 +557,16 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 		// Leave columns C-H empty for teachers to fill in
 		// Column I (測驗日期) also left empty
 	}
+}
 
-	// Add instructions sheet
+// writeRecordsInstructions adds the instructions sheet of the records
+// templates with students, starting with the given introduction lines
+func writeRecordsInstructions(f *excelize.File, intro []string) {
 	instructionSheet := "使用說明"
 	f.NewSheet(instructionSheet)
 	f.SetColWidth(instructionSheet, "A", "A", 80)
 
-	instructions := []string{
-		"運動記錄批次匯入模板 - 使用說明",
-		"",
-		fmt.Sprintf("此模板已載入 %d 位學生資料", len(students)),
+	instructions := append(intro,
 		"",
 		"欄位說明：",
 		"• 座號* (必填)：學生在班級中的座號（已自動填入）",
@@ -385,7 +583,7 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 		"1. 座號和姓名已自動填入，請勿修改",
 		"2. 運動項目可以只填寫部分（如只測了身高體重）",
 		"3. 空白的運動項目會自動跳過",
-		"4. 系統會新增記錄，不會覆蓋既有資料",
+		"4. 同一學生同日同項目已有記錄時預設略過，可於匯入時選擇取代或保留兩筆",
 		"5. 超出合理範圍的數值會顯示警告，但仍可匯入",
 		"",
 		"合理值範圍參考：",
@@ -395,15 +593,72 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 		"• 立定跳遠：20-350 cm",
 		"• 仰臥起坐：0-100 次",
 		"• 心肺耐力：60-1800 秒",
-	}
+	)
 
 	for i, text := range instructions {
 		cell, _ := excelize.CoordinatesToCellName(1, i+1)
 		f.SetCellValue(instructionSheet, cell, text)
 	}
+}
 
-	// Set active sheet to main sheet
-	idx, _ := f.GetSheetIndex(sheetName)
+// Fill colors used to highlight import problems
+const (
+	annotationErrorColor   = "#FFC7CE"
+	annotationWarningColor = "#FFEB9C"
+)
+
+// GenerateAnnotatedWorkbook returns the uploaded file of a preview with
+// problem cells highlighted (errors red, warnings yellow), a comment holding
+// the messages on each of those cells and an extra status column, so
+// teachers can fix the file and upload it again. Every sheet with preview
+// rows is annotated. Files that were not .xlsx are converted to .xlsx.
+func (s *TemplateService) GenerateAnnotatedWorkbook(preview *models.ImportPreview) (*bytes.Buffer, error) {
+	if len(preview.FileData) == 0 {
+		return nil, fmt.Errorf("預覽資料不包含原始檔案")
+	}
+
+	f, sheetNames, err := openAnnotationWorkbook(preview)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Column maps of the sheets of a multi-sheet import
+	sheetColumns := make(map[string]map[string]int)
+	for _, summary := range preview.Sheets {
+		sheetColumns[summary.Name] = summary.ColumnMap
+	}
+
+	// Group the rows by sheet; rows of single-sheet imports carry no sheet
+	order := make([]string, 0)
+	sheetRows := make(map[string][]models.ImportRow)
+	columnMaps := make(map[string]map[string]int)
+	for _, row := range preview.Rows {
+		sheetName, ok := sheetNames[row.Sheet]
+		if !ok {
+			continue
+		}
+		if _, exists := sheetRows[sheetName]; !exists {
+			order = append(order, sheetName)
+			columnMaps[sheetName] = preview.ColumnMap
+			if columns, ok := sheetColumns[row.Sheet]; ok && columns != nil {
+				columnMaps[sheetName] = columns
+			}
+		}
+		sheetRows[sheetName] = append(sheetRows[sheetName], row)
+	}
+	if len(order) == 0 {
+		order = append(order, sheetNames[""])
+	}
+
+	for _, sheetName := range order {
+		if err := annotateSheet(f, sheetName, sheetRows[sheetName], columnMaps[sheetName]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Set active sheet to the first annotated sheet
+	idx, _ := f.GetSheetIndex(order[0])
 	f.SetActiveSheet(idx)
 
 	// Write to buffer
@@ -414,3 +669,215 @@ func (s *TemplateService) GenerateRecordsTemplateWithStudents(schoolID uint, gra
 
 	return buffer, nil
 }
+
+// annotateSheet highlights the problems of the preview rows of one sheet and
+// adds the status column
+func annotateSheet(f *excelize.File, sheetName string, previewRows []models.ImportRow, columnMap map[string]int) error {
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("無法讀取工作表: %w", err)
+	}
+	statusCol := 1
+	for _, row := range rows {
+		if len(row)+1 > statusCol {
+			statusCol = len(row) + 1
+		}
+	}
+
+	// Status column header, styled like the template headers
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+			Size: 12,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{"#E2EFDA"},
+			Pattern: 1,
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "center",
+			Vertical:   "center",
+		},
+	})
+	headerCell, _ := excelize.CoordinatesToCellName(statusCol, 1)
+	f.SetCellValue(sheetName, headerCell, "匯入檢查結果")
+	f.SetCellStyle(sheetName, headerCell, headerCell, headerStyle)
+	statusColName, _ := excelize.ColumnNumberToName(statusCol)
+	f.SetColWidth(sheetName, statusColName, statusColName, 40)
+
+	highlighter := newCellHighlighter(f, sheetName)
+
+	for _, row := range previewRows {
+		statusCell, _ := excelize.CoordinatesToCellName(statusCol, row.RowNumber)
+
+		// Group the messages of each cell; problems without a column of their
+		// own (e.g. "no sport values") go on the status cell
+		type cellNote struct {
+			messages []string
+			isError  bool
+		}
+		notes := make(map[string]*cellNote)
+		order := make([]string, 0, len(row.Errors))
+		for _, rowErr := range row.Errors {
+			cell := statusCell
+			if col, ok := columnMap[rowErr.Field]; ok {
+				cell, _ = excelize.CoordinatesToCellName(col+1, row.RowNumber)
+			}
+			note, exists := notes[cell]
+			if !exists {
+				note = &cellNote{}
+				notes[cell] = note
+				order = append(order, cell)
+			}
+			note.messages = append(note.messages, rowErr.Message)
+			note.isError = note.isError || rowErr.Level == "error"
+		}
+
+		for _, cell := range order {
+			note := notes[cell]
+			color := annotationWarningColor
+			if note.isError {
+				color = annotationErrorColor
+			}
+			highlighter.fill(cell, color)
+			if cell != statusCell {
+				f.AddComment(sheetName, excelize.Comment{
+					Author: "匯入檢查",
+					Cell:   cell,
+					Text:   strings.Join(note.messages, "\n"),
+				})
+			}
+		}
+
+		switch row.Status {
+		case models.RowStatusError:
+			f.SetCellValue(sheetName, statusCell, "錯誤："+rowMessages(row))
+			highlighter.fill(statusCell, annotationErrorColor)
+		case models.RowStatusWarning:
+			f.SetCellValue(sheetName, statusCell, "警告："+rowMessages(row))
+			highlighter.fill(statusCell, annotationWarningColor)
+		default:
+			f.SetCellValue(sheetName, statusCell, "可匯入")
+		}
+	}
+
+	return nil
+}
+
+// openAnnotationWorkbook opens the uploaded file of a preview for editing and
+// maps the sheet names of the preview rows to sheets of the workbook; the
+// empty name maps to the sheet of a single-sheet preview. An .xlsx upload is
+// opened as is to keep its formatting; other formats are copied cell by cell
+// into a new workbook.
+func openAnnotationWorkbook(preview *models.ImportPreview) (*excelize.File, map[string]string, error) {
+	sheetNames := make(map[string]string)
+
+	if preview.FileFormat == FormatXLSX {
+		f, err := excelize.OpenReader(bytes.NewReader(preview.FileData))
+		if err != nil {
+			return nil, nil, fmt.Errorf("無法解析 Excel 檔案: %w", err)
+		}
+		for _, name := range f.GetSheetList() {
+			sheetNames[name] = name
+		}
+		sheetName := preview.SheetName
+		if idx, _ := f.GetSheetIndex(sheetName); sheetName == "" || idx < 0 {
+			sheetName = f.GetSheetName(0)
+		}
+		sheetNames[""] = sheetName
+		return f, sheetNames, nil
+	}
+
+	wb, err := ReadWorkbook(bytes.NewReader(preview.FileData), preview.FileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := excelize.NewFile()
+
+	// Write values as text so student numbers keep their leading zeros
+	textStyle, _ := f.NewStyle(&excelize.Style{
+		NumFmt: 49, // Text format (@ in Excel)
+	})
+	for i, sheet := range wb.Sheets {
+		sheetName := sheet.Name
+		// Excel sheet names are at most 31 characters without []:*?/\
+		if sheetName == "" || len([]rune(sheetName)) > 31 || strings.ContainsAny(sheetName, "[]:*?/\\") {
+			sheetName = fmt.Sprintf("Sheet%d", i+1)
+		}
+		if i == 0 {
+			if sheetName != "Sheet1" {
+				f.SetSheetName("Sheet1", sheetName)
+			}
+		} else {
+			if idx, _ := f.GetSheetIndex(sheetName); idx >= 0 {
+				sheetName = fmt.Sprintf("Sheet%d", i+1)
+			}
+			f.NewSheet(sheetName)
+		}
+		sheetNames[sheet.Name] = sheetName
+		if sheet.Name == preview.SheetName || i == 0 {
+			sheetNames[""] = sheetName
+		}
+
+		for r, row := range sheet.Rows {
+			for c, value := range row {
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+				f.SetCellStr(sheetName, cell, value)
+				f.SetCellStyle(sheetName, cell, cell, textStyle)
+			}
+		}
+	}
+
+	return f, sheetNames, nil
+}
+
+// rowMessages joins all messages of a preview row
+func rowMessages(row models.ImportRow) string {
+	messages := make([]string, 0, len(row.Errors))
+	for _, rowErr := range row.Errors {
+		messages = append(messages, rowErr.Message)
+	}
+	return strings.Join(messages, "；")
+}
+
+// cellHighlighter fills cells with a color while keeping the rest of their
+// existing style (number format, font, borders)
+type cellHighlighter struct {
+	f      *excelize.File
+	sheet  string
+	styles map[string]int // "original style|color" -> highlighted style
+}
+
+func newCellHighlighter(f *excelize.File, sheet string) *cellHighlighter {
+	return &cellHighlighter{f: f, sheet: sheet, styles: make(map[string]int)}
+}
+
+// fill applies a solid fill color to a cell
+func (h *cellHighlighter) fill(cell, color string) {
+	original, err := h.f.GetCellStyle(h.sheet, cell)
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("%d|%s", original, color)
+	styleID, exists := h.styles[key]
+	if !exists {
+		style, err := h.f.GetStyle(original)
+		if err != nil || style == nil {
+			style = &excelize.Style{}
+		}
+		style.Fill = excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{color},
+			Pattern: 1,
+		}
+		if styleID, err = h.f.NewStyle(style); err != nil {
+			return
+		}
+		h.styles[key] = styleID
+	}
+
+	h.f.SetCellStyle(h.sheet, cell, cell, styleID)
+}